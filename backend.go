@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Backend queries and configures the outputs of a display server.
+type Backend interface {
+	// Outputs returns the list of outputs without probing for changes.
+	Outputs() (Outputs, error)
+
+	// Detect probes for changed outputs and returns the list of outputs.
+	Detect() (Outputs, error)
+
+	// Apply configures the outputs according to the layout described by
+	// rule (configure_row, configure_column or configure_single).
+	Apply(rule Rule, current Outputs) error
+
+	// Disable switches off the given outputs.
+	Disable(off Outputs) error
+
+	// Subscribe starts sending change events to ch until done is closed.
+	// Backends which cannot report changes never send an event.
+	Subscribe(ch chan<- Event, done <-chan struct{}) error
+}
+
+// DetailedBackend is implemented by backends which can report the CRTC
+// assignment, transform and mode timings of the outputs (see OutputDetails
+// and ModeTiming).
+type DetailedBackend interface {
+	// DetailedOutputs returns the list of outputs including their details.
+	DetailedOutputs() (Outputs, error)
+}
+
+// Event is a change notification sent by a backend. Event holds the
+// backend-specific event, it is only used for logging. Error is set when the
+// backend stops sending events, e.g. because the connection to the display
+// server was lost.
+type Event struct {
+	Event interface{}
+	Error error
+
+	// Outputs is set when the backend was able to apply the change to the
+	// outputs it queried last, there is no need to query them again.
+	Outputs Outputs
+}
+
+// defaultBackend is used when neither the config nor the command line
+// select a backend.
+const defaultBackend = "randr"
+
+var backends = make(map[string]func() (Backend, error))
+
+// registerBackend makes a backend available under name.
+func registerBackend(name string, fn func() (Backend, error)) {
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("backend %v registered twice", name))
+	}
+	backends[name] = fn
+}
+
+// backendNames returns the sorted list of available backends.
+func backendNames() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend returns the named backend.
+func NewBackend(name string) (Backend, error) {
+	fn, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, available backends: %v",
+			name, strings.Join(backendNames(), ", "))
+	}
+
+	return fn()
+}
+
+// runRuleCommands runs cmds for the rule, errors are printed but do not stop
+// the execution of the remaining commands.
+func runRuleCommands(rule Rule, cmds []*exec.Cmd) {
+	for _, cmd := range cmds {
+		err := RunCommand(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "executing command for rule %v failed: %v\n", rule.Name, err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hyprMonitor is a monitor as printed by `hyprctl monitors all -j`.
+type hyprMonitor struct {
+	Name           string   `json:"name"`
+	Make           string   `json:"make"`
+	Model          string   `json:"model"`
+	Serial         string   `json:"serial"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	RefreshRate    float64  `json:"refreshRate"`
+	X              int      `json:"x"`
+	Y              int      `json:"y"`
+	Scale          float64  `json:"scale"`
+	Focused        bool     `json:"focused"`
+	Disabled       bool     `json:"disabled"`
+	AvailableModes []string `json:"availableModes"`
+}
+
+// hyprMode is a mode parsed from the list of available modes, which looks
+// like "1920x1080@60.01Hz".
+type hyprMode struct {
+	Name string
+	Rate float64
+}
+
+// String returns the mode in the format used by `hyprctl keyword monitor`.
+func (m hyprMode) String() string {
+	return m.Name + "@" + strconv.FormatFloat(m.Rate, 'f', -1, 64)
+}
+
+// parseHyprMode parses a mode from the list of available modes.
+func parseHyprMode(s string) (hyprMode, error) {
+	data := strings.SplitN(strings.TrimSuffix(s, "Hz"), "@", 2)
+	if len(data) != 2 {
+		return hyprMode{}, fmt.Errorf("invalid mode %q", s)
+	}
+
+	rate, err := strconv.ParseFloat(data[1], 64)
+	if err != nil {
+		return hyprMode{}, fmt.Errorf("invalid mode %q: %w", s, err)
+	}
+
+	return hyprMode{Name: data[0], Rate: rate}, nil
+}
+
+// modes returns the parsed list of available modes, invalid modes are
+// skipped.
+func (m hyprMonitor) modes() []hyprMode {
+	var modes []hyprMode
+	for _, s := range m.AvailableModes {
+		mode, err := parseHyprMode(s)
+		if err != nil {
+			V("monitor %v: %v\n", m.Name, err)
+			continue
+		}
+		modes = append(modes, mode)
+	}
+	return modes
+}
+
+// current returns the name of the current mode.
+func (m hyprMonitor) current() string {
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+}
+
+// Output converts the monitor to grobi's representation. Hyprland does not
+// report preferred modes, the first one is used for --auto.
+func (m hyprMonitor) Output() Output {
+	output := Output{
+		Name:      m.Name,
+		Connected: true,
+		MonitorID: waylandMonitorID(m.Make, m.Model, m.Serial),
+		Vendor:    waylandVendor(m.Make),
+	}
+
+	for _, mode := range m.modes() {
+		current := !m.Disabled && mode.Name == m.current()
+		added := addWaylandMode(&output, mode.Name, mode.Rate, current && m.isCurrentRate(mode.Rate), false)
+		if current {
+			added.Active = true
+		}
+	}
+
+	return output
+}
+
+// isCurrentRate returns true if rate from the list of available modes is the
+// current refresh rate, which is printed with more decimals.
+func (m hyprMonitor) isCurrentRate(rate float64) bool {
+	return math.Abs(rate-m.RefreshRate) < 0.01
+}
+
+// findMode returns the mode with the given name ("1920x1080") and refresh
+// rate, see selectWaylandMode.
+func (m hyprMonitor) findMode(name string, rate float64) (hyprMode, bool) {
+	modes := m.modes()
+	list := make([]waylandMode, 0, len(modes))
+	for _, mode := range modes {
+		list = append(list, waylandMode{
+			Name:    mode.Name,
+			Rate:    mode.Rate,
+			Current: !m.Disabled && mode.Name == m.current() && m.isCurrentRate(mode.Rate),
+		})
+	}
+
+	i, ok := selectWaylandMode(list, name, rate)
+	if !ok {
+		return hyprMode{}, false
+	}
+	return modes[i], true
+}
+
+// parseHyprMonitors parses the output of `hyprctl monitors all -j`.
+func parseHyprMonitors(buf []byte) ([]hyprMonitor, error) {
+	var monitors []hyprMonitor
+	err := json.Unmarshal(buf, &monitors)
+	if err != nil {
+		return nil, fmt.Errorf("parsing hyprctl output: %w", err)
+	}
+
+	return monitors, nil
+}
+
+// hyprScales returns the scale factor of each enabled monitor.
+func hyprScales(monitors []hyprMonitor) map[string]float64 {
+	scales := make(map[string]float64)
+	for _, m := range monitors {
+		if !m.Disabled && m.Scale > 0 {
+			scales[m.Name] = m.Scale
+		}
+	}
+	return scales
+}
+
+// BuildHyprlandCommands returns the calls to `hyprctl` which configure layout.
+// Monitors keep their scale factor.
+func BuildHyprlandCommands(monitors []hyprMonitor, layout Layout) ([]*exec.Cmd, error) {
+	byName := make(map[string]hyprMonitor)
+	for _, m := range monitors {
+		byName[m.Name] = m
+	}
+
+	scales := hyprScales(monitors)
+
+	var cmds []*exec.Cmd
+	for _, name := range layout.Disable {
+		cmds = append(cmds, exec.Command("hyprctl", "keyword", "monitor", name+",disable"))
+	}
+
+	var primary string
+	for _, out := range layout.Outputs {
+		m, ok := byName[out.Name]
+		if !ok {
+			return nil, fmt.Errorf("monitor %v not found", out.Name)
+		}
+
+		mode, ok := m.findMode(out.Mode, out.Rate)
+		if !ok {
+			return nil, fmt.Errorf("monitor %v: mode %v not found", out.Name, out.Mode)
+		}
+
+		scale := 1.0
+		if s, ok := scales[out.Name]; ok {
+			scale = s
+		}
+
+		cmds = append(cmds, exec.Command("hyprctl", "keyword", "monitor",
+			fmt.Sprintf("%s,%s,%dx%d,%s", out.Name, mode, out.X, out.Y,
+				strconv.FormatFloat(scale, 'f', -1, 64))))
+
+		if out.Primary {
+			primary = out.Name
+		}
+	}
+
+	// like on sway, the primary monitor is focused so that new windows open
+	// there
+	if primary != "" {
+		cmds = append(cmds, exec.Command("hyprctl", "dispatch", "focusmonitor", primary))
+	}
+
+	return cmds, nil
+}
+
+// hyprEventSocket returns the path of Hyprland's event socket. Since
+// Hyprland 0.40 it is located in $XDG_RUNTIME_DIR, older versions use /tmp.
+func hyprEventSocket() (string, error) {
+	sig := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if sig == "" {
+		return "", errors.New("HYPRLAND_INSTANCE_SIGNATURE is not set")
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		path := filepath.Join(dir, "hypr", sig, ".socket2.sock")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return filepath.Join("/tmp", "hypr", sig, ".socket2.sock"), nil
+}
+
+// hyprlandBackend configures monitors on Hyprland by running `hyprctl`.
+type hyprlandBackend struct {
+	// monitors holds the monitors from the last query.
+	monitors []hyprMonitor
+}
+
+func init() {
+	registerBackend("hyprland", func() (Backend, error) {
+		return &hyprlandBackend{}, nil
+	})
+}
+
+// Outputs runs `hyprctl monitors all -j` and returns the parsed output.
+func (b *hyprlandBackend) Outputs() (Outputs, error) {
+	cmd := exec.Command("hyprctl", "monitors", "all", "-j")
+	cmd.Stderr = os.Stderr
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	monitors, err := parseHyprMonitors(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	b.monitors = monitors
+
+	outputs := make(Outputs, 0, len(monitors))
+	for _, m := range monitors {
+		outputs = append(outputs, m.Output())
+	}
+
+	return outputs, nil
+}
+
+// Detect returns the outputs, Hyprland probes for changes on its own.
+func (b *hyprlandBackend) Detect() (Outputs, error) {
+	return b.Outputs()
+}
+
+// Apply runs `hyprctl keyword monitor` to configure the outputs for rule.
+func (b *hyprlandBackend) Apply(rule Rule, current Outputs) error {
+	layout, err := ComputeScaledLayout(rule, current, hyprScales(b.monitors))
+	if err != nil {
+		return err
+	}
+
+	cmds, err := BuildHyprlandCommands(b.monitors, layout)
+	if err != nil {
+		return err
+	}
+
+	runRuleCommands(rule, cmds)
+	return nil
+}
+
+// Disable runs `hyprctl keyword monitor` to switch off the outputs.
+func (b *hyprlandBackend) Disable(off Outputs) error {
+	for _, output := range off {
+		err := RunCommand(exec.Command("hyprctl", "keyword", "monitor", output.Name+",disable"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subscribe forwards the monitoradded and monitorremoved events received on
+// Hyprland's event socket.
+func (b *hyprlandBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	path, err := hyprEventSocket()
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return fmt.Errorf("connecting to Hyprland event socket: %w", err)
+	}
+
+	go func() {
+		<-done
+		_ = conn.Close()
+	}()
+
+	go func() {
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			line := sc.Text()
+			name := strings.SplitN(line, ">>", 2)[0]
+			if name != "monitoradded" && name != "monitorremoved" {
+				continue
+			}
+
+			select {
+			case ch <- Event{Event: line}:
+			case <-done:
+				return
+			}
+		}
+
+		err := sc.Err()
+		if err == nil {
+			err = errors.New("event socket closed by Hyprland")
+		}
+
+		select {
+		case ch <- Event{Error: err}:
+		case <-done:
+		}
+	}()
+
+	return nil
+}
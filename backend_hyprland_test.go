@@ -0,0 +1,190 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// hyprctlMonitorsOutput was recorded from `hyprctl monitors all -j` on
+// Hyprland 0.41, shortened to the relevant fields.
+const hyprctlMonitorsOutput = `[
+  {
+    "id": 0, "name": "eDP-1", "description": "Chimei Innolux Corporation 0x14D4",
+    "make": "Chimei Innolux Corporation", "model": "0x14D4", "serial": "",
+    "width": 1920, "height": 1080, "refreshRate": 60.00800,
+    "x": 0, "y": 0, "scale": 1.50, "transform": 0,
+    "focused": true, "dpmsStatus": true, "vrr": false, "disabled": false,
+    "availableModes": ["1920x1080@60.01Hz", "1920x1080@48.01Hz", "1280x720@60.00Hz"]
+  },
+  {
+    "id": 1, "name": "DP-2", "description": "Dell Inc. DELL U2720Q 8LXMZ13",
+    "make": "Dell Inc.", "model": "DELL U2720Q", "serial": "8LXMZ13",
+    "width": 3840, "height": 2160, "refreshRate": 60.00000,
+    "x": 0, "y": 0, "scale": 1.00, "transform": 0,
+    "focused": false, "dpmsStatus": true, "vrr": false, "disabled": true,
+    "availableModes": ["3840x2160@60.00Hz", "3840x2160@30.00Hz", "2560x1440@59.95Hz"]
+  }
+]`
+
+func TestParseHyprMonitors(t *testing.T) {
+	monitors, err := parseHyprMonitors([]byte(hyprctlMonitorsOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outputs Outputs
+	for _, m := range monitors {
+		outputs = append(outputs, m.Output())
+	}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Modes: Modes{
+				{Name: "1920x1080", Active: true, Rates: []float64{60.01, 48.01}, ActiveRate: 60.01},
+				{Name: "1280x720", Rates: []float64{60}},
+			},
+			MonitorID: "Chimei Innolux Corporation-0x14D4-",
+			Vendor:    "Chimei Innolux Corporation",
+		},
+		{
+			Name:      "DP-2",
+			Connected: true,
+			Modes: Modes{
+				{Name: "3840x2160", Rates: []float64{60, 30}},
+				{Name: "2560x1440", Rates: []float64{59.95}},
+			},
+			MonitorID: "Dell Inc.-DELL U2720Q-8LXMZ13",
+			Vendor:    "Dell Inc.",
+		},
+	}
+
+	if !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("wrong outputs returned:\n  want %v\n  got  %v", want, outputs)
+	}
+
+	if !reflect.DeepEqual(hyprScales(monitors), map[string]float64{"eDP-1": 1.5}) {
+		t.Fatalf("wrong scales returned: %v", hyprScales(monitors))
+	}
+}
+
+func TestBuildHyprlandCommands(t *testing.T) {
+	monitors, err := parseHyprMonitors([]byte(hyprctlMonitorsOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outputs Outputs
+	for _, m := range monitors {
+		outputs = append(outputs, m.Output())
+	}
+
+	var tests = []struct {
+		rule Rule
+		cmds [][]string
+	}{
+		{
+			Rule{ConfigureRow: []string{"eDP-1", "DP-2"}, Primary: "DP-2"},
+			[][]string{
+				{"hyprctl", "keyword", "monitor", "eDP-1,1920x1080@60.01,0x0,1.5"},
+				{"hyprctl", "keyword", "monitor", "DP-2,3840x2160@60,1280x0,1"},
+				{"hyprctl", "dispatch", "focusmonitor", "DP-2"},
+			},
+		},
+		{
+			Rule{ConfigureRow: []string{"eDP-1@1920x1080@48", "DP-2@3840x2160@30"}},
+			[][]string{
+				{"hyprctl", "keyword", "monitor", "eDP-1,1920x1080@48.01,0x0,1.5"},
+				{"hyprctl", "keyword", "monitor", "DP-2,3840x2160@30,1280x0,1"},
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP-2@2560x1440"},
+			[][]string{
+				{"hyprctl", "keyword", "monitor", "eDP-1,disable"},
+				{"hyprctl", "keyword", "monitor", "DP-2,2560x1440@59.95,0x0,1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			layout, err := ComputeScaledLayout(test.rule, outputs, hyprScales(monitors))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmds, err := BuildHyprlandCommands(monitors, layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var args [][]string
+			for _, cmd := range cmds {
+				args = append(args, cmd.Args)
+			}
+
+			if !reflect.DeepEqual(args, test.cmds) {
+				t.Fatalf("wrong commands returned:\n  want %q\n  got  %q", test.cmds, args)
+			}
+		})
+	}
+}
+
+func TestHyprlandSubscribe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grobi-test-hypr-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	err = os.MkdirAll(filepath.Join(dir, "hypr", "sig"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", filepath.Join(dir, "hypr", "sig", ".socket2.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	setenv(t, "XDG_RUNTIME_DIR", dir)
+	setenv(t, "HYPRLAND_INSTANCE_SIGNATURE", "sig")
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("workspace>>2\nmonitoraddedv2>>1,DP-2,Dell Inc.\nmonitoradded>>DP-2\n"))
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	err = (&hyprlandBackend{}).Subscribe(ch, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-ch:
+		if ev.Error != nil {
+			t.Fatal(ev.Error)
+		}
+
+		if ev.Event != "monitoradded>>DP-2" {
+			t.Fatalf("wrong event received: %v", ev.Event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
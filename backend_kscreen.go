@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// kscreenSize is a size as printed by `kscreen-doctor -j`.
+type kscreenSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// kscreenMode is a mode as printed by `kscreen-doctor -j`.
+type kscreenMode struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	RefreshRate float64     `json:"refreshRate"`
+	Size        kscreenSize `json:"size"`
+}
+
+// size returns the mode name used by grobi, e.g. "1920x1080".
+func (m kscreenMode) size() string {
+	return fmt.Sprintf("%dx%d", m.Size.Width, m.Size.Height)
+}
+
+// kscreenEdid holds the monitor identity KScreen derives from the EDID.
+type kscreenEdid struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+}
+
+// kscreenOutput is an output as printed by `kscreen-doctor -j`.
+type kscreenOutput struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Connected bool   `json:"connected"`
+	Pos       struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"pos"`
+	Scale          float64       `json:"scale"`
+	Primary        bool          `json:"primary"`
+	Priority       int           `json:"priority"`
+	CurrentModeID  string        `json:"currentModeId"`
+	PreferredModes []string      `json:"preferredModes"`
+	Modes          []kscreenMode `json:"modes"`
+	Edid           *kscreenEdid  `json:"edid"`
+}
+
+// kscreenConfig is the configuration as printed by `kscreen-doctor -j`.
+type kscreenConfig struct {
+	List []kscreenOutput `json:"outputs"`
+}
+
+// parseKScreen parses the output of `kscreen-doctor -j`.
+func parseKScreen(buf []byte) (kscreenConfig, error) {
+	var cfg kscreenConfig
+	err := json.Unmarshal(buf, &cfg)
+	if err != nil {
+		return kscreenConfig{}, fmt.Errorf("parsing kscreen-doctor output: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// isPrimary returns true if the output is the primary one. Plasma 5.24 and
+// later replaced the primary flag with a priority, 1 is the primary output.
+func (o kscreenOutput) isPrimary() bool {
+	return o.Enabled && (o.Primary || o.Priority == 1)
+}
+
+// isPreferred returns true if the mode with the ID is a preferred mode.
+func (o kscreenOutput) isPreferred(id string) bool {
+	for _, pref := range o.PreferredModes {
+		if pref == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Output converts the output to grobi's representation.
+func (o kscreenOutput) Output() Output {
+	output := Output{
+		Name:      o.Name,
+		Connected: o.Connected,
+		Primary:   o.isPrimary(),
+	}
+
+	if o.Edid != nil {
+		output.MonitorID = waylandMonitorID(o.Edid.Vendor, o.Edid.Name, o.Edid.Serial)
+		output.Vendor = waylandVendor(o.Edid.Vendor)
+	}
+
+	for _, m := range o.Modes {
+		addWaylandMode(&output, m.size(), m.RefreshRate, o.Enabled && m.ID == o.CurrentModeID, o.isPreferred(m.ID))
+	}
+
+	return output
+}
+
+// findMode returns the mode with the given name ("1920x1080") and refresh
+// rate of the output, see selectWaylandMode.
+func (o kscreenOutput) findMode(name string, rate float64) (kscreenMode, bool) {
+	list := make([]waylandMode, 0, len(o.Modes))
+	for _, mode := range o.Modes {
+		list = append(list, waylandMode{
+			Name:      mode.size(),
+			Rate:      mode.RefreshRate,
+			Current:   mode.ID == o.CurrentModeID,
+			Preferred: o.isPreferred(mode.ID),
+		})
+	}
+
+	i, ok := selectWaylandMode(list, name, rate)
+	if !ok {
+		return kscreenMode{}, false
+	}
+	return o.Modes[i], true
+}
+
+// Outputs returns the outputs of the configuration.
+func (c kscreenConfig) Outputs() Outputs {
+	outputs := make(Outputs, 0, len(c.List))
+	for _, o := range c.List {
+		outputs = append(outputs, o.Output())
+	}
+	return outputs
+}
+
+// scales returns the scale factor of each enabled output.
+func (c kscreenConfig) scales() map[string]float64 {
+	scales := make(map[string]float64)
+	for _, o := range c.List {
+		if o.Enabled && o.Scale > 0 {
+			scales[o.Name] = o.Scale
+		}
+	}
+	return scales
+}
+
+// BuildKScreenCommand returns a call to `kscreen-doctor` which configures all
+// outputs of layout at once. Modes are selected by their KScreen ID, outputs
+// keep their scale factor.
+func BuildKScreenCommand(cfg kscreenConfig, layout Layout) (*exec.Cmd, error) {
+	outputs := make(map[string]kscreenOutput)
+	for _, o := range cfg.List {
+		outputs[o.Name] = o
+	}
+
+	scales := cfg.scales()
+
+	var args []string
+	for _, name := range layout.Disable {
+		args = append(args, fmt.Sprintf("output.%s.disable", name))
+	}
+
+	for _, out := range layout.Outputs {
+		o, ok := outputs[out.Name]
+		if !ok {
+			return nil, fmt.Errorf("output %v not found", out.Name)
+		}
+
+		mode, ok := o.findMode(out.Mode, out.Rate)
+		if !ok {
+			return nil, fmt.Errorf("output %v: mode %v not found", out.Name, out.Mode)
+		}
+
+		args = append(args,
+			fmt.Sprintf("output.%s.enable", out.Name),
+			fmt.Sprintf("output.%s.mode.%s", out.Name, mode.ID),
+			fmt.Sprintf("output.%s.position.%d,%d", out.Name, out.X, out.Y))
+
+		if scale, ok := scales[out.Name]; ok {
+			args = append(args, fmt.Sprintf("output.%s.scale.%s",
+				out.Name, strconv.FormatFloat(scale, 'f', -1, 64)))
+		}
+
+		if out.Primary {
+			args = append(args, fmt.Sprintf("output.%s.priority.1", out.Name))
+		}
+	}
+
+	return exec.Command("kscreen-doctor", args...), nil
+}
+
+// kscreenBackend configures outputs on KDE Plasma by running
+// `kscreen-doctor`, so that the layout is not overridden by KScreen.
+type kscreenBackend struct {
+	// cfg holds the configuration from the last query.
+	cfg kscreenConfig
+}
+
+func init() {
+	registerBackend("kscreen", func() (Backend, error) {
+		return &kscreenBackend{}, nil
+	})
+}
+
+// Outputs runs `kscreen-doctor -j` and returns the parsed output.
+func (b *kscreenBackend) Outputs() (Outputs, error) {
+	cmd := exec.Command("kscreen-doctor", "-j")
+	cmd.Stderr = os.Stderr
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := parseKScreen(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	b.cfg = cfg
+	return cfg.Outputs(), nil
+}
+
+// Detect returns the outputs, KScreen probes for changes on its own.
+func (b *kscreenBackend) Detect() (Outputs, error) {
+	return b.Outputs()
+}
+
+// Apply runs `kscreen-doctor` to configure the outputs for rule.
+func (b *kscreenBackend) Apply(rule Rule, current Outputs) error {
+	layout, err := ComputeScaledLayout(rule, current, b.cfg.scales())
+	if err != nil {
+		return err
+	}
+
+	cmd, err := BuildKScreenCommand(b.cfg, layout)
+	if err != nil {
+		return err
+	}
+
+	runRuleCommands(rule, []*exec.Cmd{cmd})
+	return nil
+}
+
+// Disable runs `kscreen-doctor` to switch off the outputs.
+func (b *kscreenBackend) Disable(off Outputs) error {
+	if len(off) == 0 {
+		return nil
+	}
+
+	var args []string
+	for _, output := range off {
+		args = append(args, fmt.Sprintf("output.%s.disable", output.Name))
+	}
+
+	return RunCommand(exec.Command("kscreen-doctor", args...))
+}
+
+// Subscribe does nothing, kscreen-doctor cannot report changes. Polling is
+// used instead.
+func (b *kscreenBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	V("kscreen backend does not report changes, relying on polling\n")
+	return nil
+}
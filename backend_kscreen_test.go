@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// kscreenDoctorOutput was recorded from `kscreen-doctor -j` on Plasma 5.27
+// (Wayland), shortened to the relevant fields.
+const kscreenDoctorOutput = `{
+  "outputs": [
+    {
+      "id": 1, "name": "eDP-1", "type": 7, "icon": "video-display",
+      "connected": true, "enabled": true, "priority": 1,
+      "pos": { "x": 0, "y": 0 },
+      "size": { "width": 1920, "height": 1080 },
+      "scale": 1.5, "rotation": 1,
+      "currentModeId": "1",
+      "preferredModes": [ "1" ],
+      "modes": [
+        { "id": "1", "name": "1920x1080@60", "refreshRate": 60.008, "size": { "width": 1920, "height": 1080 } },
+        { "id": "2", "name": "1920x1080@48", "refreshRate": 48.006, "size": { "width": 1920, "height": 1080 } },
+        { "id": "3", "name": "1280x720@60", "refreshRate": 60, "size": { "width": 1280, "height": 720 } }
+      ],
+      "edid": { "vendor": "CMN", "name": "", "serial": "" }
+    },
+    {
+      "id": 2, "name": "DP-2", "type": 11, "icon": "video-display",
+      "connected": true, "enabled": false, "priority": 0,
+      "pos": { "x": 0, "y": 0 },
+      "scale": 1, "rotation": 1,
+      "currentModeId": "",
+      "preferredModes": [ "6" ],
+      "modes": [
+        { "id": "4", "name": "2560x1440@60", "refreshRate": 59.951, "size": { "width": 2560, "height": 1440 } },
+        { "id": "5", "name": "2560x1440@144", "refreshRate": 143.912, "size": { "width": 2560, "height": 1440 } },
+        { "id": "6", "name": "3840x2160@60", "refreshRate": 59.997, "size": { "width": 3840, "height": 2160 } }
+      ],
+      "edid": { "vendor": "Dell Inc.", "name": "DELL U2720Q", "serial": "8LXMZ13" }
+    },
+    {
+      "id": 3, "name": "HDMI-A-1", "type": 10, "icon": "video-display",
+      "connected": false, "enabled": false, "priority": 0,
+      "pos": { "x": 0, "y": 0 },
+      "scale": 1, "rotation": 1,
+      "currentModeId": "",
+      "preferredModes": [],
+      "modes": []
+    }
+  ],
+  "screen": { "id": 0, "currentSize": { "width": 1280, "height": 720 } }
+}`
+
+func TestParseKScreen(t *testing.T) {
+	cfg, err := parseKScreen([]byte(kscreenDoctorOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Primary:   true,
+			Modes: Modes{
+				{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.008, 48.006}, ActiveRate: 60.008, DefaultRate: 60.008},
+				{Name: "1280x720", Rates: []float64{60}},
+			},
+			MonitorID: "CMN--",
+			Vendor:    "CMN",
+		},
+		{
+			Name:      "DP-2",
+			Connected: true,
+			Modes: Modes{
+				{Name: "2560x1440", Rates: []float64{59.951, 143.912}},
+				{Name: "3840x2160", Default: true, Rates: []float64{59.997}, DefaultRate: 59.997},
+			},
+			MonitorID: "Dell Inc.-DELL U2720Q-8LXMZ13",
+			Vendor:    "Dell Inc.",
+		},
+		{
+			Name: "HDMI-A-1",
+		},
+	}
+
+	outputs := cfg.Outputs()
+	if !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("wrong outputs returned:\n  want %v\n  got  %v", want, outputs)
+	}
+
+	if !reflect.DeepEqual(cfg.scales(), map[string]float64{"eDP-1": 1.5}) {
+		t.Fatalf("wrong scales returned: %v", cfg.scales())
+	}
+}
+
+func TestBuildKScreenCommand(t *testing.T) {
+	cfg, err := parseKScreen([]byte(kscreenDoctorOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		rule Rule
+		args []string
+	}{
+		{
+			Rule{ConfigureRow: []string{"eDP-1", "DP-2@2560x1440"}, Primary: "DP-2"},
+			[]string{"kscreen-doctor",
+				"output.eDP-1.enable", "output.eDP-1.mode.1", "output.eDP-1.position.0,0", "output.eDP-1.scale.1.5",
+				"output.DP-2.enable", "output.DP-2.mode.5", "output.DP-2.position.1280,0", "output.DP-2.priority.1",
+			},
+		},
+		{
+			Rule{ConfigureRow: []string{"eDP-1", "DP-2@2560x1440@60"}},
+			[]string{"kscreen-doctor",
+				"output.eDP-1.enable", "output.eDP-1.mode.1", "output.eDP-1.position.0,0", "output.eDP-1.scale.1.5",
+				"output.DP-2.enable", "output.DP-2.mode.4", "output.DP-2.position.1280,0",
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP-2"},
+			[]string{"kscreen-doctor",
+				"output.eDP-1.disable",
+				"output.DP-2.enable", "output.DP-2.mode.6", "output.DP-2.position.0,0",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			layout, err := ComputeScaledLayout(test.rule, cfg.Outputs(), cfg.scales())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd, err := BuildKScreenCommand(cfg, layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cmd.Args, test.args) {
+				t.Fatalf("wrong command returned:\n  want %q\n  got  %q", test.args, cmd.Args)
+			}
+		})
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// D-Bus names of the mutter display configuration interface.
+const (
+	mutterDest      = "org.gnome.Mutter.DisplayConfig"
+	mutterPath      = "/org/gnome/Mutter/DisplayConfig"
+	mutterInterface = "org.gnome.Mutter.DisplayConfig"
+)
+
+// Methods for ApplyMonitorsConfig.
+const (
+	mutterMethodTemporary  = 1
+	mutterMethodPersistent = 2
+)
+
+// mutterLayoutModePhysical is the value of the "layout-mode" property when
+// logical monitors are placed in physical pixels instead of scaled ones.
+const mutterLayoutModePhysical = 2
+
+// mutterMonitorSpec identifies a monitor.
+type mutterMonitorSpec struct {
+	Connector string
+	Vendor    string
+	Product   string
+	Serial    string
+}
+
+// mutterMode is a mode of a monitor as returned by GetCurrentState.
+type mutterMode struct {
+	ID              string
+	Width           int32
+	Height          int32
+	Refresh         float64
+	PreferredScale  float64
+	SupportedScales []float64
+	Properties      map[string]dbus.Variant
+}
+
+// mutterMonitor is a monitor as returned by GetCurrentState.
+type mutterMonitor struct {
+	Spec       mutterMonitorSpec
+	Modes      []mutterMode
+	Properties map[string]dbus.Variant
+}
+
+// mutterLogicalMonitor is a logical monitor as returned by GetCurrentState.
+type mutterLogicalMonitor struct {
+	X          int32
+	Y          int32
+	Scale      float64
+	Transform  uint32
+	Primary    bool
+	Monitors   []mutterMonitorSpec
+	Properties map[string]dbus.Variant
+}
+
+// mutterState is the reply of GetCurrentState.
+type mutterState struct {
+	Serial          uint32
+	Monitors        []mutterMonitor
+	LogicalMonitors []mutterLogicalMonitor
+	Properties      map[string]dbus.Variant
+}
+
+// mutterApplyMonitor is a monitor passed to ApplyMonitorsConfig.
+type mutterApplyMonitor struct {
+	Connector  string
+	ModeID     string
+	Properties map[string]dbus.Variant
+}
+
+// mutterApplyLogicalMonitor is a logical monitor passed to
+// ApplyMonitorsConfig.
+type mutterApplyLogicalMonitor struct {
+	X         int32
+	Y         int32
+	Scale     float64
+	Transform uint32
+	Primary   bool
+	Monitors  []mutterApplyMonitor
+}
+
+// boolProperty returns the named boolean property, which is false if not set.
+func boolProperty(props map[string]dbus.Variant, name string) bool {
+	v, ok := props[name]
+	if !ok {
+		return false
+	}
+
+	b, ok := v.Value().(bool)
+	return ok && b
+}
+
+// Outputs converts the monitors to grobi's representation.
+func (s mutterState) Outputs() Outputs {
+	primary := make(map[string]bool)
+	for _, lm := range s.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			primary[spec.Connector] = lm.Primary
+		}
+	}
+
+	transforms := s.transforms()
+
+	outputs := make(Outputs, 0, len(s.Monitors))
+	for _, m := range s.Monitors {
+		output := Output{
+			Name:      m.Spec.Connector,
+			Connected: true,
+			Primary:   primary[m.Spec.Connector],
+			MonitorID: waylandMonitorID(m.Spec.Vendor, m.Spec.Product, m.Spec.Serial),
+			Vendor:    waylandVendor(m.Spec.Vendor),
+		}
+
+		// the rotation is needed to place rotated monitors, reflected ones
+		// use the same transforms plus four
+		if t, ok := transforms[m.Spec.Connector]; ok {
+			output.Rotation = []string{"normal", "left", "inverted", "right"}[t%4]
+		}
+
+		for _, mode := range m.Modes {
+			addWaylandMode(&output, fmt.Sprintf("%dx%d", mode.Width, mode.Height), mode.Refresh,
+				boolProperty(mode.Properties, "is-current"),
+				boolProperty(mode.Properties, "is-preferred"))
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	return outputs
+}
+
+// transforms returns the transform of each active monitor.
+func (s mutterState) transforms() map[string]uint32 {
+	transforms := make(map[string]uint32)
+	for _, lm := range s.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			transforms[spec.Connector] = lm.Transform
+		}
+	}
+	return transforms
+}
+
+// scales returns the scale factor of each active monitor, or nil if mutter
+// places logical monitors in physical pixels.
+func (s mutterState) scales() map[string]float64 {
+	if v, ok := s.Properties["layout-mode"]; ok {
+		if mode, ok := v.Value().(uint32); ok && mode == mutterLayoutModePhysical {
+			return nil
+		}
+	}
+
+	scales := make(map[string]float64)
+	for _, lm := range s.LogicalMonitors {
+		for _, spec := range lm.Monitors {
+			if lm.Scale > 0 {
+				scales[spec.Connector] = lm.Scale
+			}
+		}
+	}
+
+	return scales
+}
+
+// findMode returns the mode with the given name ("1920x1080") and refresh
+// rate of the monitor, see selectWaylandMode.
+func (m mutterMonitor) findMode(name string, rate float64) (mutterMode, bool) {
+	list := make([]waylandMode, 0, len(m.Modes))
+	for _, mode := range m.Modes {
+		list = append(list, waylandMode{
+			Name:      fmt.Sprintf("%dx%d", mode.Width, mode.Height),
+			Rate:      mode.Refresh,
+			Current:   boolProperty(mode.Properties, "is-current"),
+			Preferred: boolProperty(mode.Properties, "is-preferred"),
+		})
+	}
+
+	i, ok := selectWaylandMode(list, name, rate)
+	if !ok {
+		return mutterMode{}, false
+	}
+	return m.Modes[i], true
+}
+
+// BuildMutterConfig returns the logical monitors which configure layout.
+// Monitors which are not listed are switched off by mutter, active ones keep
+// their transform.
+func BuildMutterConfig(state mutterState, layout Layout) ([]mutterApplyLogicalMonitor, error) {
+	monitors := make(map[string]mutterMonitor)
+	for _, m := range state.Monitors {
+		monitors[m.Spec.Connector] = m
+	}
+
+	scales := state.scales()
+	transforms := state.transforms()
+
+	var config []mutterApplyLogicalMonitor
+	for _, out := range layout.Outputs {
+		m, ok := monitors[out.Name]
+		if !ok {
+			return nil, fmt.Errorf("monitor %v not found", out.Name)
+		}
+
+		mode, ok := m.findMode(out.Mode, out.Rate)
+		if !ok {
+			return nil, fmt.Errorf("monitor %v: mode %v not found", out.Name, out.Mode)
+		}
+
+		scale := 1.0
+		if s, ok := scales[out.Name]; ok {
+			scale = s
+		}
+
+		config = append(config, mutterApplyLogicalMonitor{
+			X:         int32(out.X),
+			Y:         int32(out.Y),
+			Scale:     scale,
+			Transform: transforms[out.Name],
+			Primary:   out.Primary,
+			Monitors: []mutterApplyMonitor{
+				{Connector: out.Name, ModeID: mode.ID, Properties: map[string]dbus.Variant{}},
+			},
+		})
+	}
+
+	ensureMutterPrimary(config)
+
+	return config, nil
+}
+
+// BuildMutterDisableConfig returns the current logical monitors without the
+// monitors in off.
+func BuildMutterDisableConfig(state mutterState, off Outputs) ([]mutterApplyLogicalMonitor, error) {
+	disable := make(map[string]struct{})
+	for _, output := range off {
+		disable[output.Name] = struct{}{}
+	}
+
+	current := make(map[string]mutterMonitor)
+	for _, m := range state.Monitors {
+		current[m.Spec.Connector] = m
+	}
+
+	var config []mutterApplyLogicalMonitor
+	for _, lm := range state.LogicalMonitors {
+		alm := mutterApplyLogicalMonitor{
+			X:         lm.X,
+			Y:         lm.Y,
+			Scale:     lm.Scale,
+			Transform: lm.Transform,
+			Primary:   lm.Primary,
+		}
+
+		for _, spec := range lm.Monitors {
+			if _, ok := disable[spec.Connector]; ok {
+				continue
+			}
+
+			var modeID string
+			for _, mode := range current[spec.Connector].Modes {
+				if boolProperty(mode.Properties, "is-current") {
+					modeID = mode.ID
+				}
+			}
+
+			alm.Monitors = append(alm.Monitors, mutterApplyMonitor{
+				Connector:  spec.Connector,
+				ModeID:     modeID,
+				Properties: map[string]dbus.Variant{},
+			})
+		}
+
+		if len(alm.Monitors) > 0 {
+			config = append(config, alm)
+		}
+	}
+
+	if len(config) == 0 {
+		return nil, errors.New("refusing to disable all monitors")
+	}
+
+	ensureMutterPrimary(config)
+
+	return config, nil
+}
+
+// ensureMutterPrimary makes the first logical monitor primary if none is,
+// mutter requires exactly one primary logical monitor.
+func ensureMutterPrimary(config []mutterApplyLogicalMonitor) {
+	for _, lm := range config {
+		if lm.Primary {
+			return
+		}
+	}
+
+	if len(config) > 0 {
+		config[0].Primary = true
+	}
+}
+
+// mutterBackend configures monitors via the DisplayConfig D-Bus interface of
+// mutter, which is used by GNOME.
+type mutterBackend struct {
+	mu   sync.Mutex
+	conn *dbus.Conn
+}
+
+func init() {
+	registerBackend("mutter", func() (Backend, error) {
+		return &mutterBackend{}, nil
+	})
+}
+
+// mutterMatchOptions select the MonitorsChanged signal.
+var mutterMatchOptions = []dbus.MatchOption{
+	dbus.WithMatchObjectPath(mutterPath),
+	dbus.WithMatchInterface(mutterInterface),
+	dbus.WithMatchMember("MonitorsChanged"),
+}
+
+// connect returns the connection to the session bus, it is established when
+// needed.
+func (b *mutterBackend) connect() (*dbus.Conn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			return nil, fmt.Errorf("connecting to session bus: %w", err)
+		}
+		b.conn = conn
+	}
+
+	return b.conn, nil
+}
+
+// disconnect closes conn, the next call connects to the session bus again.
+func (b *mutterBackend) disconnect(conn *dbus.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == conn {
+		b.conn = nil
+	}
+	_ = conn.Close()
+}
+
+// checkConn returns err. If the connection to the session bus was closed, it
+// is dropped and errConnectionLost is returned instead.
+func (b *mutterBackend) checkConn(conn *dbus.Conn, err error) error {
+	if err == nil || conn.Connected() {
+		return err
+	}
+
+	b.disconnect(conn)
+	return fmt.Errorf("session bus: %w", errConnectionLost)
+}
+
+// currentState calls GetCurrentState.
+func (b *mutterBackend) currentState() (mutterState, error) {
+	conn, err := b.connect()
+	if err != nil {
+		return mutterState{}, err
+	}
+
+	var state mutterState
+	err = conn.Object(mutterDest, mutterPath).
+		Call(mutterInterface+".GetCurrentState", 0).
+		Store(&state.Serial, &state.Monitors, &state.LogicalMonitors, &state.Properties)
+	if err != nil {
+		return mutterState{}, fmt.Errorf("GetCurrentState: %w", b.checkConn(conn, err))
+	}
+
+	return state, nil
+}
+
+// Outputs returns the monitors mutter knows about.
+func (b *mutterBackend) Outputs() (Outputs, error) {
+	state, err := b.currentState()
+	if err != nil {
+		return nil, err
+	}
+
+	return state.Outputs(), nil
+}
+
+// Detect returns the monitors, mutter probes for changes on its own.
+func (b *mutterBackend) Detect() (Outputs, error) {
+	return b.Outputs()
+}
+
+// apply calls ApplyMonitorsConfig, or prints the configuration if --dry-run
+// is set.
+func (b *mutterBackend) apply(serial uint32, config []mutterApplyLogicalMonitor) error {
+	method := uint32(mutterMethodTemporary)
+	if globalOpts.cfg != nil && globalOpts.cfg.MutterPersistent {
+		method = mutterMethodPersistent
+	}
+
+	if globalOpts.DryRun {
+		fmt.Printf("ApplyMonitorsConfig serial %d method %d\n", serial, method)
+		for _, lm := range config {
+			for _, m := range lm.Monitors {
+				fmt.Printf("  %s mode %s at %d,%d scale %v primary %v\n",
+					m.Connector, m.ModeID, lm.X, lm.Y, lm.Scale, lm.Primary)
+			}
+		}
+		return nil
+	}
+
+	V("applying monitor config %v\n", config)
+
+	conn, err := b.connect()
+	if err != nil {
+		return err
+	}
+
+	err = conn.Object(mutterDest, mutterPath).
+		Call(mutterInterface+".ApplyMonitorsConfig", 0,
+			serial, method, config, map[string]dbus.Variant{}).Err
+	if err != nil {
+		return fmt.Errorf("ApplyMonitorsConfig: %w", b.checkConn(conn, err))
+	}
+
+	return nil
+}
+
+// Apply configures the monitors for rule with ApplyMonitorsConfig.
+func (b *mutterBackend) Apply(rule Rule, current Outputs) error {
+	state, err := b.currentState()
+	if err != nil {
+		return err
+	}
+
+	layout, err := ComputeScaledLayout(rule, state.Outputs(), state.scales())
+	if err != nil {
+		return err
+	}
+
+	config, err := BuildMutterConfig(state, layout)
+	if err != nil {
+		return err
+	}
+
+	return b.apply(state.Serial, config)
+}
+
+// Disable switches off the monitors by applying the current configuration
+// without them.
+func (b *mutterBackend) Disable(off Outputs) error {
+	state, err := b.currentState()
+	if err != nil {
+		return err
+	}
+
+	config, err := BuildMutterDisableConfig(state, off)
+	if err != nil {
+		return err
+	}
+
+	return b.apply(state.Serial, config)
+}
+
+// Subscribe forwards the MonitorsChanged signal.
+func (b *mutterBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	conn, err := b.connect()
+	if err != nil {
+		return err
+	}
+
+	err = conn.AddMatchSignal(mutterMatchOptions...)
+	if err != nil {
+		return fmt.Errorf("subscribing to MonitorsChanged: %w", b.checkConn(conn, err))
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		// the match is added again when subscribing anew
+		defer func() {
+			conn.RemoveSignal(signals)
+			if conn.Connected() {
+				_ = conn.RemoveMatchSignal(mutterMatchOptions...)
+			}
+		}()
+
+		for {
+			select {
+			case sig, ok := <-signals:
+				var ev Event
+				if !ok {
+					// the connection is closed, the next call connects again
+					b.disconnect(conn)
+					ev.Error = fmt.Errorf("session bus closed: %w", errConnectionLost)
+				} else if sig.Name != mutterInterface+".MonitorsChanged" {
+					continue
+				} else {
+					ev.Event = sig.Name
+				}
+
+				select {
+				case ch <- ev:
+				case <-done:
+					return
+				}
+
+				if !ok {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
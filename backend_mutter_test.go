@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const dbusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:tmpdir=DIR</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startDBus runs a private dbus-daemon and points the session bus address to
+// it. The test is skipped if dbus-daemon is not installed.
+func startDBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir, err := ioutil.TempDir("", "grobi-test-dbus-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	config := filepath.Join(dir, "session.conf")
+	err = ioutil.WriteFile(config, []byte(strings.Replace(dbusConfig, "DIR", dir, 1)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	address = strings.TrimSpace(address)
+
+	setenv(t, "DBUS_SESSION_BUS_ADDRESS", address)
+	return address
+}
+
+// stubMutter implements the parts of org.gnome.Mutter.DisplayConfig used by
+// grobi.
+type stubMutter struct {
+	mu      sync.Mutex
+	applied [][]mutterApplyLogicalMonitor
+	methods []uint32
+}
+
+func mutterModeProps(current, preferred bool) map[string]dbus.Variant {
+	props := make(map[string]dbus.Variant)
+	if current {
+		props["is-current"] = dbus.MakeVariant(true)
+	}
+	if preferred {
+		props["is-preferred"] = dbus.MakeVariant(true)
+	}
+	return props
+}
+
+func (s *stubMutter) GetCurrentState() (uint32, []mutterMonitor, []mutterLogicalMonitor, map[string]dbus.Variant, *dbus.Error) {
+	monitors := []mutterMonitor{
+		{
+			Spec: mutterMonitorSpec{Connector: "eDP-1", Vendor: "CMN", Product: "0x14d4", Serial: "0x00000000"},
+			Modes: []mutterMode{
+				{ID: "1920x1080@60.008", Width: 1920, Height: 1080, Refresh: 60.008, PreferredScale: 1, SupportedScales: []float64{1, 2}, Properties: mutterModeProps(true, true)},
+				{ID: "1920x1080@48.006", Width: 1920, Height: 1080, Refresh: 48.006, PreferredScale: 1, SupportedScales: []float64{1, 2}, Properties: mutterModeProps(false, false)},
+			},
+			Properties: map[string]dbus.Variant{"is-builtin": dbus.MakeVariant(true)},
+		},
+		{
+			Spec: mutterMonitorSpec{Connector: "DP-1", Vendor: "SAM", Product: "S24C350", Serial: "H9XZ305118"},
+			Modes: []mutterMode{
+				{ID: "1920x1080@60.000", Width: 1920, Height: 1080, Refresh: 60, PreferredScale: 1, SupportedScales: []float64{1}, Properties: mutterModeProps(false, true)},
+				{ID: "1280x1024@75.025", Width: 1280, Height: 1024, Refresh: 75.025, PreferredScale: 1, SupportedScales: []float64{1}, Properties: mutterModeProps(false, false)},
+			},
+			Properties: map[string]dbus.Variant{},
+		},
+	}
+
+	logical := []mutterLogicalMonitor{
+		{
+			Scale:      2,
+			Primary:    true,
+			Monitors:   []mutterMonitorSpec{monitors[0].Spec},
+			Properties: map[string]dbus.Variant{},
+		},
+	}
+
+	props := map[string]dbus.Variant{"layout-mode": dbus.MakeVariant(uint32(1))}
+
+	return 23, monitors, logical, props, nil
+}
+
+func (s *stubMutter) ApplyMonitorsConfig(serial uint32, method uint32, logical []mutterApplyLogicalMonitor, props map[string]dbus.Variant) *dbus.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if serial != 23 {
+		return dbus.MakeFailedError(errors.New("wrong serial"))
+	}
+
+	s.applied = append(s.applied, logical)
+	s.methods = append(s.methods, method)
+	return nil
+}
+
+// startStubMutter exports the stub on a private session bus.
+func startStubMutter(t *testing.T) (*stubMutter, *dbus.Conn) {
+	address := startDBus(t)
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	stub := &stubMutter{}
+	err = conn.Export(stub, mutterPath, mutterInterface)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := conn.RequestName(mutterDest, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("unable to acquire name, reply %v", reply)
+	}
+
+	return stub, conn
+}
+
+func TestMutterOutputs(t *testing.T) {
+	startStubMutter(t)
+
+	outputs, err := (&mutterBackend{}).Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Primary:   true,
+			Modes:     Modes{{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.008, 48.006}, ActiveRate: 60.008, DefaultRate: 60.008}},
+			MonitorID: "CMN-0x14d4-0x00000000",
+			Vendor:    "CMN",
+			Rotation:  "normal",
+		},
+		{
+			Name:      "DP-1",
+			Connected: true,
+			Modes: Modes{
+				{Name: "1920x1080", Default: true, Rates: []float64{60}, DefaultRate: 60},
+				{Name: "1280x1024", Rates: []float64{75.025}},
+			},
+			MonitorID: "SAM-S24C350-H9XZ305118",
+			Vendor:    "SAM",
+		},
+	}
+
+	if !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("wrong outputs returned:\n  want %v\n  got  %v", want, outputs)
+	}
+}
+
+func TestMutterApply(t *testing.T) {
+	stub, _ := startStubMutter(t)
+
+	b := &mutterBackend{}
+	outputs, err := b.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"eDP-1@1920x1080@48", "DP-1@1280x1024"},
+		Primary:      "DP-1",
+	}
+
+	err = b.Apply(rule, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []mutterApplyLogicalMonitor{
+		{
+			Scale: 2,
+			Monitors: []mutterApplyMonitor{
+				{Connector: "eDP-1", ModeID: "1920x1080@48.006", Properties: map[string]dbus.Variant{}},
+			},
+		},
+		{
+			X:       960,
+			Scale:   1,
+			Primary: true,
+			Monitors: []mutterApplyMonitor{
+				{Connector: "DP-1", ModeID: "1280x1024@75.025", Properties: map[string]dbus.Variant{}},
+			},
+		},
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	if len(stub.applied) != 1 {
+		t.Fatalf("expected one call to ApplyMonitorsConfig, got %d", len(stub.applied))
+	}
+
+	if !reflect.DeepEqual(stub.applied[0], want) {
+		t.Fatalf("wrong config applied:\n  want %+v\n  got  %+v", want, stub.applied[0])
+	}
+
+	if stub.methods[0] != mutterMethodTemporary {
+		t.Fatalf("wrong method used: %v", stub.methods[0])
+	}
+}
+
+// rotatedMutterState returns a state with eDP-1 as the primary monitor and
+// DP-1 rotated to the right of it.
+func rotatedMutterState() mutterState {
+	stub := &stubMutter{}
+	serial, monitors, _, props, _ := stub.GetCurrentState()
+
+	return mutterState{
+		Serial:   serial,
+		Monitors: monitors,
+		LogicalMonitors: []mutterLogicalMonitor{
+			{Scale: 1, Primary: true, Monitors: []mutterMonitorSpec{monitors[0].Spec}},
+			{X: 1920, Scale: 1, Transform: 3, Monitors: []mutterMonitorSpec{monitors[1].Spec}},
+		},
+		Properties: props,
+	}
+}
+
+func TestBuildMutterConfigTransform(t *testing.T) {
+	state := rotatedMutterState()
+
+	outputs := state.Outputs()
+	if outputs[1].Rotation != "right" {
+		t.Fatalf("wrong rotation for DP-1: %q", outputs[1].Rotation)
+	}
+
+	layout, err := ComputeScaledLayout(Rule{ConfigureRow: []string{"DP-1", "eDP-1"}}, outputs, state.scales())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := BuildMutterConfig(state, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []mutterApplyLogicalMonitor{
+		{
+			Scale:     1,
+			Transform: 3,
+			Primary:   true,
+			Monitors: []mutterApplyMonitor{
+				{Connector: "DP-1", ModeID: "1920x1080@60.000", Properties: map[string]dbus.Variant{}},
+			},
+		},
+		{
+			X:     1080,
+			Scale: 1,
+			Monitors: []mutterApplyMonitor{
+				{Connector: "eDP-1", ModeID: "1920x1080@60.008", Properties: map[string]dbus.Variant{}},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("wrong config returned:\n  want %+v\n  got  %+v", want, config)
+	}
+}
+
+func TestBuildMutterDisableConfig(t *testing.T) {
+	state := rotatedMutterState()
+
+	config, err := BuildMutterDisableConfig(state, Outputs{{Name: "eDP-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []mutterApplyLogicalMonitor{
+		{
+			X:         1920,
+			Scale:     1,
+			Transform: 3,
+			Primary:   true,
+			Monitors: []mutterApplyMonitor{
+				{Connector: "DP-1", Properties: map[string]dbus.Variant{}},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("wrong config returned:\n  want %+v\n  got  %+v", want, config)
+	}
+
+	_, err = BuildMutterDisableConfig(state, Outputs{{Name: "eDP-1"}, {Name: "DP-1"}})
+	if err == nil {
+		t.Fatal("expected error for disabling all monitors not returned")
+	}
+}
+
+func TestMutterSubscribe(t *testing.T) {
+	_, conn := startStubMutter(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	err := (&mutterBackend{}).Subscribe(ch, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Emit(mutterPath, mutterInterface+".MonitorsChanged")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-ch:
+		if ev.Error != nil {
+			t.Fatal(ev.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
+
+func TestMutterCheckConn(t *testing.T) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	conn, err := dbus.NewConn(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &mutterBackend{conn: conn}
+
+	callErr := errors.New("call failed")
+	if err := b.checkConn(conn, callErr); err != callErr || b.conn != conn {
+		t.Fatalf("error on open connection changed: %v", err)
+	}
+
+	_ = conn.Close()
+
+	err = b.checkConn(conn, dbus.ErrClosed)
+	if !errors.Is(err, errConnectionLost) {
+		t.Fatalf("wrong error returned for closed connection: %v", err)
+	}
+
+	if b.conn != nil {
+		t.Fatal("closed connection not dropped")
+	}
+}
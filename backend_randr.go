@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// randrBackend queries and configures outputs via the RANDR extension
+// directly. It falls back to running `xrandr` when the X server cannot be
+// reached.
+type randrBackend struct {
+	xrandr xrandrBackend
+	conn   *RandrConn
+
+	// cache holds the outputs from the last native query, change events are
+	// applied to it.
+	mu    sync.Mutex
+	cache *randrCache
+}
+
+func init() {
+	registerBackend("randr", func() (Backend, error) {
+		return &randrBackend{}, nil
+	})
+}
+
+// connect returns the connection to the X server, it is established when
+// needed. A lost connection is replaced by a new one.
+func (b *randrBackend) connect() (*RandrConn, error) {
+	if b.conn != nil && b.conn.Lost() {
+		V("connection to the X server lost, reconnecting\n")
+		b.conn.Close()
+		b.conn = nil
+
+		// the IDs in the cache are not valid for the new connection
+		b.mu.Lock()
+		b.cache = nil
+		b.mu.Unlock()
+	}
+
+	if b.conn == nil {
+		c, err := NewRandrConn()
+		if err != nil {
+			return nil, err
+		}
+		b.conn = c
+	}
+
+	return b.conn, nil
+}
+
+// errMultipleScreens is returned by native queries when the X server has more
+// than one screen, only `xrandr` queries all of them.
+var errMultipleScreens = errors.New("more than one X screen present")
+
+// outputs queries the outputs via the RANDR extension.
+func (b *randrBackend) outputs(detect bool) (Outputs, error) {
+	c, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Screens > 1 {
+		return nil, errMultipleScreens
+	}
+
+	cache, err := c.query(detect)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.cache = cache
+	b.mu.Unlock()
+
+	return cache.Outputs().MergeTiles(), nil
+}
+
+// Outputs queries the RANDR extension for the current outputs, it falls back
+// to running `xrandr` when that fails for other reasons than a lost
+// connection.
+func (b *randrBackend) Outputs() (Outputs, error) {
+	outputs, err := b.outputs(false)
+	if err == nil || errors.Is(err, errConnectionLost) {
+		return outputs, err
+	}
+
+	V("native RANDR query failed, falling back to xrandr: %v\n", err)
+	return b.xrandr.Outputs()
+}
+
+// Detect rescans the outputs via the RANDR extension and returns them, it
+// falls back to running `xrandr` when that fails for other reasons than a
+// lost connection.
+func (b *randrBackend) Detect() (Outputs, error) {
+	outputs, err := b.outputs(true)
+	if err == nil || errors.Is(err, errConnectionLost) {
+		return outputs, err
+	}
+
+	V("native RANDR query failed, falling back to xrandr: %v\n", err)
+	return b.xrandr.Detect()
+}
+
+// DetailedOutputs runs `xrandr --verbose`, the native query does not collect
+// the details yet.
+func (b *randrBackend) DetailedOutputs() (Outputs, error) {
+	return b.xrandr.DetailedOutputs()
+}
+
+// Screens queries the size limits of the screen via the RANDR extension, it
+// falls back to running `xrandr` when that fails.
+func (b *randrBackend) Screens() (Screens, error) {
+	screen, err := b.screen()
+	if err == nil {
+		return Screens{screen}, nil
+	}
+
+	V("native RANDR query failed, falling back to xrandr: %v\n", err)
+	return b.xrandr.Screens()
+}
+
+// screen queries the size limits of the screen via the RANDR extension.
+func (b *randrBackend) screen() (Screen, error) {
+	c, err := b.connect()
+	if err != nil {
+		return Screen{}, err
+	}
+
+	if c.Screens > 1 {
+		return Screen{}, errMultipleScreens
+	}
+
+	return c.screen()
+}
+
+// Apply configures the outputs for rule. Atomic rules are applied in one step
+// via the RANDR extension, all others by running `xrandr`.
+func (b *randrBackend) Apply(rule Rule, current Outputs) error {
+	if !rule.Atomic {
+		return b.xrandr.Apply(rule, current)
+	}
+
+	c, err := b.connect()
+	if err != nil {
+		V("native RANDR connection failed, falling back to xrandr: %v\n", err)
+		return b.xrandr.Apply(rule, current)
+	}
+
+	if c.Screens > 1 {
+		V("%v, falling back to xrandr\n", errMultipleScreens)
+		return b.xrandr.Apply(rule, current)
+	}
+
+	layout, err := ComputeLayout(rule, current)
+	if err != nil {
+		return err
+	}
+
+	V("applying layout atomically via RANDR\n")
+	return c.ApplyLayout(layout)
+}
+
+// Disable switches off the outputs by running `xrandr`.
+func (b *randrBackend) Disable(off Outputs) error {
+	return b.xrandr.Disable(off)
+}
+
+// Monitors runs `xrandr --listmonitors`.
+func (b *randrBackend) Monitors() (Monitors, error) {
+	return b.xrandr.Monitors()
+}
+
+// SetMonitors runs `xrandr` to remove and create the monitors, the RANDR
+// bindings lack the monitor requests.
+func (b *randrBackend) SetMonitors(add Monitors, remove []string) error {
+	return b.xrandr.SetMonitors(add, remove)
+}
+
+// Providers runs `xrandr --listproviders`.
+func (b *randrBackend) Providers() (Providers, error) {
+	return b.xrandr.Providers()
+}
+
+// LinkProvider runs `xrandr --setprovideroutputsource`.
+func (b *randrBackend) LinkProvider(sink, source Provider) error {
+	return b.xrandr.LinkProvider(sink, source)
+}
+
+// Subscribe forwards RANDR change events received on the connection also
+// used for queries. The events are applied to the outputs of the last query,
+// only changes which cannot be applied need the outputs to be queried again.
+func (b *randrBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	c, err := b.connect()
+	if err != nil {
+		return err
+	}
+
+	err = selectRandrEvents(c)
+	if err != nil {
+		return err
+	}
+
+	go b.forwardChanges(c, ch, done)
+	return nil
+}
+
+// update applies change to the cache. It returns the new list of outputs, or
+// nil if the outputs need to be queried again. Changes which do not modify
+// the outputs are reported with ok set to false.
+func (b *randrBackend) update(change RandrChange) (outputs Outputs, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cache == nil {
+		return nil, true
+	}
+
+	changed, applied := b.cache.Apply(change)
+	if !applied {
+		V("RANDR change %v cannot be applied, querying outputs again\n", change)
+		return nil, true
+	}
+
+	if !changed {
+		return nil, false
+	}
+
+	return b.cache.Outputs().MergeTiles(), true
+}
+
+// forwardChanges decodes the RANDR events received on the connection and
+// sends the changes to ch until done is closed or the connection is lost,
+// which is reported as an error.
+func (b *randrBackend) forwardChanges(c *RandrConn, ch chan<- Event, done <-chan struct{}) {
+	for {
+		xev, err := c.waitForEvent()
+		if xev == nil && err == nil {
+			return
+		}
+
+		ev := Event{Event: xev, Error: err}
+		if err == nil {
+			change, ok := decodeRandrEvent(xev)
+			if !ok {
+				continue
+			}
+
+			outputs, ok := b.update(change)
+			if !ok {
+				V("ignoring RANDR change %v\n", change)
+				continue
+			}
+
+			ev = Event{Event: change, Outputs: outputs}
+		}
+
+		select {
+		case ch <- ev:
+		case <-done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
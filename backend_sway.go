@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message types of the sway/i3 IPC protocol.
+const (
+	swayRunCommand = 0
+	swaySubscribe  = 2
+	swayGetOutputs = 3
+
+	// swayEventOutput is sent when an output was added, removed or changed.
+	swayEventOutput = 0x80000007
+)
+
+// swayMagic starts every message sent over the IPC socket.
+const swayMagic = "i3-ipc"
+
+// swayConn is a connection to the sway IPC socket.
+type swayConn struct {
+	conn net.Conn
+}
+
+// dialSway connects to the IPC socket named in $SWAYSOCK.
+func dialSway() (*swayConn, error) {
+	path := os.Getenv("SWAYSOCK")
+	if path == "" {
+		return nil, errors.New("SWAYSOCK is not set, is sway running?")
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &swayConn{conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *swayConn) Close() {
+	_ = c.conn.Close()
+}
+
+// send writes a message to the socket.
+func (c *swayConn) send(typ uint32, payload []byte) error {
+	buf := make([]byte, len(swayMagic)+8+len(payload))
+	copy(buf, swayMagic)
+	binary.LittleEndian.PutUint32(buf[len(swayMagic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[len(swayMagic)+4:], typ)
+	copy(buf[len(swayMagic)+8:], payload)
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// receive reads the next message or event from the socket.
+func (c *swayConn) receive() (typ uint32, payload []byte, err error) {
+	header := make([]byte, len(swayMagic)+8)
+	_, err = io.ReadFull(c.conn, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if string(header[:len(swayMagic)]) != swayMagic {
+		return 0, nil, fmt.Errorf("invalid magic %q received", header[:len(swayMagic)])
+	}
+
+	length := binary.LittleEndian.Uint32(header[len(swayMagic):])
+	typ = binary.LittleEndian.Uint32(header[len(swayMagic)+4:])
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.conn, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return typ, payload, nil
+}
+
+// request sends a message and decodes the reply into result.
+func (c *swayConn) request(typ uint32, payload []byte, result interface{}) error {
+	err := c.send(typ, payload)
+	if err != nil {
+		return err
+	}
+
+	rtyp, reply, err := c.receive()
+	if err != nil {
+		return err
+	}
+
+	if rtyp != typ {
+		return fmt.Errorf("unexpected reply type %d for request type %d", rtyp, typ)
+	}
+
+	return json.Unmarshal(reply, result)
+}
+
+// swayMode is a mode as reported by sway, the refresh rate is in mHz.
+type swayMode struct {
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+	Refresh int `json:"refresh"`
+}
+
+// Name returns the mode name in the form xrandr uses.
+func (m swayMode) Name() string {
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+}
+
+// Rate returns the refresh rate in Hz.
+func (m swayMode) Rate() float64 {
+	return float64(m.Refresh) / 1000
+}
+
+// swayOutput is an output as returned by get_outputs.
+type swayOutput struct {
+	Name    string `json:"name"`
+	Make    string `json:"make"`
+	Model   string `json:"model"`
+	Serial  string `json:"serial"`
+	Active  bool   `json:"active"`
+	Primary bool   `json:"primary"`
+	Rect    struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"rect"`
+	Scale       float64    `json:"scale"`
+	Transform   string     `json:"transform"`
+	Modes       []swayMode `json:"modes"`
+	CurrentMode swayMode   `json:"current_mode"`
+}
+
+// waylandMonitorID returns the monitor ID for the make, model and serial
+// number reported by a Wayland compositor. Components which are unknown are
+// left empty.
+func waylandMonitorID(vendor, model, serial string) string {
+	for _, s := range []*string{&vendor, &model, &serial} {
+		if *s == "Unknown" {
+			*s = ""
+		}
+	}
+
+	if vendor == "" && model == "" && serial == "" {
+		return ""
+	}
+
+	return strings.Join([]string{vendor, model, serial}, "-")
+}
+
+// waylandVendor returns the vendor for the make reported by a Wayland
+// compositor, it is empty if the make is unknown.
+func waylandVendor(make string) string {
+	if make == "Unknown" {
+		return ""
+	}
+	return make
+}
+
+// waylandRotation returns the rotation for an output transform as reported by
+// sway and wlr-randr, e.g. "90" or "flipped-270". Flipping does not change
+// the size of the output and is ignored.
+func waylandRotation(transform string) string {
+	switch strings.TrimPrefix(strings.TrimPrefix(transform, "flipped"), "-") {
+	case "90":
+		return "left"
+	case "180":
+		return "inverted"
+	case "270":
+		return "right"
+	}
+	return "normal"
+}
+
+// addWaylandMode adds a mode reported by a Wayland compositor to output and
+// returns it. Compositors list each combination of size and refresh rate,
+// modes sharing a name are collapsed into one with all rates.
+func addWaylandMode(output *Output, name string, rate float64, current, preferred bool) *Mode {
+	for i := range output.Modes {
+		if output.Modes[i].Name == name {
+			output.Modes[i].addRate(rate, current, preferred)
+			return &output.Modes[i]
+		}
+	}
+
+	output.Modes = append(output.Modes, Mode{Name: name})
+	mode := &output.Modes[len(output.Modes)-1]
+	mode.addRate(rate, current, preferred)
+	return mode
+}
+
+// waylandMode describes a mode offered by a Wayland compositor, see
+// selectWaylandMode.
+type waylandMode struct {
+	Name      string
+	Rate      float64
+	Current   bool
+	Preferred bool
+}
+
+// selectWaylandMode returns the index of the mode with the given name
+// ("1920x1080"). If rate is not zero, the mode with the closest refresh rate
+// is used. Otherwise the current mode is preferred, then a preferred mode,
+// then the one with the highest refresh rate.
+func selectWaylandMode(modes []waylandMode, name string, rate float64) (int, bool) {
+	var found []int
+	for i, mode := range modes {
+		if mode.Name == name {
+			found = append(found, i)
+		}
+	}
+
+	if len(found) == 0 {
+		return 0, false
+	}
+
+	if rate != 0 {
+		best := found[0]
+		for _, i := range found[1:] {
+			if math.Abs(modes[i].Rate-rate) < math.Abs(modes[best].Rate-rate) {
+				best = i
+			}
+		}
+		return best, true
+	}
+
+	for _, i := range found {
+		if modes[i].Current {
+			return i, true
+		}
+	}
+
+	for _, i := range found {
+		if modes[i].Preferred {
+			return i, true
+		}
+	}
+
+	best := found[0]
+	for _, i := range found[1:] {
+		if modes[i].Rate > modes[best].Rate {
+			best = i
+		}
+	}
+
+	return best, true
+}
+
+// Output converts the output to grobi's representation. Sway does not report
+// preferred modes, so none is marked as default and the first one is used for
+// --auto.
+func (o swayOutput) Output() Output {
+	output := Output{
+		Name:      o.Name,
+		Connected: true,
+		Primary:   o.Primary,
+		MonitorID: waylandMonitorID(o.Make, o.Model, o.Serial),
+		Vendor:    waylandVendor(o.Make),
+		Rotation:  waylandRotation(o.Transform),
+	}
+
+	for _, m := range o.Modes {
+		addWaylandMode(&output, m.Name(), m.Rate(), o.Active && m == o.CurrentMode, false)
+	}
+
+	// the current mode may be a custom one which is not in the list
+	if o.Active && !output.Active() {
+		mode := Mode{Name: o.CurrentMode.Name()}
+		mode.addRate(o.CurrentMode.Rate(), true, false)
+		output.Modes = append(output.Modes, mode)
+	}
+
+	return output
+}
+
+// swayBackend configures outputs via the sway IPC socket.
+type swayBackend struct {
+	// scales holds the scale factor of each output from the last query.
+	scales map[string]float64
+}
+
+func init() {
+	registerBackend("sway", func() (Backend, error) {
+		return &swayBackend{}, nil
+	})
+}
+
+// getOutputs runs get_outputs via IPC.
+func (b *swayBackend) getOutputs() ([]swayOutput, error) {
+	c, err := dialSway()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var outputs []swayOutput
+	err = c.request(swayGetOutputs, nil, &outputs)
+	if err != nil {
+		return nil, fmt.Errorf("get_outputs: %w", err)
+	}
+
+	return outputs, nil
+}
+
+// Outputs returns the outputs sway knows about.
+func (b *swayBackend) Outputs() (Outputs, error) {
+	list, err := b.getOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	b.scales = make(map[string]float64)
+
+	outputs := make(Outputs, 0, len(list))
+	for _, o := range list {
+		outputs = append(outputs, o.Output())
+		if o.Active && o.Scale > 0 {
+			b.scales[o.Name] = o.Scale
+		}
+	}
+
+	return outputs, nil
+}
+
+// Detect returns the outputs sway knows about, sway probes for changes on its
+// own.
+func (b *swayBackend) Detect() (Outputs, error) {
+	return b.Outputs()
+}
+
+// swayCommandResult is returned for each command executed by run_command.
+type swayCommandResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// run executes the commands via IPC, or prints them if --dry-run is set.
+func (b *swayBackend) run(cmds []string) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	if globalOpts.DryRun {
+		for _, cmd := range cmds {
+			fmt.Printf("swaymsg %s\n", cmd)
+		}
+		return nil
+	}
+
+	V("running sway commands %v\n", cmds)
+
+	c, err := dialSway()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var results []swayCommandResult
+	err = c.request(swayRunCommand, []byte(strings.Join(cmds, "; ")), &results)
+	if err != nil {
+		return fmt.Errorf("run_command: %w", err)
+	}
+
+	for i, res := range results {
+		if res.Success {
+			continue
+		}
+
+		cmd := ""
+		if i < len(cmds) {
+			cmd = cmds[i]
+		}
+		return fmt.Errorf("sway command %q failed: %v", cmd, res.Error)
+	}
+
+	return nil
+}
+
+// buildSwayCommands returns the sway commands which configure layout.
+func buildSwayCommands(layout Layout) []string {
+	var cmds []string
+	for _, name := range layout.Disable {
+		cmds = append(cmds, fmt.Sprintf("output %s disable", name))
+	}
+
+	var primary string
+	for _, out := range layout.Outputs {
+		mode := out.Mode
+		if out.Rate != 0 {
+			mode += "@" + strconv.FormatFloat(out.Rate, 'f', -1, 64) + "Hz"
+		}
+
+		cmds = append(cmds, fmt.Sprintf("output %s enable mode %s position %d %d",
+			out.Name, mode, out.X, out.Y))
+
+		if out.Primary {
+			primary = out.Name
+		}
+	}
+
+	// Wayland has no primary output, focus it instead so new windows appear
+	// there
+	if primary != "" {
+		cmds = append(cmds, fmt.Sprintf("focus output %s", primary))
+	}
+
+	return cmds
+}
+
+// Apply configures the outputs for rule with `output` commands.
+func (b *swayBackend) Apply(rule Rule, current Outputs) error {
+	layout, err := ComputeScaledLayout(rule, current, b.scales)
+	if err != nil {
+		return err
+	}
+
+	return b.run(buildSwayCommands(layout))
+}
+
+// Disable switches off the outputs.
+func (b *swayBackend) Disable(off Outputs) error {
+	var cmds []string
+	for _, output := range off {
+		cmds = append(cmds, fmt.Sprintf("output %s disable", output.Name))
+	}
+
+	return b.run(cmds)
+}
+
+// Subscribe forwards output events sent by sway.
+func (b *swayBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	c, err := dialSway()
+	if err != nil {
+		return err
+	}
+
+	var result swayCommandResult
+	err = c.request(swaySubscribe, []byte(`["output"]`), &result)
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	if !result.Success {
+		c.Close()
+		return errors.New("subscribing to output events failed")
+	}
+
+	go func() {
+		<-done
+		c.Close()
+	}()
+
+	go func() {
+		for {
+			typ, payload, err := c.receive()
+			if err == nil && typ != swayEventOutput {
+				continue
+			}
+
+			select {
+			case ch <- Event{Event: string(payload), Error: err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
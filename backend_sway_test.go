@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// swayGetOutputsReply was recorded from sway 1.8 with a laptop panel and an
+// external monitor, which is disabled.
+const swayGetOutputsReply = `[
+  {
+    "id": 3, "type": "output", "name": "eDP-1", "active": true, "dpms": true,
+    "primary": false, "make": "Chimei Innolux Corporation", "model": "0x14D4",
+    "serial": "0x00000000", "scale": 1.5, "scale_filter": "linear",
+    "transform": "normal", "adaptive_sync_status": "disabled",
+    "current_workspace": "1",
+    "modes": [
+      { "width": 1920, "height": 1080, "refresh": 60008 },
+      { "width": 1920, "height": 1080, "refresh": 48006 },
+      { "width": 1280, "height": 720, "refresh": 60000 }
+    ],
+    "current_mode": { "width": 1920, "height": 1080, "refresh": 60008 },
+    "rect": { "x": 0, "y": 0, "width": 1280, "height": 720 }
+  },
+  {
+    "id": 4, "type": "output", "name": "HDMI-A-1", "active": false, "dpms": false,
+    "primary": false, "make": "Samsung Electric Company", "model": "S24C350",
+    "serial": "H9XZ305118", "transform": "normal",
+    "modes": [
+      { "width": 1920, "height": 1080, "refresh": 60000 },
+      { "width": 1680, "height": 1050, "refresh": 59883 }
+    ],
+    "current_mode": { "width": 0, "height": 0, "refresh": 0 },
+    "rect": { "x": 0, "y": 0, "width": 0, "height": 0 }
+  }
+]`
+
+// setenv sets the environment variable for the duration of the test.
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+
+	err := os.Setenv(key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeSway serves recorded replies on a local IPC socket.
+type fakeSway struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeSway(t *testing.T) *fakeSway {
+	dir, err := ioutil.TempDir("", "grobi-test-sway-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "sway-ipc.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &fakeSway{listener: l}
+	go srv.serve()
+
+	t.Cleanup(func() { _ = l.Close() })
+	setenv(t, "SWAYSOCK", path)
+
+	return srv
+}
+
+func (srv *fakeSway) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go srv.handle(&swayConn{conn: conn})
+	}
+}
+
+func (srv *fakeSway) handle(c *swayConn) {
+	defer c.Close()
+
+	for {
+		typ, payload, err := c.receive()
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case swayGetOutputs:
+			_ = c.send(typ, []byte(swayGetOutputsReply))
+		case swayRunCommand:
+			srv.mu.Lock()
+			srv.commands = append(srv.commands, string(payload))
+			srv.mu.Unlock()
+			_ = c.send(typ, []byte(`[{"success": true}]`))
+		case swaySubscribe:
+			_ = c.send(typ, []byte(`{"success": true}`))
+			_ = c.send(0x80000000, []byte(`{"change": "run"}`))
+			_ = c.send(swayEventOutput, []byte(`{"change": "unspecified"}`))
+		}
+	}
+}
+
+func (srv *fakeSway) Commands() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.commands
+}
+
+func TestSwayOutputs(t *testing.T) {
+	newFakeSway(t)
+
+	b := &swayBackend{}
+	outputs, err := b.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Modes: Modes{
+				{Name: "1920x1080", Active: true, Rates: []float64{60.008, 48.006}, ActiveRate: 60.008},
+				{Name: "1280x720", Rates: []float64{60}},
+			},
+			MonitorID: "Chimei Innolux Corporation-0x14D4-0x00000000",
+			Vendor:    "Chimei Innolux Corporation",
+			Rotation:  "normal",
+		},
+		{
+			Name:      "HDMI-A-1",
+			Connected: true,
+			Modes: Modes{
+				{Name: "1920x1080", Rates: []float64{60}},
+				{Name: "1680x1050", Rates: []float64{59.883}},
+			},
+			MonitorID: "Samsung Electric Company-S24C350-H9XZ305118",
+			Vendor:    "Samsung Electric Company",
+			Rotation:  "normal",
+		},
+	}
+
+	if !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("wrong outputs returned:\n  want %v\n  got  %v", want, outputs)
+	}
+
+	if !reflect.DeepEqual(b.scales, map[string]float64{"eDP-1": 1.5}) {
+		t.Fatalf("wrong scales returned: %v", b.scales)
+	}
+}
+
+func TestSwayApply(t *testing.T) {
+	srv := newFakeSway(t)
+
+	b := &swayBackend{}
+	outputs, err := b.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"eDP-1", "HDMI-A-1@1680x1050@60"},
+		Primary:      "HDMI-A-1",
+	}
+
+	err = b.Apply(rule, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"output eDP-1 enable mode 1920x1080 position 0 0; " +
+			"output HDMI-A-1 enable mode 1680x1050@59.883Hz position 1280 0; " +
+			"focus output HDMI-A-1",
+	}
+
+	if !reflect.DeepEqual(srv.Commands(), want) {
+		t.Fatalf("wrong commands sent:\n  want %q\n  got  %q", want, srv.Commands())
+	}
+}
+
+func TestSwaySubscribe(t *testing.T) {
+	newFakeSway(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	err := (&swayBackend{}).Subscribe(ch, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-ch:
+		if ev.Error != nil {
+			t.Fatal(ev.Error)
+		}
+
+		var data map[string]string
+		err = json.Unmarshal([]byte(ev.Event.(string)), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data["change"] != "unspecified" {
+			t.Fatalf("wrong event received: %v", ev.Event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+}
+
+func TestSelectWaylandMode(t *testing.T) {
+	modes := []waylandMode{
+		{Name: "1920x1080", Rate: 60},
+		{Name: "1920x1080", Rate: 144, Preferred: true},
+		{Name: "1920x1080", Rate: 120},
+		{Name: "1280x720", Rate: 60, Current: true},
+		{Name: "1280x720", Rate: 75},
+		{Name: "800x600", Rate: 56},
+		{Name: "800x600", Rate: 75},
+	}
+
+	var tests = []struct {
+		name  string
+		rate  float64
+		index int
+		found bool
+	}{
+		{"1920x1080", 0, 1, true},
+		{"1920x1080", 100, 2, true},
+		{"1280x720", 0, 3, true},
+		{"1280x720", 70, 4, true},
+		{"800x600", 0, 6, true},
+		{"640x480", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		i, ok := selectWaylandMode(modes, test.name, test.rate)
+		if i != test.index || ok != test.found {
+			t.Errorf("%v@%v: want mode %d, %v, got %d, %v", test.name, test.rate, test.index, test.found, i, ok)
+		}
+	}
+}
+
+func TestSwayOutputTransform(t *testing.T) {
+	var tests = []struct {
+		transform string
+		rotation  string
+	}{
+		{"normal", "normal"},
+		{"90", "left"},
+		{"180", "inverted"},
+		{"270", "right"},
+		{"flipped", "normal"},
+		{"flipped-90", "left"},
+		{"flipped-180", "inverted"},
+		{"flipped-270", "right"},
+	}
+
+	for _, test := range tests {
+		o := swayOutput{Name: "DP-1", Transform: test.transform}
+		if rotation := o.Output().Rotation; rotation != test.rotation {
+			t.Errorf("transform %q: wrong rotation, want %q, got %q", test.transform, test.rotation, rotation)
+		}
+	}
+
+	var outputs []swayOutput
+	err := json.Unmarshal([]byte(swayGetOutputsReply), &outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs[1].Transform = "270"
+
+	current := Outputs{outputs[0].Output(), outputs[1].Output()}
+	layout, err := ComputeScaledLayout(Rule{ConfigureRow: []string{"HDMI-A-1", "eDP-1"}}, current, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rotated monitor is 1080 pixels wide
+	if layout.Outputs[1].X != 1080 {
+		t.Fatalf("wrong position for eDP-1 next to the rotated monitor: %+v", layout.Outputs)
+	}
+}
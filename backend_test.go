@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestNewBackend(t *testing.T) {
+	for _, name := range []string{"randr", "xrandr"} {
+		b, err := NewBackend(name)
+		if err != nil {
+			t.Fatalf("backend %v: %v", name, err)
+		}
+		if b == nil {
+			t.Fatalf("backend %v: nil returned", name)
+		}
+	}
+
+	_, err := NewBackend("foobar")
+	if err == nil {
+		t.Fatal("no error returned for unknown backend")
+	}
+}
+
+func TestConfigValidBackend(t *testing.T) {
+	if err := (Config{Backend: "xrandr"}).Valid(); err != nil {
+		t.Fatalf("valid backend rejected: %v", err)
+	}
+
+	if err := (Config{Backend: "foobar"}).Valid(); err == nil {
+		t.Fatal("unknown backend accepted")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// wlrMode is a mode as printed by `wlr-randr --json`.
+type wlrMode struct {
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Refresh   float64 `json:"refresh"`
+	Preferred bool    `json:"preferred"`
+	Current   bool    `json:"current"`
+}
+
+// wlrOutput is an output as printed by `wlr-randr --json`.
+type wlrOutput struct {
+	Name     string    `json:"name"`
+	Make     string    `json:"make"`
+	Model    string    `json:"model"`
+	Serial   string    `json:"serial"`
+	Enabled  bool      `json:"enabled"`
+	Modes    []wlrMode `json:"modes"`
+	Position struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"position"`
+	Transform string  `json:"transform"`
+	Scale     float64 `json:"scale"`
+}
+
+// Output converts the output to grobi's representation.
+func (o wlrOutput) Output() Output {
+	output := Output{
+		Name:      o.Name,
+		Connected: true,
+		MonitorID: waylandMonitorID(o.Make, o.Model, o.Serial),
+		Vendor:    waylandVendor(o.Make),
+		Rotation:  waylandRotation(o.Transform),
+	}
+
+	for _, m := range o.Modes {
+		name := fmt.Sprintf("%dx%d", m.Width, m.Height)
+		addWaylandMode(&output, name, m.Refresh, o.Enabled && m.Current, m.Preferred)
+	}
+
+	return output
+}
+
+// ParseWlrRandr returns the outputs and their scale factors parsed from the
+// output of `wlr-randr --json`.
+func ParseWlrRandr(buf []byte) (Outputs, map[string]float64, error) {
+	var list []wlrOutput
+	err := json.Unmarshal(buf, &list)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing wlr-randr output: %w", err)
+	}
+
+	outputs := make(Outputs, 0, len(list))
+	scales := make(map[string]float64)
+	for _, o := range list {
+		outputs = append(outputs, o.Output())
+		if o.Enabled && o.Scale > 0 {
+			scales[o.Name] = o.Scale
+		}
+	}
+
+	return outputs, scales, nil
+}
+
+// BuildWlrRandrCommand returns a call to `wlr-randr` which configures all
+// outputs of layout at once. wlr-randr does not support relative placement,
+// so the absolute positions of the layout are used. Outputs keep their scale
+// factor.
+func BuildWlrRandrCommand(layout Layout, scales map[string]float64) *exec.Cmd {
+	var args []string
+	for _, name := range layout.Disable {
+		args = append(args, "--output", name, "--off")
+	}
+
+	for _, out := range layout.Outputs {
+		mode := out.Mode
+		if out.Rate != 0 {
+			mode += "@" + strconv.FormatFloat(out.Rate, 'f', -1, 64) + "Hz"
+		}
+
+		args = append(args, "--output", out.Name, "--on",
+			"--mode", mode,
+			"--pos", fmt.Sprintf("%d,%d", out.X, out.Y))
+
+		if scale, ok := scales[out.Name]; ok {
+			args = append(args, "--scale", strconv.FormatFloat(scale, 'f', -1, 64))
+		}
+
+		if out.Primary {
+			V("wlroots has no primary output, ignoring primary %v\n", out.Name)
+		}
+	}
+
+	return exec.Command("wlr-randr", args...)
+}
+
+// wlrootsBackend configures outputs on wlroots compositors by running
+// `wlr-randr`.
+type wlrootsBackend struct {
+	// scales holds the scale factor of each output from the last query.
+	scales map[string]float64
+}
+
+func init() {
+	registerBackend("wlroots", func() (Backend, error) {
+		return &wlrootsBackend{}, nil
+	})
+}
+
+// Outputs runs `wlr-randr --json` and returns the parsed output.
+func (b *wlrootsBackend) Outputs() (Outputs, error) {
+	cmd := exec.Command("wlr-randr", "--json")
+	cmd.Stderr = os.Stderr
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, scales, err := ParseWlrRandr(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	b.scales = scales
+	return outputs, nil
+}
+
+// Detect returns the outputs, the compositor probes for changes on its own.
+func (b *wlrootsBackend) Detect() (Outputs, error) {
+	return b.Outputs()
+}
+
+// Apply runs `wlr-randr` to configure the outputs for rule.
+func (b *wlrootsBackend) Apply(rule Rule, current Outputs) error {
+	layout, err := ComputeScaledLayout(rule, current, b.scales)
+	if err != nil {
+		return err
+	}
+
+	runRuleCommands(rule, []*exec.Cmd{BuildWlrRandrCommand(layout, b.scales)})
+	return nil
+}
+
+// Disable runs `wlr-randr` to switch off the outputs.
+func (b *wlrootsBackend) Disable(off Outputs) error {
+	if len(off) == 0 {
+		return nil
+	}
+
+	var args []string
+	for _, output := range off {
+		args = append(args, "--output", output.Name, "--off")
+	}
+
+	return RunCommand(exec.Command("wlr-randr", args...))
+}
+
+// Subscribe does nothing, wlr-randr cannot report changes. Polling is used
+// instead.
+func (b *wlrootsBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	V("wlroots backend does not report changes, relying on polling\n")
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// wlrRandrOutput was recorded from `wlr-randr --json` on river.
+const wlrRandrOutput = `[
+  {
+    "name": "eDP-1",
+    "description": "Chimei Innolux Corporation 0x14D4 (eDP-1)",
+    "make": "Chimei Innolux Corporation",
+    "model": "0x14D4",
+    "serial": "Unknown",
+    "physical_size": { "width": 310, "height": 170 },
+    "enabled": true,
+    "modes": [
+      { "width": 1920, "height": 1080, "refresh": 60.008, "preferred": true, "current": true },
+      { "width": 1920, "height": 1080, "refresh": 48.006, "preferred": false, "current": false }
+    ],
+    "position": { "x": 0, "y": 0 },
+    "transform": "normal",
+    "scale": 2.0,
+    "adaptive_sync": false
+  },
+  {
+    "name": "DP-3",
+    "description": "Dell Inc. DELL U2720Q 8LXMZ13 (DP-3)",
+    "make": "Dell Inc.",
+    "model": "DELL U2720Q",
+    "serial": "8LXMZ13",
+    "physical_size": { "width": 600, "height": 340 },
+    "enabled": false,
+    "modes": [
+      { "width": 3840, "height": 2160, "refresh": 59.997, "preferred": true, "current": false },
+      { "width": 2560, "height": 1440, "refresh": 59.951, "preferred": false, "current": false }
+    ],
+    "position": { "x": 0, "y": 0 },
+    "transform": "normal",
+    "scale": 1.0,
+    "adaptive_sync": false
+  }
+]`
+
+func TestParseWlrRandr(t *testing.T) {
+	outputs, scales, err := ParseWlrRandr([]byte(wlrRandrOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Modes: Modes{
+				{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.008, 48.006}, ActiveRate: 60.008, DefaultRate: 60.008},
+			},
+			MonitorID: "Chimei Innolux Corporation-0x14D4-",
+			Vendor:    "Chimei Innolux Corporation",
+			Rotation:  "normal",
+		},
+		{
+			Name:      "DP-3",
+			Connected: true,
+			Modes: Modes{
+				{Name: "3840x2160", Default: true, Rates: []float64{59.997}, DefaultRate: 59.997},
+				{Name: "2560x1440", Rates: []float64{59.951}},
+			},
+			MonitorID: "Dell Inc.-DELL U2720Q-8LXMZ13",
+			Vendor:    "Dell Inc.",
+			Rotation:  "normal",
+		},
+	}
+
+	if !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("wrong outputs returned:\n  want %v\n  got  %v", want, outputs)
+	}
+
+	if !reflect.DeepEqual(scales, map[string]float64{"eDP-1": 2}) {
+		t.Fatalf("wrong scales returned: %v", scales)
+	}
+}
+
+func TestBuildWlrRandrCommand(t *testing.T) {
+	outputs, scales, err := ParseWlrRandr([]byte(wlrRandrOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		rule Rule
+		args []string
+	}{
+		{
+			Rule{ConfigureRow: []string{"eDP-1", "DP-3"}},
+			[]string{"wlr-randr",
+				"--output", "eDP-1", "--on", "--mode", "1920x1080", "--pos", "0,0", "--scale", "2",
+				"--output", "DP-3", "--on", "--mode", "3840x2160", "--pos", "960,0",
+			},
+		},
+		{
+			Rule{ConfigureColumn: []string{"DP-3@2560x1440", "eDP-1"}},
+			[]string{"wlr-randr",
+				"--output", "DP-3", "--on", "--mode", "2560x1440", "--pos", "0,0",
+				"--output", "eDP-1", "--on", "--mode", "1920x1080", "--pos", "0,1440", "--scale", "2",
+			},
+		},
+		{
+			Rule{ConfigureRow: []string{"eDP-1@1920x1080@48", "DP-3"}},
+			[]string{"wlr-randr",
+				"--output", "eDP-1", "--on", "--mode", "1920x1080@48.006Hz", "--pos", "0,0", "--scale", "2",
+				"--output", "DP-3", "--on", "--mode", "3840x2160", "--pos", "960,0",
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP-3"},
+			[]string{"wlr-randr",
+				"--output", "eDP-1", "--off",
+				"--output", "DP-3", "--on", "--mode", "3840x2160", "--pos", "0,0",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			layout, err := ComputeScaledLayout(test.rule, outputs, scales)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := BuildWlrRandrCommand(layout, scales)
+			if !reflect.DeepEqual(cmd.Args, test.args) {
+				t.Fatalf("wrong command returned:\n  want %q\n  got  %q", test.args, cmd.Args)
+			}
+		})
+	}
+}
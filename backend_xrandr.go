@@ -0,0 +1,148 @@
+package main
+
+import (
+	"time"
+
+	"github.com/BurntSushi/xgb/randr"
+)
+
+// xrandrBackend configures outputs by running `xrandr` and parsing its output.
+type xrandrBackend struct{}
+
+func init() {
+	registerBackend("xrandr", func() (Backend, error) {
+		return xrandrBackend{}, nil
+	})
+}
+
+// Outputs runs `xrandr --current` and returns the parsed output.
+func (xrandrBackend) Outputs() (Outputs, error) {
+	return xrandrOutputs("--current")
+}
+
+// Detect runs `xrandr`, which rescans the outputs, and returns the parsed
+// output.
+func (xrandrBackend) Detect() (Outputs, error) {
+	return xrandrOutputs()
+}
+
+// DetailedOutputs runs `xrandr --verbose` and returns the parsed output.
+func (xrandrBackend) DetailedOutputs() (Outputs, error) {
+	return xrandrOutputs("--verbose")
+}
+
+// Screens runs `xrandr --current` and returns the parsed screens.
+func (xrandrBackend) Screens() (Screens, error) {
+	screens, _, err := xrandrQuery("--current")
+	return screens, err
+}
+
+// Apply runs the calls to `xrandr` which configure the outputs for rule.
+func (xrandrBackend) Apply(rule Rule, current Outputs) error {
+	cmds, err := BuildCommandOutputRow(rule, current)
+	if err != nil {
+		return err
+	}
+
+	runRuleCommands(rule, cmds)
+	return nil
+}
+
+// Disable runs `xrandr` to switch off the outputs.
+func (xrandrBackend) Disable(off Outputs) error {
+	cmds, err := DisableOutputs(off)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range cmds {
+		err = RunCommand(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Monitors runs `xrandr --listmonitors` and returns the parsed output.
+func (xrandrBackend) Monitors() (Monitors, error) {
+	return xrandrMonitors()
+}
+
+// SetMonitors runs `xrandr` to remove and create the monitors.
+func (xrandrBackend) SetMonitors(add Monitors, remove []string) error {
+	return RunCommand(BuildCommandMonitors(add, remove))
+}
+
+// Providers runs `xrandr --listproviders` and returns the parsed output.
+func (xrandrBackend) Providers() (Providers, error) {
+	return xrandrProviders()
+}
+
+// LinkProvider runs `xrandr --setprovideroutputsource`.
+func (xrandrBackend) LinkProvider(sink, source Provider) error {
+	return RunCommand(BuildCommandLinkProvider(sink, source))
+}
+
+// Subscribe connects to the X server and forwards RANDR change events.
+func (xrandrBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	X, err := NewRandrConn()
+	if err != nil {
+		return err
+	}
+
+	err = selectRandrEvents(X)
+	if err != nil {
+		X.Close()
+		return err
+	}
+
+	go func() {
+		defer X.Close()
+		forwardXEvents(X, ch, done)
+	}()
+
+	return nil
+}
+
+const eventSendTimeout = 500 * time.Millisecond
+
+// selectRandrEvents subscribes to all RANDR change events on the root window.
+func selectRandrEvents(X *RandrConn) error {
+	eventMask := randr.NotifyMaskScreenChange |
+		randr.NotifyMaskCrtcChange |
+		randr.NotifyMaskOutputChange |
+		randr.NotifyMaskOutputProperty |
+		randr.NotifyMaskProviderChange |
+		randr.NotifyMaskResourceChange
+
+	return randr.SelectInputChecked(X.X, X.Root, uint16(eventMask)).Check()
+}
+
+// forwardXEvents sends all events received on the connection to ch until done
+// is closed or the connection is lost, which is reported as an error.
+func forwardXEvents(X *RandrConn, ch chan<- Event, done <-chan struct{}) {
+	for {
+		ev, err := X.waitForEvent()
+		if ev == nil && err == nil {
+			return
+		}
+
+		if err != nil {
+			select {
+			case ch <- Event{Error: err}:
+			case <-done:
+			}
+			return
+		}
+
+		select {
+		case ch <- Event{Event: ev}:
+		case <-time.After(eventSendTimeout):
+			continue
+		case <-done:
+			return
+		}
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
 )
@@ -24,14 +23,37 @@ func (cmd CmdApply) Usage() string {
 	return "RULE"
 }
 
-func ApplyRule(outputs Outputs, rule Rule) error {
+// ApplyRule configures the outputs as described by rule using the backend and
+// runs the commands which are configured to be executed afterwards.
+func ApplyRule(backend Backend, outputs Outputs, rule Rule) error {
 	var cmds []*exec.Cmd
-	var err error
+
+	// outputs on other screens are left alone
+	outputs = rule.ScreenOutputs(outputs)
 
 	switch {
 	case rule.ConfigureSingle != "" || len(rule.ConfigureRow) > 0 || len(rule.ConfigureColumn) > 0:
-		cmds, err = BuildCommandOutputRow(rule, outputs)
+		err := checkLayoutFits(backend, rule, outputs)
+		if err != nil {
+			return err
+		}
+
+		err = backend.Apply(rule, outputs)
+		if err != nil {
+			return err
+		}
+
+		err = applyMonitors(backend, rule, outputs)
+		if err != nil {
+			return err
+		}
 	case rule.ConfigureCommand != "":
+		// remove the virtual monitors of the previous rule
+		err := applyMonitors(backend, rule, outputs)
+		if err != nil {
+			return err
+		}
+
 		cmds = []*exec.Cmd{exec.Command("sh", "-c", rule.ConfigureCommand)}
 	default:
 		return fmt.Errorf("no output configuration for rule %v", rule.Name)
@@ -44,15 +66,7 @@ func ApplyRule(outputs Outputs, rule Rule) error {
 		}
 	}
 
-	if err != nil {
-		return err
-	}
-	for _, cmd := range cmds {
-		err = RunCommand(cmd)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "executing command for rule %v failed: %v\n", rule.Name, err)
-		}
-	}
+	runRuleCommands(rule, cmds)
 
 	return nil
 }
@@ -70,7 +84,12 @@ func (cmd CmdApply) Execute(args []string) (err error) {
 		return errors.New("need exactly one rule name as the parameter")
 	}
 
-	outputs, err := DetectOutputs()
+	backend, err := globalOpts.OpenBackend()
+	if err != nil {
+		return err
+	}
+
+	outputs, err := backend.Detect()
 	if err != nil {
 		return err
 	}
@@ -79,7 +98,7 @@ func (cmd CmdApply) Execute(args []string) (err error) {
 	for _, rule := range globalOpts.cfg.Rules {
 		if strings.ToLower(rule.Name) == ruleName {
 			V("found matching rule (name %v)\n", rule.Name)
-			return ApplyRule(outputs, rule)
+			return ApplyRule(backend, outputs, rule)
 		}
 	}
 
@@ -40,10 +40,23 @@ func (cmd CmdRules) Execute(args []string) error {
 			printList("Disconnected", rule.OutputsDisconnected)
 			printList("Present", rule.OutputsPresent)
 			printList("Absent", rule.OutputsAbsent)
+			for name, props := range rule.OutputsProperties {
+				fmt.Printf("  Properties %s: %v\n", name, props)
+			}
+			for name, vendor := range rule.OutputsVendor {
+				fmt.Printf("  Vendor %s: %v\n", name, vendor)
+			}
+			printList("Audio", rule.OutputsAudio)
+			if rule.Screen != nil {
+				fmt.Printf("  Screen: %d\n", *rule.Screen)
+			}
 			printList("ConfigureRow", rule.ConfigureRow)
 			printList("ConfigureColumn", rule.ConfigureColumn)
 			printOne("ConfigureSingle", rule.ConfigureSingle)
 			printOne("ConfigureCommand", rule.ConfigureCommand)
+			for name, n := range rule.SplitOutputs {
+				fmt.Printf("  Split %s: %d monitors\n", name, n)
+			}
 			printList("ExecuteAfter", rule.ExecuteAfter)
 		}
 	}
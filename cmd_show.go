@@ -1,13 +1,19 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type CmdShow struct{}
 
 func init() {
 	_, err := parser.AddCommand("show",
 		"show monitors and IDs",
-		"The show command lists all connected monitors with their IDs",
+		"The show command lists all connected monitors with their IDs "+
+			"and the problems found while parsing the xrandr output, "+
+			"with --verbose also their output properties, EDID, CRTCs, transform "+
+			"and the timing of the active mode",
 		&CmdShow{})
 	if err != nil {
 		panic(err)
@@ -15,16 +21,173 @@ func init() {
 }
 
 func ListOutput(output Output) {
-	fmt.Printf("%- 10s %s\n", output.Name, output.MonitorID)
+	str := fmt.Sprintf("%- 10s %s", output.Name, output.MonitorID)
+
+	if name := output.VendorName(); name != output.Vendor {
+		str += " (" + name + ")"
+	}
+
+	if placement := output.Placement(); placement != "" {
+		str += " " + placement
+	}
+
+	if output.WidthMM > 0 || output.HeightMM > 0 {
+		str += fmt.Sprintf(" %dmm x %dmm", output.WidthMM, output.HeightMM)
+	}
+
+	if output.Unknown {
+		str += " (unknown connection)"
+	}
+
+	fmt.Println(str)
+
+	if len(output.Tiles) > 0 {
+		fmt.Printf("    tiles: %s\n", strings.Join(tileNames(output), ", "))
+	}
+
+	for _, w := range output.Warnings {
+		if w.Line == 0 {
+			fmt.Printf("    warning: %v\n", w.Err)
+			continue
+		}
+		fmt.Printf("    warning: line %d: %v\n", w.Line, w.Err)
+	}
+
+	if globalOpts.Verbose {
+		for _, name := range output.Properties.Names() {
+			fmt.Printf("    %s: %v\n", name, output.Properties[name])
+		}
+
+		if output.EDID != nil {
+			listEDID(output.EDID)
+		}
+
+		if output.Details != nil {
+			listDetails(output)
+		}
+	}
+}
+
+// listEDID prints the interesting fields of the EDID.
+func listEDID(e *EDID) {
+	fmt.Printf("    EDID %d.%d: %s, manufactured %s\n", e.Version, e.Revision, e.Vendor, e.Manufactured())
+	if e.WidthCM > 0 && e.HeightCM > 0 {
+		fmt.Printf("    size: %dcm x %dcm\n", e.WidthCM, e.HeightCM)
+	}
+	if e.Gamma > 0 {
+		fmt.Printf("    display gamma: %.2f\n", e.Gamma)
+	}
+	for i, timing := range e.Timings {
+		if i == 0 {
+			fmt.Printf("    preferred timing: %v\n", timing)
+			continue
+		}
+		fmt.Printf("    detailed timing: %v\n", timing)
+	}
+	if e.RangeLimits != nil {
+		fmt.Printf("    range limits: %v\n", e.RangeLimits)
+	}
+	if e.CEA != nil {
+		listCEA(e.CEA)
+	}
+	if d := e.DisplayID; d != nil {
+		str := fmt.Sprintf("    DisplayID %d.%d: %s", d.Version, d.Revision, d.ProductType)
+		if d.Tiled {
+			str += ", tiled"
+		}
+		fmt.Println(str)
+	}
+}
+
+// listCEA prints the data from the CEA-861 extension blocks.
+func listCEA(cea *CEA) {
+	fmt.Printf("    CEA-861 revision %d\n", cea.Revision)
+	if len(cea.Video) > 0 {
+		var modes []string
+		for _, mode := range cea.Video {
+			modes = append(modes, mode.String())
+		}
+		fmt.Printf("    CEA modes: %s\n", strings.Join(modes, ", "))
+	}
+	for _, audio := range cea.Audio {
+		fmt.Printf("    audio: %v\n", audio)
+	}
+	if cea.HDMI != nil {
+		fmt.Printf("    HDMI: physical address %s\n", cea.HDMI.PhysicalAddress)
+	}
+	if cea.HDR != nil {
+		fmt.Printf("    HDR: %s, max luminance %.0f cd/m²\n", strings.Join(cea.HDR.EOTFs, ", "), cea.HDR.MaxLuminance)
+	}
+}
+
+// listDetails prints the CRTC assignment, transform and the timing of the
+// active mode.
+func listDetails(output Output) {
+	d := output.Details
+
+	if d.Crtc >= 0 {
+		fmt.Printf("    CRTC: %d\n", d.Crtc)
+	}
+	fmt.Printf("    possible CRTCs: %v\n", d.Crtcs)
+	if len(d.Clones) > 0 {
+		fmt.Printf("    clones: %s\n", strings.Join(d.Clones, ", "))
+	}
+	if d.Brightness > 0 {
+		fmt.Printf("    gamma: %v:%v:%v, brightness: %v\n",
+			d.Gamma[0], d.Gamma[1], d.Gamma[2], d.Brightness)
+	}
+	if d.Panning.Width > 0 {
+		fmt.Printf("    panning: %v, tracking: %v\n", d.Panning, d.Tracking)
+	}
+	for i, row := range d.Transform {
+		prefix := "               "
+		if i == 0 {
+			prefix = "    transform: "
+		}
+		fmt.Printf("%s%f %f %f\n", prefix, row[0], row[1], row[2])
+	}
+	if d.Filter != "" {
+		fmt.Printf("    filter: %s\n", d.Filter)
+	}
+
+	for _, mode := range output.Modes {
+		for _, t := range mode.Timings {
+			if t.ID != d.ModeID {
+				continue
+			}
+
+			fmt.Printf("    mode %s (0x%x): %.3fMHz %s\n", mode.Name, t.ID, t.PixelClock, strings.Join(t.Flags, " "))
+			fmt.Printf("        h: width %d start %d end %d total %d skew %d\n",
+				t.HDisplay, t.HSyncStart, t.HSyncEnd, t.HTotal, t.HSkew)
+			fmt.Printf("        v: height %d start %d end %d total %d clock %.2fHz\n",
+				t.VDisplay, t.VSyncStart, t.VSyncEnd, t.VTotal, t.Rate)
+		}
+	}
 }
 
 func (cmd CmdShow) Execute(args []string) error {
-	outputs, err := DetectOutputs()
+	// the config file is only needed for selecting the backend
+	err := globalOpts.ReadConfigfile()
+	if err != nil {
+		V("%v\n", err)
+	}
+
+	backend, err := globalOpts.OpenBackend()
+	if err != nil {
+		return err
+	}
+
+	var outputs Outputs
+	if detailed, ok := backend.(DetailedBackend); ok && globalOpts.Verbose {
+		outputs, err = detailed.DetailedOutputs()
+	} else {
+		outputs, err = backend.Detect()
+	}
 	if err != nil {
 		return err
 	}
 	for _, output := range outputs {
-		if output.Connected {
+		if output.Connected || output.Unknown {
 			ListOutput(output)
 		}
 	}
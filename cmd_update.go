@@ -1,5 +1,10 @@
 package main
 
+import (
+	"errors"
+	"sort"
+)
+
 type CmdUpdate struct{}
 
 func init() {
@@ -12,14 +17,34 @@ func init() {
 	}
 }
 
-func MatchRules(rules []Rule, outputs Outputs) (Rule, error) {
+// MatchRules returns the first matching rule for each X screen, indexed by
+// the screen. A rule is only considered for the screen it configures, see
+// Rule.ScreenIndex.
+func MatchRules(rules []Rule, outputs Outputs) (map[int]Rule, error) {
+	matched := make(map[int]Rule)
 	for _, rule := range rules {
+		screen := rule.ScreenIndex(outputs)
+		if _, ok := matched[screen]; ok {
+			continue
+		}
+
 		if rule.Match(outputs) {
-			return rule, nil
+			matched[screen] = rule
 		}
 	}
 
-	return Rule{}, nil
+	return matched, nil
+}
+
+// matchedScreens returns the screens of the matched rules in ascending order.
+func matchedScreens(matched map[int]Rule) []int {
+	screens := make([]int, 0, len(matched))
+	for screen := range matched {
+		screens = append(screens, screen)
+	}
+	sort.Ints(screens)
+
+	return screens
 }
 
 func (cmd CmdUpdate) Execute(args []string) (err error) {
@@ -31,17 +56,34 @@ func (cmd CmdUpdate) Execute(args []string) (err error) {
 	// install panic handler if commands are given
 	defer RunCommandsOnFailure(&err, globalOpts.cfg.OnFailure)()
 
-	outputs, err := DetectOutputs()
+	backend, err := globalOpts.OpenBackend()
+	if err != nil {
+		return err
+	}
+
+	outputs, err := backend.Detect()
 	if err != nil {
 		return err
 	}
 
-	rule, err := MatchRules(globalOpts.cfg.Rules, outputs)
+	matched, err := MatchRules(globalOpts.cfg.Rules, outputs)
 	if err != nil {
 		return err
 	}
 
-	V("rule %q matches\n", rule.Name)
+	if len(matched) == 0 {
+		return errors.New("no rule matches")
+	}
+
+	for _, screen := range matchedScreens(matched) {
+		rule := matched[screen]
+		V("rule %q matches for screen %d\n", rule.Name, screen)
+
+		err = ApplyRule(backend, outputs, rule)
+		if err != nil {
+			return err
+		}
+	}
 
-	return ApplyRule(outputs, rule)
+	return nil
 }
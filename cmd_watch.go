@@ -1,19 +1,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"runtime"
 	"time"
-
-	"github.com/BurntSushi/xgb"
-	"github.com/BurntSushi/xgb/randr"
-	"github.com/BurntSushi/xgb/xproto"
 )
 
 type CmdWatch struct{}
 
+// reconnectMinDelay and reconnectMaxDelay limit the time between attempts to
+// subscribe to change events again.
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = time.Minute
+)
+
 func init() {
 	_, err := parser.AddCommand("watch",
 		"watch for changes",
@@ -24,71 +27,63 @@ func init() {
 	}
 }
 
-type Event struct {
-	Event xgb.Event
-	Error error
-}
-
-const eventSendTimeout = 500 * time.Millisecond
-
-func subscribeXEvents(ch chan<- Event, done <-chan struct{}) {
-	X, err := xgb.NewConn()
+func (cmd CmdWatch) Execute(args []string) (err error) {
+	err = globalOpts.ReadConfigfile()
 	if err != nil {
-		ch <- Event{Error: err}
-		return
-	}
-
-	defer X.Close()
-	if err = randr.Init(X); err != nil {
-		ch <- Event{Error: err}
-		return
+		return fmt.Errorf("reading config file failed: %w", err)
 	}
 
-	root := xproto.Setup(X).DefaultScreen(X).Root
-
-	eventMask := randr.NotifyMaskScreenChange |
-		randr.NotifyMaskCrtcChange |
-		randr.NotifyMaskOutputChange |
-		randr.NotifyMaskOutputProperty
+	// install panic handler if commands are given
+	defer RunCommandsOnFailure(&err, globalOpts.cfg.OnFailure)()
 
-	err = randr.SelectInputChecked(X, root, uint16(eventMask)).Check()
+	backend, err := globalOpts.OpenBackend()
 	if err != nil {
-		ch <- Event{Error: err}
-		return
+		return err
 	}
 
-	for {
-		ev, err := X.WaitForEvent()
-		select {
-		case ch <- Event{Event: ev, Error: err}:
-		case <-time.After(eventSendTimeout):
-			continue
-		case <-done:
-			return
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
+	V("grobi %s, compiled with %v on %v\n", version, runtime.Version(), runtime.GOOS)
+
+	return watch(backend, nil)
 }
 
-func (cmd CmdWatch) Execute(args []string) (err error) {
-	err = globalOpts.ReadConfigfile()
-	if err != nil {
-		return fmt.Errorf("reading config file failed: %w", err)
-	}
+// watch configures the outputs whenever they change until stop is closed. A
+// lost connection to the display server is reestablished.
+func watch(backend Backend, stop <-chan struct{}) error {
+	var ch chan Event
+	var done chan struct{}
+	defer func() {
+		close(done)
+	}()
+
+	// subscribe (re-)subscribes to change events, it retries with exponential
+	// backoff until the display server can be reached
+	subscribe := func() {
+		if done != nil {
+			close(done)
+		}
 
-	// install panic handler if commands are given
-	defer RunCommandsOnFailure(&err, globalOpts.cfg.OnFailure)()
+		done = make(chan struct{})
+		ch = make(chan Event)
 
-	done := make(chan struct{})
-	defer close(done)
+		delay := reconnectMinDelay
+		for {
+			err := backend.Subscribe(ch, done)
+			if err == nil {
+				V("successfully subscribed to change events\n")
+				return
+			}
 
-	ch := make(chan Event)
-	go subscribeXEvents(ch, done)
+			fmt.Fprintf(os.Stderr, "subscribing to change events failed: %v, retrying in %v\n", err, delay)
+			time.Sleep(delay)
 
-	V("grobi %s, compiled with %v on %v\n", version, runtime.Version(), runtime.GOOS)
-	V("successfully subscribed to X RANDR change events\n")
+			delay *= 2
+			if delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+		}
+	}
+
+	subscribe()
 
 	var tickerCh <-chan time.Time
 	if globalOpts.PollInterval > 0 {
@@ -98,19 +93,59 @@ func (cmd CmdWatch) Execute(args []string) (err error) {
 	var backoffCh <-chan time.Time
 	var disablePoll bool
 	var eventReceived bool
+	var eventOutputs Outputs
 
-	var lastRule Rule
+	// linkPending is set when providers may have been added, they were
+	// linked when the backend was opened
+	var linkPending bool
+
+	// lastRules holds the name of the rule last applied for each screen
+	lastRules := make(map[int]string)
 	var lastOutputs Outputs
+
+	// reconnect subscribes to change events again after the connection to
+	// the display server was lost. The display server may have been
+	// restarted, so the rules are evaluated again.
+	reconnect := func(err error) {
+		fmt.Fprintf(os.Stderr, "%v, reconnecting\n", err)
+		subscribe()
+
+		lastRules = make(map[int]string)
+		lastOutputs = nil
+		eventReceived = true
+		linkPending = true
+	}
+
+nextEvent:
 	for {
 		if !disablePoll {
 			var outputs Outputs
 			var err error
 
-			if eventReceived || globalOpts.ActivePoll {
-				outputs, err = DetectOutputs()
-				eventReceived = false
-			} else {
-				outputs, err = GetOutputs()
+			// new providers (e.g. a DisplayLink dock) need to be linked
+			// before their outputs can be queried
+			if linkPending {
+				if err := linkProviders(backend, globalOpts.cfg.LinkProviders); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+				}
+				linkPending = false
+			}
+
+			switch {
+			case eventReceived || globalOpts.ActivePoll:
+				outputs, err = backend.Detect()
+			case eventOutputs != nil:
+				outputs = eventOutputs
+			default:
+				outputs, err = backend.Outputs()
+			}
+
+			eventReceived = false
+			eventOutputs = nil
+
+			if errors.Is(err, errConnectionLost) {
+				reconnect(err)
+				continue
 			}
 
 			if err != nil {
@@ -142,21 +177,21 @@ func (cmd CmdWatch) Execute(args []string) (err error) {
 			if len(off) > 0 {
 				V("disable %d outputs", len(off))
 
-				cmd, err := DisableOutputs(off)
-				if err != nil {
-					return fmt.Errorf("disabling outputs: %w", err)
-				}
-
 				// forget the last rule set, something has changed for sure
-				lastRule = Rule{}
+				lastRules = make(map[int]string)
 
-				err = RunCommand(cmd)
+				err = backend.Disable(off)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "error disabling: %v\n", err)
 				}
 
 				// refresh outputs again
-				outputs, err = GetOutputs()
+				outputs, err = backend.Outputs()
+				if errors.Is(err, errConnectionLost) {
+					reconnect(err)
+					continue
+				}
+
 				if err != nil {
 					return fmt.Errorf("detecting outputs after disabling: %w", err)
 				}
@@ -164,22 +199,43 @@ func (cmd CmdWatch) Execute(args []string) (err error) {
 				V("new outputs after disable: %v", outputs)
 			}
 
-			rule, err := MatchRules(globalOpts.cfg.Rules, outputs)
+			matched, err := MatchRules(globalOpts.cfg.Rules, outputs)
 			if err != nil {
 				return fmt.Errorf("matching rules: %w", err)
 			}
 
-			if rule.Name != lastRule.Name {
+			// forget the rules of screens for which nothing matches any more
+			for screen := range lastRules {
+				if _, ok := matched[screen]; !ok {
+					delete(lastRules, screen)
+				}
+			}
+
+			var applied bool
+			for _, screen := range matchedScreens(matched) {
+				rule := matched[screen]
+				if rule.Name == lastRules[screen] {
+					continue
+				}
+
 				V("outputs: %v", outputs)
-				V("new rule found: %v", rule.Name)
+				V("new rule found for screen %d: %v", screen, rule.Name)
+
+				err = ApplyRule(backend, outputs, rule)
+				if errors.Is(err, errConnectionLost) {
+					reconnect(err)
+					continue nextEvent
+				}
 
-				err = ApplyRule(outputs, rule)
 				if err != nil {
 					return fmt.Errorf("applying rules: %w", err)
 				}
 
-				lastRule = rule
+				lastRules[screen] = rule.Name
+				applied = true
+			}
 
+			if applied {
 				if globalOpts.Pause > 0 {
 					V("disable polling for %d seconds\n", globalOpts.Pause)
 					disablePoll = true
@@ -187,7 +243,12 @@ func (cmd CmdWatch) Execute(args []string) (err error) {
 				}
 
 				// refresh outputs for next cycle
-				outputs, err = GetOutputs()
+				outputs, err = backend.Outputs()
+				if errors.Is(err, errConnectionLost) {
+					reconnect(err)
+					continue
+				}
+
 				if err != nil {
 					return fmt.Errorf("refreshing outputs: %w", err)
 				}
@@ -198,17 +259,29 @@ func (cmd CmdWatch) Execute(args []string) (err error) {
 
 		select {
 		case ev := <-ch:
-			V("new RANDR change event received\n")
+			V("new change event received\n")
 			if ev.Error != nil {
-				return fmt.Errorf("RANDR change event contains error: %w", ev.Error)
+				reconnect(fmt.Errorf("change event contains error: %w", ev.Error))
+				continue
 			}
 
-			eventReceived = true
+			if providersChanged(ev) {
+				linkPending = true
+			}
+
+			if ev.Outputs != nil {
+				V("change %v applied by backend\n", ev.Event)
+				eventOutputs = ev.Outputs
+			} else {
+				eventReceived = true
+			}
 		case <-tickerCh:
 		case <-backoffCh:
 			V("reenable polling\n")
 			backoffCh = nil
 			disablePoll = false
+		case <-stop:
+			return nil
 		}
 	}
 }
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+// lostBackend loses the connection to the display server while applying the
+// first rule.
+type lostBackend struct {
+	stop chan struct{}
+
+	applied    int
+	subscribed int
+}
+
+func (b *lostBackend) Outputs() (Outputs, error) {
+	return testOutputs, nil
+}
+
+func (b *lostBackend) Detect() (Outputs, error) {
+	return testOutputs, nil
+}
+
+func (b *lostBackend) Apply(rule Rule, current Outputs) error {
+	b.applied++
+	if b.applied == 1 {
+		return errConnectionLost
+	}
+
+	close(b.stop)
+	return nil
+}
+
+func (b *lostBackend) Disable(off Outputs) error {
+	return nil
+}
+
+func (b *lostBackend) Subscribe(ch chan<- Event, done <-chan struct{}) error {
+	b.subscribed++
+	return nil
+}
+
+func TestWatchReconnectOnApply(t *testing.T) {
+	cfg := globalOpts.cfg
+	t.Cleanup(func() {
+		globalOpts.cfg = cfg
+	})
+
+	globalOpts.cfg = &Config{
+		Rules: []Rule{{Name: "single", ConfigureSingle: "LVDS"}},
+	}
+
+	b := &lostBackend{stop: make(chan struct{})}
+	err := watch(b, b.stop)
+	if err != nil {
+		t.Fatalf("watch returned error: %v", err)
+	}
+
+	if b.applied != 2 {
+		t.Errorf("rule applied %d times, want 2", b.applied)
+	}
+
+	if b.subscribed != 2 {
+		t.Errorf("subscribed %d times, want 2", b.subscribed)
+	}
+}
@@ -16,8 +16,23 @@ import (
 type Config struct {
 	Rules []Rule
 
+	Backend string `yaml:"backend"`
+
+	// MutterPersistent makes the mutter backend store configurations
+	// permanently instead of only applying them temporarily.
+	MutterPersistent bool `yaml:"mutter_persistent"`
+
+	// LinkProviders lists the providers (e.g. DisplayLink docks) which are
+	// linked before the outputs are queried, so that their outputs appear.
+	LinkProviders []ProviderLink `yaml:"link_providers"`
+
 	ExecuteAfter []string `yaml:"execute_after"`
 	OnFailure    []string `yaml:"on_failure"`
+
+	// PNPIDs is a file in the format of pnp.ids from hwdata, the vendor
+	// names found there replace the embedded ones. The file installed by
+	// hwdata is used if it is not set.
+	PNPIDs string `yaml:"pnp_ids"`
 }
 
 // xdgConfigDir returns the config directory according to the xdg standard, see
@@ -81,15 +96,62 @@ func readConfig(name string) (Config, error) {
 
 // Valid returns an error if the config is invalid, ie a pattern is malformed.
 func (cfg Config) Valid() error {
+	if cfg.Backend != "" {
+		if _, ok := backends[cfg.Backend]; !ok {
+			return fmt.Errorf("unknown backend %q", cfg.Backend)
+		}
+	}
+
+	for _, link := range cfg.LinkProviders {
+		for _, pat := range []string{link.Sink, link.Source} {
+			if _, err := path.Match(pat, ""); err != nil {
+				return fmt.Errorf("provider pattern %q malformed: %v", pat, err)
+			}
+		}
+	}
 
 	for _, rule := range cfg.Rules {
-		for _, list := range [][]string{rule.OutputsPresent, rule.OutputsAbsent, rule.OutputsConnected, rule.OutputsDisconnected} {
+		for _, list := range [][]string{rule.OutputsPresent, rule.OutputsAbsent, rule.OutputsConnected, rule.OutputsDisconnected, rule.OutputsAudio} {
 			for _, pat := range list {
 				if _, err := path.Match(pat, ""); err != nil {
 					return fmt.Errorf("pattern %q malformed: %v", pat, err)
 				}
 			}
 		}
+
+		for pat, props := range rule.OutputsProperties {
+			if _, err := path.Match(pat, ""); err != nil {
+				return fmt.Errorf("pattern %q malformed: %v", pat, err)
+			}
+
+			for name, pat := range props {
+				if _, err := path.Match(pat, ""); err != nil {
+					return fmt.Errorf("pattern %q for property %v malformed: %v", pat, name, err)
+				}
+			}
+		}
+
+		for name, pat := range rule.OutputsVendor {
+			for _, p := range []string{name, pat} {
+				if _, err := path.Match(p, ""); err != nil {
+					return fmt.Errorf("pattern %q malformed: %v", p, err)
+				}
+			}
+		}
+
+		if rule.Screen != nil && *rule.Screen < 0 {
+			return fmt.Errorf("rule %v: invalid screen %d", rule.Name, *rule.Screen)
+		}
+
+		for name, n := range rule.SplitOutputs {
+			if n < 2 {
+				return fmt.Errorf("rule %v: output %v must be split into at least two monitors", rule.Name, name)
+			}
+		}
+
+		if len(rule.SplitOutputs) > 0 && rule.ConfigureCommand != "" {
+			return fmt.Errorf("rule %v: split_outputs cannot be used with configure_command", rule.Name)
+		}
 	}
 
 	return nil
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EDID is the decoded EDID of a monitor.
+type EDID struct {
+	// Vendor is the three letter PNP ID of the manufacturer, e.g. "SAM".
+	Vendor  string
+	Product uint16
+	Serial  uint32
+
+	// Week is the week of manufacture (zero if unknown), Year the year of
+	// manufacture. If ModelYear is set, Year is the model year instead.
+	Week      int
+	Year      int
+	ModelYear bool
+
+	// Version and Revision of the EDID structure, e.g. 1 and 4.
+	Version  int
+	Revision int
+
+	// Digital is set for monitors with a digital input.
+	Digital bool
+
+	// WidthCM and HeightCM are the physical size of the monitor, both are
+	// zero if unknown.
+	WidthCM, HeightCM int
+
+	// Gamma is the display gamma, zero if not defined.
+	Gamma float64
+
+	Features EDIDFeatures
+
+	// Timings lists the detailed timing descriptors, the first one is the
+	// preferred timing. The detailed timings from the extension blocks
+	// follow.
+	Timings []DetailedTiming
+
+	// RangeLimits is nil if the EDID does not contain the range limits
+	// descriptor.
+	RangeLimits *RangeLimits
+
+	// DisplayName and DisplaySerial are the contents of the display name and
+	// serial number descriptors.
+	DisplayName   string
+	DisplaySerial string
+
+	// CEA and DisplayID are set if the EDID contains the extension blocks.
+	CEA       *CEA
+	DisplayID *DisplayID
+
+	// ChecksumMismatch is set if the checksum of the base block is wrong.
+	// Some monitors ship such an EDID, it is decoded anyway.
+	ChecksumMismatch bool
+}
+
+// EDIDFeatures holds the feature support flags of the EDID.
+type EDIDFeatures struct {
+	// Standby, Suspend and ActiveOff are the supported DPMS states.
+	Standby   bool
+	Suspend   bool
+	ActiveOff bool
+
+	// SRGB is set if sRGB is the default color space.
+	SRGB bool
+
+	// PreferredTimingNative is set if the preferred timing is the native
+	// resolution of the monitor.
+	PreferredTimingNative bool
+
+	// Continuous is set if the monitor supports continuous frequencies.
+	Continuous bool
+}
+
+// DetailedTiming is a detailed timing descriptor of the EDID.
+type DetailedTiming struct {
+	// PixelClock is in kHz.
+	PixelClock int
+
+	HActive, HBlank, HSyncOffset, HSyncWidth int
+	VActive, VBlank, VSyncOffset, VSyncWidth int
+
+	// WidthMM and HeightMM is the size of the image.
+	WidthMM, HeightMM int
+
+	HBorder, VBorder int
+	Interlaced       bool
+}
+
+// Rate returns the refresh rate of the timing in Hz.
+func (t DetailedTiming) Rate() float64 {
+	total := (t.HActive + t.HBlank) * (t.VActive + t.VBlank)
+	if total == 0 {
+		return 0
+	}
+	return float64(t.PixelClock) * 1000 / float64(total)
+}
+
+func (t DetailedTiming) String() string {
+	return fmt.Sprintf("%dx%d@%.2f", t.HActive, t.VActive, t.Rate())
+}
+
+// RangeLimits are the display range limits of the EDID.
+type RangeLimits struct {
+	// MinVRate and MaxVRate are in Hz, MinHRate and MaxHRate in kHz.
+	MinVRate, MaxVRate int
+	MinHRate, MaxHRate int
+
+	// MaxPixelClock is in MHz.
+	MaxPixelClock int
+}
+
+func (r RangeLimits) String() string {
+	return fmt.Sprintf("%d-%d Hz V, %d-%d kHz H, max dotclock %d MHz",
+		r.MinVRate, r.MaxVRate, r.MinHRate, r.MaxHRate, r.MaxPixelClock)
+}
+
+// edidHeader is the fixed header every EDID starts with.
+var edidHeader = []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+// errEDIDChecksum is reported as a warning for an EDID with an invalid base
+// block checksum.
+var errEDIDChecksum = errors.New("EDID checksum mismatch")
+
+// edidBlockSize is the size of the base block and each extension block.
+const edidBlockSize = 128
+
+// ParseEDIDHex decodes the EDID from the hex string `xrandr` prints.
+func ParseEDIDHex(s string) (*EDID, error) {
+	var errEdidCorrupted = errors.New("corrupt EDID: " + s)
+	if len(s) < 32 {
+		return nil, errEdidCorrupted
+	}
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errEdidCorrupted
+	}
+
+	return ParseEDID(data)
+}
+
+// ParseEDID decodes an EDID with its extension blocks. All EDID 1.x versions
+// are supported, panels which only provide a DisplayID structure are
+// identified by its product identification. A base block with an invalid
+// checksum is decoded and marked with ChecksumMismatch.
+func ParseEDID(data []byte) (*EDID, error) {
+	if len(data) >= len(edidHeader) && string(data[:8]) != string(edidHeader) {
+		if isDisplayID(data) {
+			return parseDisplayIDOnly(data)
+		}
+		return nil, errors.New("invalid EDID header")
+	}
+
+	if len(data) < edidBlockSize {
+		return nil, fmt.Errorf("EDID too short: %d bytes", len(data))
+	}
+
+	e := &EDID{
+		Version:  int(data[18]),
+		Revision: int(data[19]),
+
+		ChecksumMismatch: !edidChecksum(data[:edidBlockSize]),
+	}
+
+	// EDID 2.0 uses a different structure, later 1.x revisions are
+	// compatible with 1.4
+	if e.Version != 1 {
+		return nil, fmt.Errorf("unknown EDID version %d.%d", e.Version, e.Revision)
+	}
+
+	vendor, err := decodeVendor(binary.BigEndian.Uint16(data[8:10]))
+	if err != nil {
+		return nil, err
+	}
+	e.Vendor = vendor
+
+	e.Product = binary.LittleEndian.Uint16(data[10:12])
+	e.Serial = binary.LittleEndian.Uint32(data[12:16])
+
+	switch data[16] {
+	case 0xff:
+		e.ModelYear = true
+	default:
+		e.Week = int(data[16])
+	}
+	e.Year = int(data[17]) + 1990
+
+	e.Digital = data[20]&0x80 != 0
+	e.WidthCM = int(data[21])
+	e.HeightCM = int(data[22])
+
+	if data[23] != 0xff {
+		e.Gamma = float64(int(data[23])+100) / 100
+	}
+
+	features := data[24]
+	e.Features = EDIDFeatures{
+		Standby:               features&0x80 != 0,
+		Suspend:               features&0x40 != 0,
+		ActiveOff:             features&0x20 != 0,
+		SRGB:                  features&0x04 != 0,
+		PreferredTimingNative: features&0x02 != 0,
+		Continuous:            features&0x01 != 0,
+	}
+
+	// decode the four descriptor blocks
+	for i := 0; i < 4; i++ {
+		e.parseDescriptor(data[54+i*18:54+18+i*18], e.Revision)
+	}
+
+	e.parseExtensions(data)
+
+	return e, nil
+}
+
+// Extension block tags.
+const (
+	edidTagCEA       = 0x02
+	edidTagDisplayID = 0x70
+)
+
+// edidChecksum returns true if the bytes of the block sum up to zero.
+func edidChecksum(block []byte) bool {
+	var sum byte
+	for _, b := range block {
+		sum += b
+	}
+	return sum == 0
+}
+
+// parseExtensions decodes the extension blocks following the base block.
+// Blocks with an invalid checksum and unknown blocks are skipped.
+func (e *EDID) parseExtensions(data []byte) {
+	count := int(data[126])
+	for i := 1; i <= count; i++ {
+		if (i+1)*edidBlockSize > len(data) {
+			V("EDID announces %d extension blocks, but only %d are present\n", count, i-1)
+			return
+		}
+
+		block := data[i*edidBlockSize : (i+1)*edidBlockSize]
+		if !edidChecksum(block) {
+			V("EDID extension block %d: checksum mismatch, skipping\n", i)
+			continue
+		}
+
+		switch block[0] {
+		case edidTagCEA:
+			parseCEA(e, block)
+		case edidTagDisplayID:
+			parseDisplayID(e, block)
+		}
+	}
+}
+
+// decodeVendor decodes the manufacturer ID, 'A' = 0b00001, 'B' = 0b00010, ...,
+// 'Z' = 0b11010.
+func decodeVendor(manuf uint16) (string, error) {
+	// The first bit is reserved and needs to be zero
+	if manuf&0x8000 != 0x0000 {
+		return "", errors.New("invalid manufacturer ID in EDID")
+	}
+
+	var vendor string
+	mask := uint16(0x7C00) // 0b0111110000000000
+	for i := uint(0); i <= 10; i += 5 {
+		number := ((manuf & (mask >> i)) >> (10 - i))
+		vendor += string(byte(number + 'A' - 1))
+	}
+
+	return vendor, nil
+}
+
+// parseDescriptor decodes one 18 byte descriptor block of an EDID 1.x with
+// the given revision.
+func (e *EDID) parseDescriptor(d []byte, revision int) {
+	// detailed timings start with the pixel clock, which is never zero
+	if d[0] != 0 || d[1] != 0 {
+		e.Timings = append(e.Timings, parseDetailedTiming(d))
+		return
+	}
+
+	// the other interesting descriptors start with three zeroes
+	if d[2] != 0 {
+		return
+	}
+
+	switch d[3] {
+	case 0xff: // display serial number
+		e.DisplaySerial = strings.TrimSpace(string(d[5:]))
+	case 0xfc: // display name
+		e.DisplayName = strings.TrimSpace(string(d[5:]))
+	case 0xfd: // display range limits
+		e.RangeLimits = parseRangeLimits(d, revision)
+	}
+}
+
+// parseDetailedTiming decodes a detailed timing descriptor.
+func parseDetailedTiming(d []byte) DetailedTiming {
+	return DetailedTiming{
+		PixelClock: int(binary.LittleEndian.Uint16(d[0:2])) * 10,
+
+		HActive:     int(d[2]) | int(d[4]&0xf0)<<4,
+		HBlank:      int(d[3]) | int(d[4]&0x0f)<<8,
+		VActive:     int(d[5]) | int(d[7]&0xf0)<<4,
+		VBlank:      int(d[6]) | int(d[7]&0x0f)<<8,
+		HSyncOffset: int(d[8]) | int(d[11]&0xc0)<<2,
+		HSyncWidth:  int(d[9]) | int(d[11]&0x30)<<4,
+		VSyncOffset: int(d[10]>>4) | int(d[11]&0x0c)<<2,
+		VSyncWidth:  int(d[10]&0x0f) | int(d[11]&0x03)<<4,
+
+		WidthMM:  int(d[12]) | int(d[14]&0xf0)<<4,
+		HeightMM: int(d[13]) | int(d[14]&0x0f)<<8,
+
+		HBorder:    int(d[15]),
+		VBorder:    int(d[16]),
+		Interlaced: d[17]&0x80 != 0,
+	}
+}
+
+// parseRangeLimits decodes the display range limits descriptor. EDID 1.4 uses
+// the flags in byte 4 to extend the rates by 255, it is reserved before.
+func parseRangeLimits(d []byte, revision int) *RangeLimits {
+	r := &RangeLimits{
+		MinVRate:      int(d[5]),
+		MaxVRate:      int(d[6]),
+		MinHRate:      int(d[7]),
+		MaxHRate:      int(d[8]),
+		MaxPixelClock: int(d[9]) * 10,
+	}
+
+	flags := d[4]
+	if revision < 4 {
+		flags = 0
+	}
+	if flags&0x02 != 0 {
+		r.MaxVRate += 255
+		if flags&0x01 != 0 {
+			r.MinVRate += 255
+		}
+	}
+	if flags&0x08 != 0 {
+		r.MaxHRate += 255
+		if flags&0x04 != 0 {
+			r.MinHRate += 255
+		}
+	}
+
+	return r
+}
+
+// Audio returns true if the monitor can play audio.
+func (e *EDID) Audio() bool {
+	return e.CEA != nil && (e.CEA.BasicAudio || len(e.CEA.Audio) > 0)
+}
+
+// MonitorID returns the monitor ID used in rules, it consists of the vendor,
+// product and serial numbers, the display name and serial.
+func (e *EDID) MonitorID() string {
+	return fmt.Sprintf("%s-%d-%d-%v-%v", e.Vendor, e.Product, e.Serial, e.DisplayName, e.DisplaySerial)
+}
+
+// Manufactured returns the date of manufacture like "week 12 of 2015".
+func (e *EDID) Manufactured() string {
+	switch {
+	case e.ModelYear:
+		return fmt.Sprintf("model year %d", e.Year)
+	case e.Week == 0:
+		return fmt.Sprintf("%d", e.Year)
+	default:
+		return fmt.Sprintf("week %d of %d", e.Week, e.Year)
+	}
+}
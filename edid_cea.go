@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// CEA holds the data decoded from the CEA-861 extension blocks of an EDID,
+// which are found in TVs and most monitors with HDMI inputs.
+type CEA struct {
+	Revision int
+
+	Underscan  bool
+	BasicAudio bool
+	YCbCr444   bool
+	YCbCr422   bool
+
+	// Video lists the short video descriptors, Audio the short audio
+	// descriptors.
+	Video []CEAVideoMode
+	Audio []CEAAudioFormat
+
+	// HDMI is set if the HDMI vendor-specific data block is present, HDR if
+	// the HDR static metadata block is present.
+	HDMI *HDMIInfo
+	HDR  *HDRInfo
+}
+
+// CEAVideoMode is a short video descriptor, which refers to one of the
+// standard CEA-861 modes by its video identification code (VIC).
+type CEAVideoMode struct {
+	VIC    int
+	Native bool
+}
+
+// ceaModes maps the VICs of the common CEA-861 modes to the mode and rate.
+var ceaModes = map[int]string{
+	1:   "640x480@60",
+	2:   "720x480@60",
+	3:   "720x480@60",
+	4:   "1280x720@60",
+	5:   "1920x1080i@60",
+	16:  "1920x1080@60",
+	17:  "720x576@50",
+	18:  "720x576@50",
+	19:  "1280x720@50",
+	20:  "1920x1080i@50",
+	31:  "1920x1080@50",
+	32:  "1920x1080@24",
+	33:  "1920x1080@25",
+	34:  "1920x1080@30",
+	63:  "1920x1080@120",
+	64:  "1920x1080@100",
+	93:  "3840x2160@24",
+	94:  "3840x2160@25",
+	95:  "3840x2160@30",
+	96:  "3840x2160@50",
+	97:  "3840x2160@60",
+	98:  "4096x2160@24",
+	99:  "4096x2160@25",
+	100: "4096x2160@30",
+	101: "4096x2160@50",
+	102: "4096x2160@60",
+}
+
+// Name returns the mode and rate for the VIC, e.g. "1920x1080@60". Unknown
+// VICs are returned as "VIC 42".
+func (m CEAVideoMode) Name() string {
+	if name, ok := ceaModes[m.VIC]; ok {
+		return name
+	}
+	return fmt.Sprintf("VIC %d", m.VIC)
+}
+
+func (m CEAVideoMode) String() string {
+	if m.Native {
+		return m.Name() + " (native)"
+	}
+	return m.Name()
+}
+
+// CEAAudioFormat is a short audio descriptor.
+type CEAAudioFormat struct {
+	// Format is the audio format code, e.g. 1 for LPCM.
+	Format   int
+	Channels int
+
+	// Rates lists the supported sampling rates in kHz.
+	Rates []float64
+}
+
+// audioFormats are the names of the audio format codes.
+var audioFormats = map[int]string{
+	1:  "LPCM",
+	2:  "AC-3",
+	3:  "MPEG-1",
+	4:  "MP3",
+	5:  "MPEG-2",
+	6:  "AAC LC",
+	7:  "DTS",
+	8:  "ATRAC",
+	9:  "DSD",
+	10: "E-AC-3",
+	11: "DTS-HD",
+	12: "MLP",
+	13: "DST",
+	14: "WMA Pro",
+}
+
+// audioRates are the sampling rates of the bits in the short audio
+// descriptor, in kHz.
+var audioRates = []float64{32, 44.1, 48, 88.2, 96, 176.4, 192}
+
+func (f CEAAudioFormat) String() string {
+	name, ok := audioFormats[f.Format]
+	if !ok {
+		name = fmt.Sprintf("format %d", f.Format)
+	}
+	return fmt.Sprintf("%s, %d channels, %v kHz", name, f.Channels, f.Rates)
+}
+
+// HDMIInfo is the content of the HDMI vendor-specific data block.
+type HDMIInfo struct {
+	// PhysicalAddress is the CEC address, e.g. "1.0.0.0".
+	PhysicalAddress string
+
+	// DeepColor lists the supported bits per color component above 8.
+	DeepColor []int
+
+	// MaxTMDSClock is in MHz, zero if not given.
+	MaxTMDSClock int
+}
+
+// HDRInfo is the content of the HDR static metadata data block.
+type HDRInfo struct {
+	// EOTFs lists the supported transfer functions, e.g. "PQ" or "HLG".
+	EOTFs []string
+
+	// MaxLuminance, MaxFrameAvgLuminance and MinLuminance are in cd/m², zero
+	// if not given.
+	MaxLuminance         float64
+	MaxFrameAvgLuminance float64
+	MinLuminance         float64
+}
+
+// CEA data block tags.
+const (
+	ceaTagAudio    = 1
+	ceaTagVideo    = 2
+	ceaTagVendor   = 3
+	ceaTagExtended = 7
+
+	ceaExtTagHDR = 6
+)
+
+// hdmiOUI is the IEEE OUI of the HDMI licensing body.
+const hdmiOUI = 0x000c03
+
+// eotfs are the names of the transfer functions in the HDR static metadata
+// block.
+var eotfs = []string{"SDR", "HDR", "PQ", "HLG"}
+
+// parseCEA decodes a CEA-861 extension block and adds the data to e.CEA,
+// the detailed timings are added to e.Timings.
+func parseCEA(e *EDID, block []byte) {
+	if e.CEA == nil {
+		e.CEA = &CEA{}
+	}
+	cea := e.CEA
+
+	cea.Revision = int(block[1])
+
+	if cea.Revision >= 2 {
+		flags := block[3]
+		cea.Underscan = cea.Underscan || flags&0x80 != 0
+		cea.BasicAudio = cea.BasicAudio || flags&0x40 != 0
+		cea.YCbCr444 = cea.YCbCr444 || flags&0x20 != 0
+		cea.YCbCr422 = cea.YCbCr422 || flags&0x10 != 0
+	}
+
+	offset := int(block[2])
+	if offset < 4 || offset > 127 {
+		// no detailed timings and no data blocks
+		return
+	}
+
+	// the data block collection is only present since revision 3
+	if cea.Revision >= 3 && offset > 4 {
+		parseCEADataBlocks(cea, block[4:offset])
+	}
+
+	// detailed timings follow until the padding, which starts with a zero
+	// pixel clock
+	for i := offset; i+18 <= 127; i += 18 {
+		d := block[i : i+18]
+		if d[0] == 0 && d[1] == 0 {
+			break
+		}
+		e.Timings = append(e.Timings, parseDetailedTiming(d))
+	}
+}
+
+// parseCEADataBlocks decodes the data block collection of a CEA-861
+// extension block.
+func parseCEADataBlocks(cea *CEA, data []byte) {
+	for len(data) > 0 {
+		tag := int(data[0] >> 5)
+		length := int(data[0] & 0x1f)
+		if 1+length > len(data) {
+			V("CEA data block with tag %d exceeds the block\n", tag)
+			return
+		}
+
+		payload := data[1 : 1+length]
+		data = data[1+length:]
+
+		switch tag {
+		case ceaTagAudio:
+			for i := 0; i+3 <= len(payload); i += 3 {
+				cea.Audio = append(cea.Audio, parseShortAudio(payload[i:i+3]))
+			}
+		case ceaTagVideo:
+			for _, b := range payload {
+				cea.Video = append(cea.Video, parseShortVideo(b))
+			}
+		case ceaTagVendor:
+			if len(payload) >= 5 && int(payload[0])|int(payload[1])<<8|int(payload[2])<<16 == hdmiOUI {
+				cea.HDMI = parseHDMI(payload)
+			}
+		case ceaTagExtended:
+			if len(payload) >= 3 && payload[0] == ceaExtTagHDR {
+				cea.HDR = parseHDR(payload[1:])
+			}
+		}
+	}
+}
+
+// parseShortVideo decodes a short video descriptor. For the VICs up to 64 the
+// highest bit marks a native mode.
+func parseShortVideo(b byte) CEAVideoMode {
+	if b&0x80 != 0 && b&0x7f <= 64 {
+		return CEAVideoMode{VIC: int(b & 0x7f), Native: true}
+	}
+	return CEAVideoMode{VIC: int(b)}
+}
+
+// parseShortAudio decodes a short audio descriptor.
+func parseShortAudio(d []byte) CEAAudioFormat {
+	f := CEAAudioFormat{
+		Format:   int(d[0]>>3) & 0x0f,
+		Channels: int(d[0]&0x07) + 1,
+	}
+
+	for i, rate := range audioRates {
+		if d[1]&(1<<uint(i)) != 0 {
+			f.Rates = append(f.Rates, rate)
+		}
+	}
+
+	return f
+}
+
+// parseHDMI decodes the HDMI vendor-specific data block, starting with the
+// OUI.
+func parseHDMI(d []byte) *HDMIInfo {
+	h := &HDMIInfo{
+		PhysicalAddress: fmt.Sprintf("%d.%d.%d.%d", d[3]>>4, d[3]&0x0f, d[4]>>4, d[4]&0x0f),
+	}
+
+	if len(d) >= 6 {
+		// bits 4 to 6 announce 30, 36 and 48 bits per pixel
+		for i, bpc := range []int{10, 12, 16} {
+			if d[5]&(0x10<<uint(i)) != 0 {
+				h.DeepColor = append(h.DeepColor, bpc)
+			}
+		}
+	}
+
+	if len(d) >= 7 {
+		h.MaxTMDSClock = int(d[6]) * 5
+	}
+
+	return h
+}
+
+// parseHDR decodes the HDR static metadata data block, following the
+// extended tag.
+func parseHDR(d []byte) *HDRInfo {
+	h := &HDRInfo{}
+
+	for i, name := range eotfs {
+		if d[0]&(1<<uint(i)) != 0 {
+			h.EOTFs = append(h.EOTFs, name)
+		}
+	}
+
+	// the luminance values are coded as described in CTA-861.3
+	if len(d) >= 3 && d[2] != 0 {
+		h.MaxLuminance = 50 * math.Pow(2, float64(d[2])/32)
+	}
+	if len(d) >= 4 && d[3] != 0 {
+		h.MaxFrameAvgLuminance = 50 * math.Pow(2, float64(d[3])/32)
+	}
+	if len(d) >= 5 && h.MaxLuminance > 0 {
+		cv := float64(d[4]) / 255
+		h.MinLuminance = h.MaxLuminance * cv * cv / 100
+	}
+
+	return h
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DisplayID holds the data decoded from the DisplayID extension blocks of an
+// EDID.
+type DisplayID struct {
+	// Version and Revision of the DisplayID structure, e.g. 1 and 3 or 2
+	// and 0.
+	Version  int
+	Revision int
+
+	// ProductType is the display product type (DisplayID 1.x) or the
+	// primary use case (DisplayID 2.0), e.g. "TV".
+	ProductType string
+
+	// Tiled is set if the tiled display topology block is present.
+	Tiled bool
+
+	// Vendor, Product, Serial, Week, Year and Name are taken from the product
+	// identification data block, Vendor is empty if it is missing.
+	Vendor  string
+	Product uint16
+	Serial  uint32
+	Week    int
+	Year    int
+	Name    string
+}
+
+// productTypes are the names of the display product types of DisplayID 1.x.
+var productTypes = []string{
+	"extension",
+	"test structure",
+	"display panel",
+	"standalone display",
+	"TV",
+	"repeater",
+	"direct drive monitor",
+}
+
+// useCases are the names of the primary use cases of DisplayID 2.0.
+var useCases = []string{
+	"extension",
+	"test structure",
+	"generic display",
+	"TV",
+	"desktop productivity",
+	"desktop gaming",
+	"presentation",
+	"virtual reality",
+	"augmented reality",
+}
+
+// DisplayID data block tags.
+const (
+	displayIDTagProductID   = 0x00
+	displayIDTagProductIDV2 = 0x20
+	displayIDTagTimingI     = 0x03
+	displayIDTagTiled       = 0x12
+	displayIDTagTimingVII   = 0x22
+	displayIDTagTiledV2     = 0x28
+)
+
+// Sizes of the parts of a DisplayID section in an EDID extension block.
+const (
+	displayIDTimingSize    = 20
+	displayIDSectionHeader = 4
+)
+
+// parseDisplayID decodes a DisplayID extension block and adds the data to
+// e.DisplayID, the detailed timings are added to e.Timings.
+func parseDisplayID(e *EDID, block []byte) {
+	// the section follows the extension tag, the checksum of the block
+	// covers it
+	d, err := parseDisplayIDSection(e, block[1:127])
+	if err != nil {
+		V("EDID extension block: %v\n", err)
+		return
+	}
+
+	// keep the version of the first block, later blocks only add data
+	if e.DisplayID == nil {
+		e.DisplayID = d
+	} else {
+		e.DisplayID.Tiled = e.DisplayID.Tiled || d.Tiled
+	}
+}
+
+// parseDisplayIDOnly decodes the DisplayID structure of a panel which does
+// not have an EDID, the monitor is identified by the product identification
+// data block.
+func parseDisplayIDOnly(data []byte) (*EDID, error) {
+	if len(data) < displayIDSectionHeader+1 {
+		return nil, fmt.Errorf("invalid DisplayID, too short: %d bytes", len(data))
+	}
+
+	length := displayIDSectionHeader + int(data[1]) + 1
+	if length > len(data) {
+		return nil, fmt.Errorf("invalid DisplayID, section exceeds the data: %d bytes", length)
+	}
+
+	if !edidChecksum(data[:length]) {
+		return nil, errors.New("checksum mismatch in DisplayID")
+	}
+
+	e := &EDID{}
+	d, err := parseDisplayIDSection(e, data[:length])
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Vendor == "" {
+		return nil, errors.New("no product identification in DisplayID")
+	}
+
+	e.DisplayID = d
+	e.Vendor = d.Vendor
+	e.Product = d.Product
+	e.Serial = d.Serial
+	e.Week = d.Week
+	e.Year = d.Year
+	e.DisplayName = d.Name
+
+	return e, nil
+}
+
+// isDisplayID returns true if data starts with the version of a DisplayID
+// 1.x or 2.x structure.
+func isDisplayID(data []byte) bool {
+	return len(data) > 0 && (data[0]>>4 == 1 || data[0]>>4 == 2)
+}
+
+// parseDisplayIDSection decodes a DisplayID section, which consists of a
+// header, the data blocks and the checksum. The detailed timings are added to
+// e.Timings.
+func parseDisplayIDSection(e *EDID, section []byte) (*DisplayID, error) {
+	length := int(section[1])
+	if displayIDSectionHeader+length+1 > len(section) {
+		return nil, fmt.Errorf("invalid DisplayID, section too long: %d bytes", length)
+	}
+
+	d := &DisplayID{
+		Version:  int(section[0] >> 4),
+		Revision: int(section[0] & 0x0f),
+	}
+
+	names := productTypes
+	if d.Version >= 2 {
+		names = useCases
+	}
+	if t := int(section[2]); t < len(names) {
+		d.ProductType = names[t]
+	} else {
+		d.ProductType = fmt.Sprintf("type %d", t)
+	}
+
+	data := section[displayIDSectionHeader : displayIDSectionHeader+length]
+	for len(data) >= 3 {
+		tag := data[0]
+		size := int(data[2])
+		if 3+size > len(data) {
+			V("DisplayID data block with tag 0x%02x exceeds the section\n", tag)
+			break
+		}
+
+		payload := data[3 : 3+size]
+		data = data[3+size:]
+
+		switch tag {
+		case displayIDTagProductID, displayIDTagProductIDV2:
+			d.parseProductID(payload, tag == displayIDTagProductIDV2)
+		case displayIDTagTimingI, displayIDTagTimingVII:
+			// type I timings use units of 10 kHz, type VII of 1 kHz
+			unit := 10
+			if tag == displayIDTagTimingVII {
+				unit = 1
+			}
+			for i := 0; i+displayIDTimingSize <= len(payload); i += displayIDTimingSize {
+				e.Timings = append(e.Timings, parseDisplayIDTiming(payload[i:i+displayIDTimingSize], unit))
+			}
+		case displayIDTagTiled, displayIDTagTiledV2:
+			d.Tiled = true
+		}
+	}
+
+	return d, nil
+}
+
+// parseProductID decodes the product identification data block. DisplayID
+// 1.x uses a PNP ID for the manufacturer, DisplayID 2.0 the IEEE OUI.
+func (d *DisplayID) parseProductID(payload []byte, oui bool) {
+	if len(payload) < 12 {
+		V("DisplayID product identification too short: %d bytes\n", len(payload))
+		return
+	}
+
+	if oui {
+		d.Vendor = fmt.Sprintf("%02X%02X%02X", payload[0], payload[1], payload[2])
+	} else {
+		d.Vendor = string(payload[:3])
+	}
+
+	d.Product = uint16(payload[3]) | uint16(payload[4])<<8
+	d.Serial = uint32(payload[5]) | uint32(payload[6])<<8 | uint32(payload[7])<<16 | uint32(payload[8])<<24
+	d.Week = int(payload[9])
+	d.Year = int(payload[10]) + 2000
+
+	name := payload[12:]
+	if n := int(payload[11]); n < len(name) {
+		name = name[:n]
+	}
+	d.Name = strings.TrimSpace(string(name))
+}
+
+// parseDisplayIDTiming decodes a type I or type VII timing, unit is the unit
+// of the pixel clock in kHz. All values are stored minus one.
+func parseDisplayIDTiming(d []byte, unit int) DetailedTiming {
+	u16 := func(i int) int {
+		return int(d[i]) | int(d[i+1])<<8
+	}
+
+	return DetailedTiming{
+		PixelClock: (int(d[0]) | int(d[1])<<8 | int(d[2])<<16 + 1) * unit,
+
+		HActive:     u16(4) + 1,
+		HBlank:      u16(6) + 1,
+		HSyncOffset: u16(8)&0x7fff + 1,
+		HSyncWidth:  u16(10) + 1,
+		VActive:     u16(12) + 1,
+		VBlank:      u16(14) + 1,
+		VSyncOffset: u16(16)&0x7fff + 1,
+		VSyncWidth:  u16(18) + 1,
+
+		Interlaced: d[3]&0x10 != 0,
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// cmnEDID is the EDID of a laptop panel and cmnEDIDHex its encoding.
+const cmnEDIDHex = "00ffffffffffff000daeb114000000000c190104951f117802ff35925552952925505400000001010101010101010101010101010101b43b804a71383440503c680034ad10000018000000fe004e3134304843452d4541410a20000000fe00434d4e0a202020202020202020000000fe004e3134304843452d4541410a2000a2"
+
+var cmnEDID = &EDID{
+	Vendor:   "CMN",
+	Product:  5297,
+	Week:     12,
+	Year:     2015,
+	Version:  1,
+	Revision: 4,
+	Digital:  true,
+	WidthCM:  31,
+	HeightCM: 17,
+	Gamma:    2.2,
+	Features: EDIDFeatures{PreferredTimingNative: true},
+	Timings: []DetailedTiming{
+		{
+			PixelClock: 152840,
+			HActive:    1920, HBlank: 330, HSyncOffset: 80, HSyncWidth: 60,
+			VActive: 1080, VBlank: 52, VSyncOffset: 6, VSyncWidth: 8,
+			WidthMM: 308, HeightMM: 173,
+		},
+	},
+}
+
+func TestParseEDID(t *testing.T) {
+	var tests = []struct {
+		edid string
+		want *EDID
+	}{
+		{cmnEDIDHex, cmnEDID},
+		{
+			"00ffffffffffff004c2dd4043432494b0b14010380351e782a6041a6564a9c251250542308008100814081809500a940b300010101011a3680a070381f4030203500132a2100001a000000fd00383c1e5110000a202020202020000000fc0053796e634d61737465720a2020000000ff004839585a3330353131380a202000f7",
+			&EDID{
+				Vendor:   "SAM",
+				Product:  1236,
+				Serial:   1263088180,
+				Week:     11,
+				Year:     2010,
+				Version:  1,
+				Revision: 3,
+				Digital:  true,
+				WidthCM:  53,
+				HeightCM: 30,
+				Gamma:    2.2,
+				Features: EDIDFeatures{ActiveOff: true, PreferredTimingNative: true},
+				Timings: []DetailedTiming{
+					{
+						PixelClock: 138500,
+						HActive:    1920, HBlank: 160, HSyncOffset: 48, HSyncWidth: 32,
+						VActive: 1080, VBlank: 31, VSyncOffset: 3, VSyncWidth: 5,
+						WidthMM: 531, HeightMM: 298,
+					},
+				},
+				RangeLimits: &RangeLimits{
+					MinVRate: 56, MaxVRate: 60,
+					MinHRate: 30, MaxHRate: 81,
+					MaxPixelClock: 160,
+				},
+				DisplayName:   "SyncMaster",
+				DisplaySerial: "H9XZ305118",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			e, err := ParseEDIDHex(test.edid)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(e, test.want) {
+				t.Fatalf("wrong EDID returned:\n  want %#v\n  got  %#v", test.want, e)
+			}
+		})
+	}
+}
+
+func TestParseEDIDErrors(t *testing.T) {
+	data, err := hex.DecodeString(cmnEDIDHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []func([]byte) []byte{
+		// too short
+		func(d []byte) []byte { return d[:100] },
+		// invalid header
+		func(d []byte) []byte { d[1] = 0; return d },
+		// EDID 2.0
+		func(d []byte) []byte { d[18] = 2; return d },
+	}
+
+	for _, modify := range tests {
+		t.Run("", func(t *testing.T) {
+			buf := modify(append([]byte{}, data...))
+			if _, err := ParseEDID(buf); err == nil {
+				t.Fatal("expected error not returned")
+			}
+		})
+	}
+}
+
+func TestParseEDIDChecksumMismatch(t *testing.T) {
+	data, err := hex.DecodeString(cmnEDIDHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ParseEDID(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want.ChecksumMismatch {
+		t.Fatal("checksum mismatch reported for valid EDID")
+	}
+
+	data[127]++
+	e, err := ParseEDID(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.ChecksumMismatch {
+		t.Error("checksum mismatch not reported")
+	}
+
+	if e.MonitorID() != want.MonitorID() {
+		t.Errorf("wrong monitor ID, want %q, got %q", want.MonitorID(), e.MonitorID())
+	}
+}
+
+func TestRangeLimitsOffsets(t *testing.T) {
+	d := []byte{0, 0, 0, 0xfd, 0x0a, 0x30, 0x5a, 0x1e, 0xa0, 0x3c, 0x0a, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20}
+	want := RangeLimits{MinVRate: 48, MaxVRate: 345, MinHRate: 30, MaxHRate: 415, MaxPixelClock: 600}
+
+	if r := parseRangeLimits(d, 4); *r != want {
+		t.Fatalf("wrong range limits, want %v, got %v", want, *r)
+	}
+}
+
+// samEDIDHex is the EDID of a monitor with a CEA-861 extension block.
+const samEDIDHex = "00ffffffffffff004c2d3a0a353233302417010380351e782af711a3564f9e280f5054bfef80714f81c0810081809500a9c0b3000101023a801871382d40582c4500132b2100001e011d007251d01e206e285500132b2100001e000000fd00324b1e5111000a202020202020000000fc00533234433335300a2020202020011802031af14690041f130312230907078301000066030c00100080011d00bc52d01e20b8285540132b2100001e8c0ad090204031200c405500132b210000188c0ad08a20e02d10103e9600132b21000018000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000099"
+
+func TestParseEDIDCEA(t *testing.T) {
+	e, err := ParseEDIDHex(samEDIDHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &CEA{
+		Revision:   3,
+		Underscan:  true,
+		BasicAudio: true,
+		YCbCr444:   true,
+		YCbCr422:   true,
+		Video: []CEAVideoMode{
+			{VIC: 16, Native: true}, {VIC: 4}, {VIC: 31}, {VIC: 19}, {VIC: 3}, {VIC: 18},
+		},
+		Audio: []CEAAudioFormat{
+			{Format: 1, Channels: 2, Rates: []float64{32, 44.1, 48}},
+		},
+		HDMI: &HDMIInfo{PhysicalAddress: "1.0.0.0"},
+	}
+
+	if !reflect.DeepEqual(e.CEA, want) {
+		t.Fatalf("wrong CEA data returned:\n  want %#v\n  got  %#v", want, e.CEA)
+	}
+
+	if !e.Audio() {
+		t.Error("audio support not detected")
+	}
+
+	var timings []string
+	for _, timing := range e.Timings {
+		timings = append(timings, timing.String())
+	}
+
+	wantTimings := []string{"1920x1080@60.00", "1280x720@60.00", "1280x720@50.00", "720x576@50.00", "720x480@59.94"}
+	if !reflect.DeepEqual(timings, wantTimings) {
+		t.Fatalf("wrong timings returned, want %v, got %v", wantTimings, timings)
+	}
+
+	if name := e.CEA.Video[0].String(); name != "1920x1080@60 (native)" {
+		t.Errorf("wrong name for VIC 16: %q", name)
+	}
+}
+
+// withExtension returns the base block of data followed by an extension
+// block containing payload, the checksums are adjusted.
+func withExtension(t testing.TB, data []byte, payload []byte) []byte {
+	if len(payload) > 127 {
+		t.Fatalf("payload too large: %d bytes", len(payload))
+	}
+
+	buf := append([]byte{}, data[:edidBlockSize]...)
+	buf[126] = 1
+	buf[127] = 0
+	buf[127] = -checksum(buf[:edidBlockSize])
+
+	block := make([]byte, edidBlockSize)
+	copy(block, payload)
+	block[127] = -checksum(block)
+
+	return append(buf, block...)
+}
+
+// checksum returns the sum of the bytes.
+func checksum(buf []byte) (sum byte) {
+	for _, b := range buf {
+		sum += b
+	}
+	return sum
+}
+
+func TestParseEDIDExtensions(t *testing.T) {
+	data, err := hex.DecodeString(cmnEDIDHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("hdr", func(t *testing.T) {
+		// CEA revision 3 with an HDR static metadata block: SDR, PQ and HLG,
+		// max luminance 50*2^(96/32) = 400 cd/m²
+		buf := withExtension(t, data, []byte{0x02, 0x03, 0x0b, 0x00, 0xe6, 0x06, 0x0d, 0x01, 0x60, 0x60, 0x00})
+
+		e, err := ParseEDID(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := &HDRInfo{EOTFs: []string{"SDR", "PQ", "HLG"}, MaxLuminance: 400, MaxFrameAvgLuminance: 400}
+		if e.CEA == nil || !reflect.DeepEqual(e.CEA.HDR, want) {
+			t.Fatalf("wrong HDR data returned: %#v", e.CEA)
+		}
+
+		if e.Audio() {
+			t.Error("audio support detected")
+		}
+	})
+
+	t.Run("displayid", func(t *testing.T) {
+		// DisplayID 1.3 for a TV with one type I timing (3840x2160, 594 MHz)
+		// and a tiled display topology block
+		buf := withExtension(t, data, []byte{
+			0x70, 0x13, 0x1a, 0x04, 0x00,
+			0x03, 0x00, 0x14,
+			0x07, 0xe8, 0x00, 0x88, 0xff, 0x0e, 0x2f, 0x02, 0xaf, 0x80, 0x57, 0x00,
+			0x6f, 0x08, 0x59, 0x00, 0x07, 0x80, 0x09, 0x00,
+			0x12, 0x00, 0x00,
+		})
+
+		e, err := ParseEDID(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := &DisplayID{Version: 1, Revision: 3, ProductType: "TV", Tiled: true}
+		if !reflect.DeepEqual(e.DisplayID, want) {
+			t.Fatalf("wrong DisplayID data returned:\n  want %#v\n  got  %#v", want, e.DisplayID)
+		}
+
+		if len(e.Timings) != 2 || e.Timings[1].String() != "3840x2160@60.00" {
+			t.Fatalf("wrong timings returned: %v", e.Timings)
+		}
+	})
+
+	t.Run("checksum", func(t *testing.T) {
+		buf := withExtension(t, data, []byte{0x02, 0x03, 0x04, 0x40})
+		buf[len(buf)-1]++
+
+		e, err := ParseEDID(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.CEA != nil {
+			t.Fatalf("extension block with invalid checksum was decoded: %#v", e.CEA)
+		}
+	})
+}
+
+func TestParseEDIDOldVersions(t *testing.T) {
+	data, err := hex.DecodeString(cmnEDIDHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, revision := range []byte{0, 1, 2} {
+		buf := append([]byte{}, data...)
+		buf[19] = revision
+		buf[127] -= revision - 4
+
+		e, err := ParseEDID(buf)
+		if err != nil {
+			t.Fatalf("EDID 1.%d: %v", revision, err)
+		}
+
+		if id := e.MonitorID(); id != "CMN-5297-0--" {
+			t.Errorf("EDID 1.%d: wrong monitor ID %q", revision, id)
+		}
+	}
+}
+
+// displayIDSection returns a DisplayID section with the given version,
+// product type and data blocks, the checksum is added.
+func displayIDSection(version, productType byte, blocks ...byte) []byte {
+	buf := append([]byte{version, byte(len(blocks)), productType, 0}, blocks...)
+	return append(buf, -checksum(buf))
+}
+
+func TestParseDisplayIDOnly(t *testing.T) {
+	var tests = []struct {
+		data      []byte
+		monitorID string
+	}{
+		{
+			// DisplayID 1.3, product identification for "DEL" 0x1234,
+			// serial 42, named "Panel"
+			displayIDSection(0x13, 0x02,
+				0x00, 0x00, 0x11,
+				'D', 'E', 'L', 0x34, 0x12, 42, 0, 0, 0, 10, 21, 5, 'P', 'a', 'n', 'e', 'l'),
+			"DEL-4660-42-Panel-",
+		},
+		{
+			// DisplayID 2.0, product identification with an OUI
+			displayIDSection(0x20, 0x04,
+				0x20, 0x00, 0x0c,
+				0x00, 0x0c, 0x03, 0x01, 0x00, 0x02, 0, 0, 0, 0, 22, 0),
+			"000C03-1-2--",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			e, err := ParseEDID(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if id := e.MonitorID(); id != test.monitorID {
+				t.Fatalf("wrong monitor ID, want %q, got %q", test.monitorID, id)
+			}
+
+			if e.DisplayID == nil {
+				t.Fatal("DisplayID not set")
+			}
+		})
+	}
+
+	// without product identification the panel cannot be identified
+	if _, err := ParseEDID(displayIDSection(0x13, 0x02)); err == nil {
+		t.Fatal("expected error not returned")
+	}
+
+	broken := displayIDSection(0x13, 0x02, 0x00, 0x00, 0x0c, 'D', 'E', 'L', 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	broken[len(broken)-1]++
+	if _, err := ParseEDID(broken); err == nil {
+		t.Fatal("expected error not returned for checksum mismatch")
+	}
+}
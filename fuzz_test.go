@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// addXrandrCorpus adds the recorded `xrandr` output in testdata/xrandr to the
+// seed corpus.
+func addXrandrCorpus(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "xrandr", "*.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		f.Fatal("no xrandr output found in testdata/xrandr")
+	}
+
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+}
+
+func FuzzRandrParse(f *testing.F) {
+	addXrandrCorpus(f)
+	f.Add([]byte(xrandrBrokenOutput))
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		_, _ = RandrParse(bytes.NewReader(buf))
+
+		_, outputs, _, err := RandrParseLenient(bytes.NewReader(buf))
+		if err != nil {
+			return
+		}
+
+		for _, output := range outputs {
+			if output.Name == "" {
+				t.Fatalf("output without name returned: %#v", output)
+			}
+		}
+	})
+}
+
+func FuzzParseModeLine(f *testing.F) {
+	for _, line := range []string{
+		"   1920x1080     60.02*+  48.02",
+		"   1920x1080     60.00 +  50.00    59.94",
+		"   2560x1440     59.95*+",
+		"   1024x768      sixty",
+		"   1x1           +",
+		"   640x480       *",
+	} {
+		f.Add(line)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		mode, err := parseModeLine(line)
+		if err != nil {
+			return
+		}
+
+		if mode.Name == "" {
+			t.Fatalf("mode without name returned for %q", line)
+		}
+
+		for _, rate := range mode.Rates {
+			if math.IsNaN(rate) || math.IsInf(rate, 0) || rate < 0 {
+				t.Fatalf("invalid rate %v returned for %q", rate, line)
+			}
+		}
+	})
+}
+
+func FuzzGenerateMonitorID(f *testing.F) {
+	f.Add(cmnEDIDHex)
+	f.Add(samEDIDHex)
+	f.Add("00ffffffffffff004c2d3a0a3532333")
+	f.Add("1302110000114445")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		_, _ = GenerateMonitorID(s)
+	})
+}
+
+func FuzzParseEDID(f *testing.F) {
+	for _, s := range []string{cmnEDIDHex, samEDIDHex} {
+		buf, err := hex.DecodeString(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+	f.Add(displayIDSection(0x13, 0x02, 0x00, 0x00, 0x0c, 'D', 'E', 'L', 0, 0, 0, 0, 0, 0, 0, 0, 0))
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		e, err := ParseEDID(buf)
+		if err != nil {
+			return
+		}
+
+		_ = e.MonitorID()
+	})
+}
@@ -2,10 +2,14 @@ module github.com/fd0/grobi
 
 require (
 	github.com/BurntSushi/xgb v0.0.0-20210121224620-deaf085860bc
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/jessevdk/go-flags v1.4.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
 	github.com/kr/pretty v0.1.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v2 v2.4.0
 )
 
-go 1.14
+go 1.18
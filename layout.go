@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LayoutOutput is an output which is enabled by a layout.
+type LayoutOutput struct {
+	Name    string
+	Mode    string
+	X, Y    int
+	Width   int
+	Height  int
+	Primary bool
+
+	// Rate is the refresh rate to use, zero selects the default rate.
+	Rate float64
+}
+
+// Layout describes the absolute arrangement of outputs a rule asks for.
+type Layout struct {
+	// Outputs is the list of outputs to enable, in the order of the rule.
+	Outputs []LayoutOutput
+
+	// Disable lists the outputs to switch off, honouring disable_order.
+	Disable []string
+
+	// Width and Height are the size of the screen needed for the layout.
+	Width  int
+	Height int
+}
+
+// ruleOutputs returns the list of configured outputs for a rule, and whether
+// these are placed in a row (left to right) or a column (top to bottom).
+func ruleOutputs(rule Rule) (outputs []string, row bool, err error) {
+	switch {
+	case rule.ConfigureSingle != "":
+		return []string{rule.ConfigureSingle}, false, nil
+	case len(rule.ConfigureRow) > 0:
+		return rule.ConfigureRow, true, nil
+	case len(rule.ConfigureColumn) > 0:
+		return rule.ConfigureColumn, false, nil
+	}
+
+	return nil, false, errors.New("empty monitor row configuration")
+}
+
+// splitOutputMode splits an entry of a rule like "LVDS1@1377x768" or
+// "HDMI1@2560x1440@144" into the output name, the mode and the refresh rate.
+// The mode is empty and the rate is zero if none was given.
+func splitOutputMode(entry string) (name, mode string, rate float64, err error) {
+	data := strings.SplitN(entry, "@", 3)
+	switch len(data) {
+	case 1:
+		return data[0], "", 0, nil
+	case 2:
+		return data[0], data[1], 0, nil
+	}
+
+	rate, err = strconv.ParseFloat(data[2], 64)
+	if err != nil || rate <= 0 {
+		return "", "", 0, fmt.Errorf("invalid refresh rate in %q", entry)
+	}
+
+	return data[0], data[1], rate, nil
+}
+
+// outputMode returns the mode of output with the given name.
+func outputMode(output Output, name string) (Mode, bool) {
+	for _, mode := range output.Modes {
+		if mode.Name == name {
+			return mode, true
+		}
+	}
+	return Mode{}, false
+}
+
+// closestRate returns the refresh rate of the named mode closest to rate. If
+// the mode or its rates are not known, rate is returned unchanged.
+func closestRate(output Output, name string, rate float64) float64 {
+	if rate == 0 {
+		return 0
+	}
+
+	mode, ok := outputMode(output, name)
+	if !ok {
+		return rate
+	}
+
+	return mode.ClosestRate(rate)
+}
+
+// disableOutputs returns the list of outputs which are currently enabled or
+// connected but are not part of active. Outputs listed in order come first.
+// For tiled monitors, all tiles are returned.
+func disableOutputs(current Outputs, active map[string]struct{}, order []string) []string {
+	disable := make(map[string]struct{})
+	for _, output := range current {
+		if !output.Connected && len(output.Modes) == 0 {
+			continue
+		}
+
+		// disable unneeded outputs that are still active
+		if _, ok := active[output.Name]; !ok {
+			disable[output.Name] = struct{}{}
+		}
+	}
+
+	var list []string
+
+	// honour disable_order if present
+	for _, name := range order {
+		if _, ok := disable[name]; ok {
+			output, _ := findOutput(current, name)
+			list = append(list, tileNames(output)...)
+			delete(disable, name)
+		}
+	}
+
+	// collect remaining outputs, in the order they were listed
+	for _, output := range current {
+		if _, ok := disable[output.Name]; ok {
+			list = append(list, tileNames(output)...)
+			delete(disable, output.Name)
+		}
+	}
+
+	return list
+}
+
+// ParseModeSize returns the width and height encoded in a mode name like
+// "1920x1080" or "1920x1080i".
+func ParseModeSize(name string) (width, height int, err error) {
+	data := strings.SplitN(name, "x", 2)
+	if len(data) != 2 {
+		return 0, 0, fmt.Errorf("invalid mode name %q", name)
+	}
+
+	h := data[1]
+	for i, c := range h {
+		if c < '0' || c > '9' {
+			h = h[:i]
+			break
+		}
+	}
+
+	width, err = strconv.Atoi(data[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mode name %q", name)
+	}
+
+	height, err = strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mode name %q", name)
+	}
+
+	return width, height, nil
+}
+
+// autoMode returns the mode `xrandr --auto` selects: the preferred mode, or
+// the first one if no mode is preferred.
+func autoMode(output Output) (Mode, bool) {
+	for _, mode := range output.Modes {
+		if mode.Default {
+			return mode, true
+		}
+	}
+
+	if len(output.Modes) > 0 {
+		return output.Modes[0], true
+	}
+
+	return Mode{}, false
+}
+
+// rotatedSize returns the size of the area on the screen a mode of width x
+// height covers. The current rotation of an output is kept when it is
+// reconfigured, so the size is swapped for outputs rotated left or right.
+func rotatedSize(output Output, width, height int) (int, int) {
+	if output.Rotation == "left" || output.Rotation == "right" {
+		return height, width
+	}
+	return width, height
+}
+
+// findOutput returns the output with the given name.
+func findOutput(outputs Outputs, name string) (Output, bool) {
+	for _, output := range outputs {
+		if output.Name == name {
+			return output, true
+		}
+	}
+	return Output{}, false
+}
+
+// ComputeLayout returns the absolute positions and modes of the outputs
+// configured by rule, given the currently available outputs.
+func ComputeLayout(rule Rule, current Outputs) (Layout, error) {
+	return ComputeScaledLayout(rule, current, nil)
+}
+
+// ComputeScaledLayout works like ComputeLayout, but places the outputs in
+// logical coordinates: the size of each output is divided by its scale factor
+// found in scales. Outputs not listed are not scaled. A tiled monitor is
+// replaced by its tiles, unless a mode for the top left tile alone is used.
+func ComputeScaledLayout(rule Rule, current Outputs, scales map[string]float64) (Layout, error) {
+	outputs, row, err := ruleOutputs(rule)
+	if err != nil {
+		return Layout{}, err
+	}
+
+	var layout Layout
+	active := make(map[string]struct{})
+
+	// unused tiles of tiled monitors
+	var unusedTiles []string
+
+	var x, y int
+	for _, entry := range outputs {
+		name, modeName, rate, err := splitOutputMode(entry)
+		if err != nil {
+			return Layout{}, err
+		}
+		active[name] = struct{}{}
+
+		output, ok := findOutput(current, name)
+		if !ok {
+			return Layout{}, fmt.Errorf("output %v not found", name)
+		}
+
+		if modeName == "" {
+			if rate != 0 {
+				return Layout{}, fmt.Errorf("output %v: refresh rate requires a mode", name)
+			}
+
+			mode, ok := autoMode(output)
+			if !ok {
+				return Layout{}, fmt.Errorf("output %v has no modes", name)
+			}
+			modeName = mode.Name
+		}
+
+		width, height, err := ParseModeSize(modeName)
+		if err != nil {
+			return Layout{}, fmt.Errorf("output %v: %w", name, err)
+		}
+		width, height = rotatedSize(output, width, height)
+
+		switch {
+		case len(output.Tiles) > 0 && modeName == output.Modes[0].Name:
+			layout.Outputs = append(layout.Outputs, tileLayout(output, x, y, rate, rule.Primary == name)...)
+		case len(output.Tiles) > 0:
+			unusedTiles = append(unusedTiles, tileNames(output)[1:]...)
+			fallthrough
+		default:
+			layout.Outputs = append(layout.Outputs, LayoutOutput{
+				Name:    name,
+				Mode:    modeName,
+				X:       x,
+				Y:       y,
+				Width:   width,
+				Height:  height,
+				Rate:    closestRate(output, modeName, rate),
+				Primary: rule.Primary == name,
+			})
+		}
+
+		logicalWidth, logicalHeight := width, height
+		if scale, ok := scales[name]; ok && scale > 0 {
+			logicalWidth = int(float64(width)/scale + 0.5)
+			logicalHeight = int(float64(height)/scale + 0.5)
+		}
+
+		if x+logicalWidth > layout.Width {
+			layout.Width = x + logicalWidth
+		}
+		if y+logicalHeight > layout.Height {
+			layout.Height = y + logicalHeight
+		}
+
+		if row {
+			x += logicalWidth
+		} else {
+			y += logicalHeight
+		}
+	}
+
+	layout.Disable = append(disableOutputs(current, active, rule.DisableOrder), unusedTiles...)
+
+	return layout, nil
+}
+
+// tileLayout returns the tiles of a tiled monitor placed at x, y. Only the
+// top left tile is made primary.
+func tileLayout(output Output, x, y int, rate float64, primary bool) []LayoutOutput {
+	var list []LayoutOutput
+	for i, tile := range output.Tiles {
+		t, _ := tile.Tile()
+		dx, dy := tileOffset(output.Tiles, t)
+		mode := tileMode(tile, t)
+
+		list = append(list, LayoutOutput{
+			Name:    tile.Name,
+			Mode:    mode,
+			X:       x + dx,
+			Y:       y + dy,
+			Width:   t.Width,
+			Height:  t.Height,
+			Rate:    closestRate(tile, mode, rate),
+			Primary: primary && i == 0,
+		})
+	}
+	return list
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeLayout(t *testing.T) {
+	var tests = []struct {
+		rule   Rule
+		layout Layout
+	}{
+		{
+			Rule{
+				ConfigureRow: []string{"VGA", "HDMI@1024x768"},
+				Primary:      "HDMI",
+			},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "VGA", Mode: "1280x1024", Width: 1280, Height: 1024},
+					{Name: "HDMI", Mode: "1024x768", X: 1280, Width: 1024, Height: 768, Primary: true},
+				},
+				Disable: []string{"LVDS", "DP3"},
+				Width:   2304,
+				Height:  1024,
+			},
+		},
+		{
+			Rule{
+				ConfigureColumn: []string{"HDMI", "LVDS"},
+				DisableOrder:    []string{"VGA"},
+			},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "HDMI", Mode: "1920x1080", Width: 1920, Height: 1080},
+					{Name: "LVDS", Mode: "1377x768", Y: 1080, Width: 1377, Height: 768},
+				},
+				Disable: []string{"VGA", "DP3"},
+				Width:   1920,
+				Height:  1848,
+			},
+		},
+		{
+			Rule{
+				ConfigureSingle: "LVDS",
+			},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "LVDS", Mode: "1377x768", Width: 1377, Height: 768},
+				},
+				Disable: []string{"VGA", "HDMI", "DP3"},
+				Width:   1377,
+				Height:  768,
+			},
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"HDMI@1920x1080@144", "VGA@1280x1024@75"},
+			},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "HDMI", Mode: "1920x1080", Width: 1920, Height: 1080, Rate: 143.98},
+					{Name: "VGA", Mode: "1280x1024", X: 1920, Width: 1280, Height: 1024, Rate: 75},
+				},
+				Disable: []string{"LVDS", "DP3"},
+				Width:   3200,
+				Height:  1080,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			layout, err := ComputeLayout(test.rule, testOutputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(layout, test.layout) {
+				t.Fatalf("wrong layout returned:\n  want %+v\n  got  %+v", test.layout, layout)
+			}
+		})
+	}
+}
+
+func TestComputeLayoutErrors(t *testing.T) {
+	var tests = []Rule{
+		{},
+		{ConfigureSingle: "DP2-1"},
+		{ConfigureRow: []string{"LVDS", "DP9"}},
+		{ConfigureSingle: "HDMI@auto"},
+		{ConfigureSingle: "HDMI@1920x1080@fast"},
+		{ConfigureSingle: "HDMI@@60"},
+	}
+
+	for _, rule := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ComputeLayout(rule, testOutputs)
+			if err == nil {
+				t.Fatal("expected error not returned")
+			}
+		})
+	}
+}
+
+func TestComputeLayoutRotated(t *testing.T) {
+	outputs := Outputs{
+		{
+			Name:      "DP1",
+			Connected: true,
+			Modes:     []Mode{{Name: "1920x1080", Default: true, Active: true}},
+			Rotation:  "left",
+		},
+		{
+			Name:      "DP2",
+			Connected: true,
+			Modes:     []Mode{{Name: "1920x1080", Default: true, Active: true}},
+			Rotation:  "inverted",
+		},
+	}
+
+	layout, err := ComputeLayout(Rule{ConfigureRow: []string{"DP1", "DP2"}}, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Layout{
+		Outputs: []LayoutOutput{
+			{Name: "DP1", Mode: "1920x1080", Width: 1080, Height: 1920},
+			{Name: "DP2", Mode: "1920x1080", X: 1080, Width: 1920, Height: 1080},
+		},
+		Width:  3000,
+		Height: 1920,
+	}
+
+	if !reflect.DeepEqual(layout, want) {
+		t.Fatalf("wrong layout returned:\n  want %+v\n  got  %+v", want, layout)
+	}
+}
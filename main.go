@@ -19,8 +19,10 @@ type GlobalOptions struct {
 	ActivePoll   bool   `short:"a" long:"active-poll"                 description:"Force xrandr to re-detect outputs during polling"`
 	Pause        uint   `short:"p" long:"pause"       default:"0"     description:"Number of seconds to pause after a change was executed"`
 	Logfile      string `short:"l" long:"logfile"                     description:"Write log to file"`
+	Backend      string `short:"b" long:"backend"                     description:"Display backend to use (randr, xrandr, sway, wlroots, mutter, kscreen, hyprland), overrides the config file"`
 
 	cfg     *Config
+	backend Backend
 	log     *log.Logger
 	logfile *log.Logger
 }
@@ -35,10 +37,62 @@ func (gopts *GlobalOptions) ReadConfigfile() error {
 		return fmt.Errorf("error reading config file: %v", err)
 	}
 
+	// the vendor names are only shown to the user, the built-in list is
+	// used if the file cannot be read
+	pnpIDs := cfg.PNPIDs
+	if pnpIDs == "" {
+		pnpIDs = defaultPNPIDs
+	}
+
+	err = LoadPNPIDs(pnpIDs)
+	if err != nil {
+		V("unable to read PNP IDs, using the built-in list: %v\n", err)
+	}
+
 	gopts.cfg = &cfg
 	return nil
 }
 
+// OpenBackend returns the backend selected on the command line or in the
+// config file, which must have been read before if it is needed.
+func (gopts *GlobalOptions) OpenBackend() (Backend, error) {
+	if gopts.backend != nil {
+		return gopts.backend, nil
+	}
+
+	name := gopts.Backend
+	if name == "" && gopts.cfg != nil {
+		name = gopts.cfg.Backend
+	}
+	if name == "" {
+		name = defaultBackend
+	}
+
+	V("using backend %v\n", name)
+
+	backend, err := NewBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if gopts.cfg != nil && len(gopts.cfg.LinkProviders) > 0 {
+		// report an unsupported backend only once instead of every time
+		// the providers are linked
+		if _, ok := backend.(ProviderBackend); !ok {
+			fmt.Fprintf(os.Stderr, "backend %v does not support link_providers, ignoring it\n", name)
+			gopts.cfg.LinkProviders = nil
+		}
+
+		err = linkProviders(backend, gopts.cfg.LinkProviders)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+
+	gopts.backend = backend
+	return backend, nil
+}
+
 // RunCommand runs the given command or prints the arguments to stdout if
 // globalOpts.DryRun is true.
 func RunCommand(cmd *exec.Cmd) error {
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Monitor is a RANDR 1.5 monitor as listed by `xrandr --listmonitors`. Window
+// managers place windows on monitors instead of outputs, so an output can be
+// split into several virtual monitors.
+type Monitor struct {
+	Name string
+
+	// Automatic is set for the monitors the X server creates for each active
+	// output.
+	Automatic bool
+	Primary   bool
+
+	Geometry          Geometry
+	WidthMM, HeightMM int
+
+	// Outputs lists the outputs showing the monitor, it is empty for
+	// monitors which only cover a part of an output.
+	Outputs []string
+}
+
+// Monitors is a list of monitors.
+type Monitors []Monitor
+
+// monitorPrefix starts the names of the monitors grobi creates, only these
+// are removed again.
+const monitorPrefix = "grobi-"
+
+// MonitorBackend is implemented by backends which can split an output into
+// several virtual monitors (see Rule.SplitOutputs).
+type MonitorBackend interface {
+	// Monitors returns the list of monitors.
+	Monitors() (Monitors, error)
+
+	// SetMonitors removes the named monitors and creates the ones in add.
+	SetMonitors(add Monitors, remove []string) error
+}
+
+// parseMonitorGeometry parses the geometry of a monitor like
+// "1920/309x1080/174+0+0".
+func parseMonitorGeometry(s string) (g Geometry, widthMM, heightMM int, err error) {
+	// split off the offsets
+	i := strings.IndexAny(s, "+-")
+	if i < 0 {
+		return Geometry{}, 0, 0, fmt.Errorf("invalid monitor geometry %q", s)
+	}
+
+	size := strings.SplitN(s[:i], "x", 2)
+	if len(size) != 2 {
+		return Geometry{}, 0, 0, fmt.Errorf("invalid monitor geometry %q", s)
+	}
+
+	var values [4]int
+	for j, dim := range size {
+		data := strings.SplitN(dim, "/", 2)
+		if len(data) != 2 {
+			return Geometry{}, 0, 0, fmt.Errorf("invalid monitor geometry %q", s)
+		}
+
+		for k, v := range data {
+			values[2*j+k], err = strconv.Atoi(v)
+			if err != nil {
+				return Geometry{}, 0, 0, fmt.Errorf("invalid monitor geometry %q", s)
+			}
+		}
+	}
+
+	g, ok := parseGeometry(fmt.Sprintf("%dx%d%s", values[0], values[2], s[i:]))
+	if !ok {
+		return Geometry{}, 0, 0, fmt.Errorf("invalid monitor geometry %q", s)
+	}
+
+	return g, values[1], values[3], nil
+}
+
+// parseMonitorLine parses a line like " 0: +*eDP-1 1920/309x1080/174+0+0  eDP-1".
+func parseMonitorLine(line string) (Monitor, error) {
+	words := strings.Fields(line)
+	if len(words) < 3 || !strings.HasSuffix(words[0], ":") {
+		return Monitor{}, fmt.Errorf("invalid monitor line: %s", line)
+	}
+
+	var m Monitor
+	name := words[1]
+	if strings.HasPrefix(name, "+") {
+		m.Automatic = true
+		name = name[1:]
+	}
+	if strings.HasPrefix(name, "*") {
+		m.Primary = true
+		name = name[1:]
+	}
+	m.Name = name
+
+	var err error
+	m.Geometry, m.WidthMM, m.HeightMM, err = parseMonitorGeometry(words[2])
+	if err != nil {
+		return Monitor{}, err
+	}
+
+	if len(words) > 3 {
+		m.Outputs = words[3:]
+	}
+
+	return m, nil
+}
+
+// ParseMonitors returns the monitors listed by `xrandr --listmonitors`.
+func ParseMonitors(rd io.Reader) (Monitors, error) {
+	var monitors Monitors
+
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "Monitors:") {
+			continue
+		}
+
+		m, err := parseMonitorLine(line)
+		if err != nil {
+			return nil, err
+		}
+		monitors = append(monitors, m)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return monitors, nil
+}
+
+// xrandrMonitors runs `xrandr --listmonitors` and returns the parsed output.
+func xrandrMonitors() (Monitors, error) {
+	cmd := exec.Command("xrandr", "--listmonitors")
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMonitors(bytes.NewReader(buf))
+}
+
+// BuildCommandMonitors returns a call to `xrandr` which removes the named
+// monitors and creates the ones in add.
+func BuildCommandMonitors(add Monitors, remove []string) *exec.Cmd {
+	var args []string
+	for _, name := range remove {
+		args = append(args, "--delmonitor", name)
+	}
+
+	for _, m := range add {
+		outputs := "none"
+		if len(m.Outputs) > 0 {
+			outputs = strings.Join(m.Outputs, ",")
+		}
+
+		geometry := fmt.Sprintf("%d/%dx%d/%d+%d+%d",
+			m.Geometry.Width, m.WidthMM, m.Geometry.Height, m.HeightMM,
+			m.Geometry.X, m.Geometry.Y)
+		args = append(args, "--setmonitor", m.Name, geometry, outputs)
+	}
+
+	return exec.Command("xrandr", args...)
+}
+
+// SplitMonitors returns the virtual monitors for the outputs rule splits.
+// Each output is cut into equally wide monitors, the first one is assigned
+// to the output so that it replaces the monitor the X server creates.
+func SplitMonitors(rule Rule, current Outputs) (Monitors, error) {
+	if len(rule.SplitOutputs) == 0 {
+		return nil, nil
+	}
+
+	layout, err := ComputeLayout(rule, current)
+	if err != nil {
+		return nil, err
+	}
+
+	var monitors Monitors
+	for _, lo := range layout.Outputs {
+		n, ok := rule.SplitOutputs[lo.Name]
+		if !ok {
+			continue
+		}
+
+		if n < 2 {
+			return nil, fmt.Errorf("output %v: invalid number of monitors %d", lo.Name, n)
+		}
+
+		output, _ := findOutput(current, lo.Name)
+
+		for i := 0; i < n; i++ {
+			m := Monitor{
+				Name: fmt.Sprintf("%s%s-%d", monitorPrefix, lo.Name, i),
+				Geometry: Geometry{
+					X:      lo.X + i*lo.Width/n,
+					Y:      lo.Y,
+					Width:  (i+1)*lo.Width/n - i*lo.Width/n,
+					Height: lo.Height,
+				},
+				WidthMM:  (i+1)*output.WidthMM/n - i*output.WidthMM/n,
+				HeightMM: output.HeightMM,
+			}
+
+			if i == 0 {
+				m.Outputs = []string{lo.Name}
+			}
+
+			monitors = append(monitors, m)
+		}
+	}
+
+	for name := range rule.SplitOutputs {
+		if _, ok := findMonitorOutput(monitors, name); !ok {
+			return nil, fmt.Errorf("output %v is split but not enabled by rule %v", name, rule.Name)
+		}
+	}
+
+	return monitors, nil
+}
+
+// findMonitorOutput returns the monitor assigned to the output.
+func findMonitorOutput(monitors Monitors, output string) (Monitor, bool) {
+	for _, m := range monitors {
+		for _, name := range m.Outputs {
+			if name == output {
+				return m, true
+			}
+		}
+	}
+	return Monitor{}, false
+}
+
+// diffMonitors returns the monitors of want which need to be created and the
+// names of the monitors created by grobi earlier which need to be removed.
+// Monitors which are already present are left alone.
+func diffMonitors(current, want Monitors) (add Monitors, remove []string) {
+	present := make(map[string]Monitor)
+	for _, m := range current {
+		if strings.HasPrefix(m.Name, monitorPrefix) {
+			present[m.Name] = m
+		}
+	}
+
+	for _, m := range want {
+		cur, ok := present[m.Name]
+		if ok && cur.Geometry == m.Geometry {
+			delete(present, m.Name)
+			continue
+		}
+
+		if ok {
+			remove = append(remove, m.Name)
+			delete(present, m.Name)
+		}
+		add = append(add, m)
+	}
+
+	for _, m := range current {
+		if _, ok := present[m.Name]; ok {
+			remove = append(remove, m.Name)
+		}
+	}
+
+	return add, remove
+}
+
+// splitState records whether monitors created by grobi may exist. Listing
+// the monitors runs xrandr, so rules without split_outputs only do it until
+// it is known that there are none to remove.
+var splitState struct {
+	known   bool
+	created bool
+}
+
+// applyMonitors creates the virtual monitors rule asks for and removes the
+// ones grobi created for other rules. The outputs are already configured, so
+// when the monitors cannot be listed, the error is only printed.
+func applyMonitors(backend Backend, rule Rule, current Outputs) error {
+	split := len(rule.SplitOutputs) > 0
+
+	mb, ok := backend.(MonitorBackend)
+	if !ok {
+		if split {
+			return fmt.Errorf("rule %v: backend does not support split_outputs", rule.Name)
+		}
+		return nil
+	}
+
+	if !split && splitState.known && !splitState.created {
+		return nil
+	}
+
+	want, err := SplitMonitors(rule, current)
+	if err != nil {
+		return err
+	}
+
+	monitors, err := mb.Monitors()
+	if err != nil {
+		if split {
+			fmt.Fprintf(os.Stderr, "rule %v: listing monitors failed: %v\n", rule.Name, err)
+		} else {
+			V("listing monitors failed: %v\n", err)
+		}
+		return nil
+	}
+
+	add, remove := diffMonitors(monitors, want)
+	if len(add) > 0 || len(remove) > 0 {
+		V("remove monitors %v, add monitors %v\n", remove, add)
+
+		splitState.known = false
+		err = mb.SetMonitors(add, remove)
+		if err != nil {
+			return err
+		}
+	}
+
+	splitState.known, splitState.created = true, len(want) > 0
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const xrandrListMonitors = `Monitors: 4
+ 0: +*eDP-1 1920/309x1080/174+0+0  eDP-1
+ 1: grobi-DP-1-0 2560/597x1440/336+1920+0  DP-1
+ 2: grobi-DP-1-1 2560/597x1440/336+4480+0
+ 3: +HDMI-1 1920/531x1080/299+8960+-100  HDMI-1
+`
+
+func TestParseMonitors(t *testing.T) {
+	monitors, err := ParseMonitors(strings.NewReader(xrandrListMonitors))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Monitors{
+		{
+			Name: "eDP-1", Automatic: true, Primary: true,
+			Geometry: Geometry{Width: 1920, Height: 1080},
+			WidthMM:  309, HeightMM: 174,
+			Outputs: []string{"eDP-1"},
+		},
+		{
+			Name:     "grobi-DP-1-0",
+			Geometry: Geometry{X: 1920, Width: 2560, Height: 1440},
+			WidthMM:  597, HeightMM: 336,
+			Outputs: []string{"DP-1"},
+		},
+		{
+			Name:     "grobi-DP-1-1",
+			Geometry: Geometry{X: 4480, Width: 2560, Height: 1440},
+			WidthMM:  597, HeightMM: 336,
+		},
+		{
+			Name: "HDMI-1", Automatic: true,
+			Geometry: Geometry{X: 8960, Y: -100, Width: 1920, Height: 1080},
+			WidthMM:  531, HeightMM: 299,
+			Outputs: []string{"HDMI-1"},
+		},
+	}
+
+	if !reflect.DeepEqual(monitors, want) {
+		t.Fatalf("wrong monitors returned:\n  want %+v\n  got  %+v", want, monitors)
+	}
+}
+
+func TestParseMonitorsErrors(t *testing.T) {
+	var tests = []string{
+		" 0: +*eDP-1",
+		" 0: eDP-1 1920x1080+0+0",
+		" 0: eDP-1 1920/309x1080/174",
+		" 0: eDP-1 1920/abcx1080/174+0+0",
+		"eDP-1 1920/309x1080/174+0+0",
+	}
+
+	for _, line := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseMonitors(strings.NewReader(line))
+			if err == nil {
+				t.Fatalf("expected error not returned for %q", line)
+			}
+		})
+	}
+}
+
+func TestSplitMonitors(t *testing.T) {
+	rule := Rule{
+		ConfigureRow: []string{"LVDS", "HDMI"},
+		SplitOutputs: map[string]int{"HDMI": 3},
+	}
+
+	monitors, err := SplitMonitors(rule, testOutputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Monitors{
+		{Name: "grobi-HDMI-0", Geometry: Geometry{X: 1377, Width: 640, Height: 1080}, Outputs: []string{"HDMI"}},
+		{Name: "grobi-HDMI-1", Geometry: Geometry{X: 2017, Width: 640, Height: 1080}},
+		{Name: "grobi-HDMI-2", Geometry: Geometry{X: 2657, Width: 640, Height: 1080}},
+	}
+
+	if !reflect.DeepEqual(monitors, want) {
+		t.Fatalf("wrong monitors returned:\n  want %+v\n  got  %+v", want, monitors)
+	}
+
+	rule.SplitOutputs = map[string]int{"VGA": 2}
+	_, err = SplitMonitors(rule, testOutputs)
+	if err == nil {
+		t.Fatal("expected error not returned for output not enabled by the rule")
+	}
+}
+
+func TestDiffMonitors(t *testing.T) {
+	current, err := ParseMonitors(strings.NewReader(xrandrListMonitors))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		want   Monitors
+		add    Monitors
+		remove []string
+	}{
+		{
+			want:   nil,
+			remove: []string{"grobi-DP-1-0", "grobi-DP-1-1"},
+		},
+		{
+			want: current[1:3],
+		},
+		{
+			want: Monitors{
+				current[1],
+				{Name: "grobi-DP-1-1", Geometry: Geometry{X: 4480, Width: 1280, Height: 1440}},
+				{Name: "grobi-DP-1-2", Geometry: Geometry{X: 5760, Width: 1280, Height: 1440}},
+			},
+			add: Monitors{
+				{Name: "grobi-DP-1-1", Geometry: Geometry{X: 4480, Width: 1280, Height: 1440}},
+				{Name: "grobi-DP-1-2", Geometry: Geometry{X: 5760, Width: 1280, Height: 1440}},
+			},
+			remove: []string{"grobi-DP-1-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			add, remove := diffMonitors(current, test.want)
+			if !reflect.DeepEqual(add, test.add) {
+				t.Errorf("wrong monitors to add:\n  want %+v\n  got  %+v", test.add, add)
+			}
+			if !reflect.DeepEqual(remove, test.remove) {
+				t.Errorf("wrong monitors to remove:\n  want %v\n  got  %v", test.remove, remove)
+			}
+		})
+	}
+}
+
+func TestBuildCommandMonitors(t *testing.T) {
+	add := Monitors{
+		{Name: "grobi-DP-1-0", Geometry: Geometry{X: 1920, Width: 2560, Height: 1440}, WidthMM: 597, HeightMM: 336, Outputs: []string{"DP-1"}},
+		{Name: "grobi-DP-1-1", Geometry: Geometry{X: 4480, Width: 2560, Height: 1440}, WidthMM: 597, HeightMM: 336},
+	}
+
+	cmd := BuildCommandMonitors(add, []string{"grobi-HDMI-1-0"})
+	want := []string{"xrandr",
+		"--delmonitor", "grobi-HDMI-1-0",
+		"--setmonitor", "grobi-DP-1-0", "2560/597x1440/336+1920+0", "DP-1",
+		"--setmonitor", "grobi-DP-1-1", "2560/597x1440/336+4480+0", "none",
+	}
+
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("wrong arguments:\n  want %q\n  got  %q", want, cmd.Args)
+	}
+}
+
+// fakeMonitorBackend records the calls to the MonitorBackend methods.
+type fakeMonitorBackend struct {
+	Backend
+
+	monitors Monitors
+	err      error
+	listed   int
+	set      int
+}
+
+func (b *fakeMonitorBackend) Monitors() (Monitors, error) {
+	b.listed++
+	return b.monitors, b.err
+}
+
+func (b *fakeMonitorBackend) SetMonitors(add Monitors, remove []string) error {
+	b.set++
+	b.monitors = add
+	return nil
+}
+
+func TestApplyMonitors(t *testing.T) {
+	t.Cleanup(func() {
+		splitState.known, splitState.created = false, false
+	})
+
+	plain := Rule{ConfigureRow: []string{"LVDS", "HDMI"}}
+	split := Rule{
+		ConfigureRow: []string{"LVDS", "HDMI"},
+		SplitOutputs: map[string]int{"HDMI": 2},
+	}
+
+	b := &fakeMonitorBackend{err: errors.New("xrandr not found")}
+
+	// a listing failure does not fail the rule
+	for _, rule := range []Rule{plain, split} {
+		err := applyMonitors(b, rule, testOutputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.err = nil
+	var tests = []struct {
+		rule   Rule
+		listed int
+		set    int
+	}{
+		// the monitors are listed until it is known that grobi created none
+		{plain, 1, 0},
+		{plain, 0, 0},
+		{split, 1, 1},
+		{split, 1, 0},
+		{plain, 1, 1},
+		{plain, 0, 0},
+	}
+
+	for i, test := range tests {
+		b.listed, b.set = 0, 0
+
+		err := applyMonitors(b, test.rule, testOutputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b.listed != test.listed || b.set != test.set {
+			t.Errorf("test %d: want %d listings and %d changes, got %d and %d",
+				i, test.listed, test.set, b.listed, b.set)
+		}
+	}
+}
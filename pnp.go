@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// defaultPNPIDs is the complete list of PNP IDs installed by hwdata, it is
+// read if the config file does not name another one.
+const defaultPNPIDs = "/usr/share/hwdata/pnp.ids"
+
+// embeddedPNPIDs holds the names of common monitor manufacturers, in the
+// format of the pnp.ids file shipped with hwdata. It is only a fallback for
+// systems without hwdata, matching vendors by their PNP ID works without it.
+//
+//go:embed pnp.ids
+var embeddedPNPIDs string
+
+var (
+	pnpOnce    sync.Once
+	pnpVendors map[string]string
+)
+
+// ParsePNPIDs reads a list of PNP IDs and vendor names in the format of the
+// pnp.ids file from hwdata: each line consists of the three letter ID, a tab
+// and the name. Empty lines and lines starting with # are ignored.
+func ParsePNPIDs(rd io.Reader) (map[string]string, error) {
+	ids := make(map[string]string)
+
+	sc := bufio.NewScanner(rd)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		data := strings.SplitN(line, "\t", 2)
+		if len(data) != 2 || len(data[0]) != 3 || strings.TrimSpace(data[1]) == "" {
+			return nil, fmt.Errorf("line %d: invalid PNP ID entry %q", lineNo, line)
+		}
+
+		ids[strings.ToUpper(data[0])] = strings.TrimSpace(data[1])
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// pnpTable returns the vendor names, the embedded list is parsed on first
+// use.
+func pnpTable() map[string]string {
+	pnpOnce.Do(func() {
+		ids, err := ParsePNPIDs(strings.NewReader(embeddedPNPIDs))
+		if err != nil {
+			panic(fmt.Sprintf("embedded pnp.ids is invalid: %v", err))
+		}
+		pnpVendors = ids
+	})
+
+	return pnpVendors
+}
+
+// LoadPNPIDs reads the vendor names from the file, they are added to the
+// embedded list and replace the names found there.
+func LoadPNPIDs(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	ids, err := ParsePNPIDs(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+
+	V("read %d PNP IDs from %v\n", len(ids), filename)
+
+	table := pnpTable()
+	for id, name := range ids {
+		table[id] = name
+	}
+
+	return nil
+}
+
+// LookupPNPID returns the name of the vendor with the three letter PNP ID,
+// e.g. "Samsung Electric Company" for "SAM".
+func LookupPNPID(id string) (string, bool) {
+	name, ok := pnpTable()[strings.ToUpper(id)]
+	return name, ok
+}
+
+// VendorName returns the name of the monitor manufacturer. If the vendor is
+// not a known PNP ID it is returned unchanged, Wayland compositors often
+// report the name already.
+func (o Output) VendorName() string {
+	if name, ok := LookupPNPID(o.Vendor); ok {
+		return name
+	}
+	return o.Vendor
+}
+
+// matchVendor returns true if the vendor or its name matches the shell
+// pattern.
+func (o Output) matchVendor(pattern string) (bool, error) {
+	if o.Vendor == "" {
+		return false, nil
+	}
+
+	m, err := path.Match(pattern, o.Vendor)
+	if err != nil || m {
+		return m, err
+	}
+
+	return path.Match(pattern, o.VendorName())
+}
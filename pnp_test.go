@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePNPIDs(t *testing.T) {
+	var tests = []struct {
+		data string
+		ids  map[string]string
+	}{
+		{
+			"SAM\tSamsung Electric Company\n",
+			map[string]string{"SAM": "Samsung Electric Company"},
+		},
+		{
+			"# comment\n\nDEL\tDell Inc.\ngsm\tGoldstar Company Ltd  \n",
+			map[string]string{"DEL": "Dell Inc.", "GSM": "Goldstar Company Ltd"},
+		},
+		{
+			"",
+			map[string]string{},
+		},
+	}
+
+	for i, test := range tests {
+		ids, err := ParsePNPIDs(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("test %d: wrong IDs returned:\n  want %v\n  got  %v", i, test.ids, ids)
+		}
+	}
+}
+
+func TestParsePNPIDsErrors(t *testing.T) {
+	for i, data := range []string{
+		"SAM Samsung Electric Company\n",
+		"SAMS\tSamsung Electric Company\n",
+		"SAM\t\n",
+	} {
+		_, err := ParsePNPIDs(strings.NewReader(data))
+		if err == nil {
+			t.Errorf("test %d: expected error not found", i)
+		}
+	}
+}
+
+func TestLookupPNPID(t *testing.T) {
+	var tests = []struct {
+		id   string
+		name string
+		ok   bool
+	}{
+		{"SAM", "Samsung Electric Company", true},
+		{"sam", "Samsung Electric Company", true},
+		{"DEL", "Dell Inc.", true},
+		{"XYZ", "", false},
+		{"", "", false},
+	}
+
+	for i, test := range tests {
+		name, ok := LookupPNPID(test.id)
+		if name != test.name || ok != test.ok {
+			t.Errorf("test %d: wrong name for %q: want %q, %v, got %q, %v", i, test.id, test.name, test.ok, name, ok)
+		}
+	}
+}
+
+func TestLoadPNPIDs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pnp.ids")
+	err := ioutil.WriteFile(filename, []byte("ZZQ\tTest Vendor\nSHP\tSharp\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, _ := LookupPNPID("SHP")
+	defer func() {
+		table := pnpTable()
+		delete(table, "ZZQ")
+		table["SHP"] = old
+	}()
+
+	err = LoadPNPIDs(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]string{
+		"ZZQ": "Test Vendor",
+		"SHP": "Sharp",
+		"SAM": "Samsung Electric Company",
+	} {
+		name, _ := LookupPNPID(id)
+		if name != want {
+			t.Errorf("wrong name for %v: want %q, got %q", id, want, name)
+		}
+	}
+
+	err = LoadPNPIDs(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Errorf("expected error for missing file not found")
+	}
+}
+
+func TestOutputVendorName(t *testing.T) {
+	var tests = []struct {
+		vendor string
+		name   string
+	}{
+		{"SAM", "Samsung Electric Company"},
+		{"Dell Inc.", "Dell Inc."},
+		{"", ""},
+	}
+
+	for i, test := range tests {
+		name := Output{Vendor: test.vendor}.VendorName()
+		if name != test.name {
+			t.Errorf("test %d: wrong name for %q: want %q, got %q", i, test.vendor, test.name, name)
+		}
+	}
+}
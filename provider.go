@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+)
+
+// Provider is a RANDR provider as listed by `xrandr --listproviders`, e.g. a
+// GPU or a DisplayLink dock. The outputs of a provider which cannot render by
+// itself only show up once it is linked to a source provider.
+type Provider struct {
+	Index int
+	ID    uint32
+	Name  string
+
+	// Capabilities is a bit mask of randr.ProviderCapability* values.
+	Capabilities uint32
+
+	Crtcs   int
+	Outputs int
+
+	// Associated is the number of providers this one is linked to.
+	Associated int
+}
+
+// SourceOutput returns true if the provider can render for other providers.
+func (p Provider) SourceOutput() bool {
+	return p.Capabilities&randr.ProviderCapabilitySourceOutput != 0
+}
+
+// SinkOutput returns true if the provider can display what another provider
+// renders.
+func (p Provider) SinkOutput() bool {
+	return p.Capabilities&randr.ProviderCapabilitySinkOutput != 0
+}
+
+// Providers is a list of providers.
+type Providers []Provider
+
+// ProviderLink configures which providers are linked with `xrandr
+// --setprovideroutputsource`. Both are shell patterns for the provider names.
+// An empty Sink matches all providers which can display the output of
+// another, an empty Source selects the first provider which can render for
+// others.
+type ProviderLink struct {
+	Sink   string `yaml:"sink"`
+	Source string `yaml:"source"`
+}
+
+// ProviderBackend is implemented by backends which can link providers.
+type ProviderBackend interface {
+	// Providers returns the list of providers.
+	Providers() (Providers, error)
+
+	// LinkProvider makes sink display the output rendered by source.
+	LinkProvider(sink, source Provider) error
+}
+
+// parseProviderLine parses a line like "Provider 1: id: 0x116 cap: 0x2, Sink
+// Output crtcs: 1 outputs: 1 associated providers: 0 name:modesetting".
+func parseProviderLine(line string) (Provider, error) {
+	var p Provider
+
+	i := strings.Index(line, " name:")
+	if i < 0 {
+		return Provider{}, fmt.Errorf("provider name not found: %s", line)
+	}
+	p.Name = line[i+len(" name:"):]
+	line = line[:i]
+
+	// the names of the capabilities are printed after the bit mask, they
+	// are not needed
+	i = strings.Index(line, " crtcs:")
+	if i < 0 {
+		return Provider{}, fmt.Errorf("number of crtcs not found: %s", line)
+	}
+	head, tail := line[:i], line[i+1:]
+
+	var id, caps string
+	_, err := fmt.Sscanf(head, "Provider %d: id: %s cap: %s", &p.Index, &id, &caps)
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid provider line %q: %w", line, err)
+	}
+
+	v, err := strconv.ParseUint(strings.TrimPrefix(id, "0x"), 16, 32)
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid provider id %q", id)
+	}
+	p.ID = uint32(v)
+
+	v, err = strconv.ParseUint(strings.TrimPrefix(strings.TrimSuffix(caps, ","), "0x"), 16, 32)
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid provider capabilities %q", caps)
+	}
+	p.Capabilities = uint32(v)
+
+	_, err = fmt.Sscanf(tail, "crtcs: %d outputs: %d associated providers: %d",
+		&p.Crtcs, &p.Outputs, &p.Associated)
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid provider line %q: %w", line, err)
+	}
+
+	return p, nil
+}
+
+// ParseProviders returns the providers listed by `xrandr --listproviders`.
+func ParseProviders(rd io.Reader) (Providers, error) {
+	var providers Providers
+
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "Provider ") {
+			continue
+		}
+
+		p, err := parseProviderLine(line)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return providers, nil
+}
+
+// xrandrProviders runs `xrandr --listproviders` and returns the parsed output.
+func xrandrProviders() (Providers, error) {
+	buf, err := exec.Command("xrandr", "--listproviders").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseProviders(bytes.NewReader(buf))
+}
+
+// BuildCommandLinkProvider returns a call to `xrandr` which makes sink
+// display the output rendered by source.
+func BuildCommandLinkProvider(sink, source Provider) *exec.Cmd {
+	return exec.Command("xrandr", "--setprovideroutputsource",
+		fmt.Sprintf("0x%x", sink.ID), fmt.Sprintf("0x%x", source.ID))
+}
+
+// matchProvider returns true if the name of the provider matches pattern, an
+// empty pattern matches all providers.
+func matchProvider(pattern string, p Provider) bool {
+	if pattern == "" {
+		return true
+	}
+
+	m, err := path.Match(pattern, p.Name)
+	return err == nil && m
+}
+
+// providerPair is a sink and the source it needs to be linked to.
+type providerPair struct {
+	Sink, Source Provider
+}
+
+// pendingLinks returns the providers which need to be linked according to
+// links. Providers which are already linked to another one are left alone.
+func pendingLinks(providers Providers, links []ProviderLink) []providerPair {
+	var pairs []providerPair
+	for _, link := range links {
+		var source Provider
+		var found bool
+		for _, p := range providers {
+			if p.SourceOutput() && matchProvider(link.Source, p) {
+				source, found = p, true
+				break
+			}
+		}
+
+		if !found {
+			V("no source provider matching %q found\n", link.Source)
+			continue
+		}
+
+		for _, p := range providers {
+			if p.ID == source.ID || !p.SinkOutput() || p.Associated > 0 {
+				continue
+			}
+
+			if matchProvider(link.Sink, p) {
+				pairs = append(pairs, providerPair{Sink: p, Source: source})
+			}
+		}
+	}
+
+	return pairs
+}
+
+// linkProviders links the providers as configured in links, so that their
+// outputs can be used.
+func linkProviders(backend Backend, links []ProviderLink) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	pb, ok := backend.(ProviderBackend)
+	if !ok {
+		return fmt.Errorf("backend does not support link_providers")
+	}
+
+	providers, err := pb.Providers()
+	if err != nil {
+		return fmt.Errorf("listing providers: %w", err)
+	}
+
+	for _, pair := range pendingLinks(providers, links) {
+		V("link provider %v (0x%x) to source %v (0x%x)\n",
+			pair.Sink.Name, pair.Sink.ID, pair.Source.Name, pair.Source.ID)
+
+		err = pb.LinkProvider(pair.Sink, pair.Source)
+		if err != nil {
+			return fmt.Errorf("linking provider %v: %w", pair.Sink.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// providersChanged returns true if ev reports that providers or other RANDR
+// resources were added or removed, new providers may need to be linked.
+func providersChanged(ev Event) bool {
+	change, ok := ev.Event.(RandrChange)
+	if !ok {
+		xev, isX := ev.Event.(xgb.Event)
+		if !isX {
+			return false
+		}
+
+		change, ok = decodeRandrEvent(xev)
+		if !ok {
+			return false
+		}
+	}
+
+	return change.Kind == RandrProviderChange || change.Kind == RandrResourceChange
+}
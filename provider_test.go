@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/xgb/randr"
+)
+
+const xrandrListProviders = `Providers: number : 3
+Provider 0: id: 0x47 cap: 0xf, Source Output, Sink Output, Source Offload, Sink Offload crtcs: 3 outputs: 4 associated providers: 1 name:Intel
+Provider 1: id: 0x116 cap: 0x2, Sink Output crtcs: 1 outputs: 1 associated providers: 0 name:modesetting
+Provider 2: id: 0x1d4 cap: 0x2, Sink Output crtcs: 1 outputs: 1 associated providers: 1 name:DisplayLink Dock
+`
+
+func TestParseProviders(t *testing.T) {
+	providers, err := ParseProviders(strings.NewReader(xrandrListProviders))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Providers{
+		{Index: 0, ID: 0x47, Name: "Intel", Capabilities: 0xf, Crtcs: 3, Outputs: 4, Associated: 1},
+		{Index: 1, ID: 0x116, Name: "modesetting", Capabilities: 0x2, Crtcs: 1, Outputs: 1},
+		{Index: 2, ID: 0x1d4, Name: "DisplayLink Dock", Capabilities: 0x2, Crtcs: 1, Outputs: 1, Associated: 1},
+	}
+
+	if !reflect.DeepEqual(providers, want) {
+		t.Fatalf("wrong providers returned:\n  want %+v\n  got  %+v", want, providers)
+	}
+
+	if !providers[0].SourceOutput() || !providers[0].SinkOutput() {
+		t.Errorf("wrong capabilities for %v", providers[0].Name)
+	}
+
+	if providers[1].SourceOutput() || !providers[1].SinkOutput() {
+		t.Errorf("wrong capabilities for %v", providers[1].Name)
+	}
+}
+
+func TestParseProvidersErrors(t *testing.T) {
+	var tests = []string{
+		"Provider 0: id: 0x47 cap: 0xf crtcs: 3 outputs: 4 associated providers: 1",
+		"Provider 0: id: 0x47 cap: 0xf name:Intel",
+		"Provider 0: id: xyz cap: 0xf crtcs: 3 outputs: 4 associated providers: 1 name:Intel",
+		"Provider 0: id: 0x47 cap: 0xf crtcs: many outputs: 4 associated providers: 1 name:Intel",
+	}
+
+	for _, line := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseProviders(strings.NewReader(line))
+			if err == nil {
+				t.Fatalf("expected error not returned for %q", line)
+			}
+		})
+	}
+}
+
+func TestPendingLinks(t *testing.T) {
+	providers, err := ParseProviders(strings.NewReader(xrandrListProviders))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		links []ProviderLink
+		sinks []string
+	}{
+		{
+			[]ProviderLink{{}},
+			[]string{"modesetting"},
+		},
+		{
+			[]ProviderLink{{Sink: "Display*"}},
+			nil,
+		},
+		{
+			[]ProviderLink{{Sink: "modesetting", Source: "Intel"}},
+			[]string{"modesetting"},
+		},
+		{
+			[]ProviderLink{{Source: "NVIDIA*"}},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			var sinks []string
+			for _, pair := range pendingLinks(providers, test.links) {
+				if pair.Source.Name != "Intel" {
+					t.Errorf("wrong source %v for sink %v", pair.Source.Name, pair.Sink.Name)
+				}
+				sinks = append(sinks, pair.Sink.Name)
+			}
+
+			if !reflect.DeepEqual(sinks, test.sinks) {
+				t.Fatalf("wrong sinks returned, want %v, got %v", test.sinks, sinks)
+			}
+		})
+	}
+}
+
+func TestBuildCommandLinkProvider(t *testing.T) {
+	cmd := BuildCommandLinkProvider(Provider{ID: 0x116}, Provider{ID: 0x47})
+	want := []string{"xrandr", "--setprovideroutputsource", "0x116", "0x47"}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("wrong arguments, want %q, got %q", want, cmd.Args)
+	}
+}
+
+func TestProvidersChanged(t *testing.T) {
+	var tests = []struct {
+		ev      Event
+		changed bool
+	}{
+		{Event{Event: RandrChange{Kind: RandrProviderChange}}, true},
+		{Event{Event: RandrChange{Kind: RandrResourceChange}}, true},
+		{Event{Event: RandrChange{Kind: RandrOutputChange}}, false},
+		{Event{Event: randr.NotifyEvent{SubCode: randr.NotifyProviderChange}}, true},
+		{Event{Event: randr.NotifyEvent{SubCode: randr.NotifyCrtcChange}}, false},
+		{Event{Event: randr.ScreenChangeNotifyEvent{}}, false},
+		{Event{Event: "monitoradded>>DP-1"}, false},
+		{Event{}, false},
+	}
+
+	for i, test := range tests {
+		if changed := providersChanged(test.ev); changed != test.changed {
+			t.Errorf("test %d: wrong result, want %v, got %v", i, test.changed, changed)
+		}
+	}
+}
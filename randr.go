@@ -3,14 +3,15 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -21,6 +22,134 @@ type Output struct {
 	Connected bool
 	Primary   bool
 	MonitorID string
+
+	// Vendor is the manufacturer of the monitor: the PNP ID from the EDID,
+	// e.g. "SAM", or the make a Wayland compositor reports, which may be the
+	// name already. It is empty if unknown, see VendorName.
+	Vendor string
+
+	// Unknown is set if the connection state of the output is not known,
+	// e.g. xrandr prints "unknown connection". Connected is false then.
+	Unknown bool
+
+	// Geometry, Rotation and Reflection describe the area of the screen an
+	// active output shows. Rotation is one of "normal", "left", "inverted"
+	// and "right", Reflection is one of "", "X axis", "Y axis" and
+	// "X and Y axis".
+	Geometry   Geometry
+	Rotation   string
+	Reflection string
+
+	// WidthMM and HeightMM are the physical size of the monitor.
+	WidthMM, HeightMM int
+
+	// Properties holds the output properties, e.g. "link-status" or
+	// "non-desktop". The EDID is not included, it is decoded into EDID.
+	Properties Properties
+
+	// EDID is the decoded EDID of the monitor, nil if none is available.
+	EDID *EDID
+
+	// Details is only set by a detailed query (`xrandr --verbose`).
+	Details *OutputDetails
+
+	// Screen is the index of the X screen the output belongs to.
+	Screen int
+
+	// Warnings lists the problems found for the output, e.g. lines lenient
+	// parsing skipped or an EDID which cannot be decoded.
+	Warnings []ParseWarning
+
+	// Tiles is only set for the logical output which represents a tiled
+	// monitor, it lists the outputs of the tiles (see MergeTiles).
+	Tiles Outputs
+}
+
+// Property is an output property together with the values it supports.
+type Property struct {
+	Value string
+
+	// Supported lists the values the property can be set to, it is empty
+	// for properties which accept a range or any value.
+	Supported []string
+
+	// Range is the range of valid values for numeric properties, it is nil
+	// if the property has no range.
+	Range *PropertyRange
+}
+
+// PropertyRange is the range of valid values of a numeric property.
+type PropertyRange struct {
+	Min, Max int64
+}
+
+func (p Property) String() string {
+	switch {
+	case p.Range != nil:
+		return fmt.Sprintf("%s (range %d..%d)", p.Value, p.Range.Min, p.Range.Max)
+	case len(p.Supported) > 0:
+		return fmt.Sprintf("%s (supported: %s)", p.Value, strings.Join(p.Supported, ", "))
+	}
+	return p.Value
+}
+
+// Properties maps property names to properties.
+type Properties map[string]Property
+
+// Names returns the sorted list of property names.
+func (p Properties) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Match returns true iff all properties in want are present and their values
+// match the shell patterns.
+func (p Properties) Match(want map[string]string) bool {
+	for name, pattern := range want {
+		prop, ok := p[name]
+		if !ok {
+			return false
+		}
+
+		m, err := path.Match(pattern, prop.Value)
+		if err != nil || !m {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Geometry is the position and size of an output on the screen.
+type Geometry struct {
+	X, Y          int
+	Width, Height int
+}
+
+func (g Geometry) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", g.Width, g.Height, g.X, g.Y)
+}
+
+// Placement returns the geometry, rotation and reflection of the output in
+// the format used by xrandr, e.g. "1920x1080+0+0 left X axis". It is empty
+// for inactive outputs.
+func (o Output) Placement() string {
+	if o.Geometry == (Geometry{}) {
+		return ""
+	}
+
+	str := o.Geometry.String()
+	if o.Rotation != "" {
+		str += " " + o.Rotation
+	}
+	if o.Reflection != "" {
+		str += " " + o.Reflection
+	}
+	return str
 }
 
 func (o Output) String() string {
@@ -35,6 +164,14 @@ func (o Output) String() string {
 	}
 	str := fmt.Sprintf("%s%s", o.Name, con)
 
+	if placement := o.Placement(); placement != "" {
+		str += " " + placement
+	}
+
+	if o.WidthMM > 0 || o.HeightMM > 0 {
+		str += fmt.Sprintf(" %dmm x %dmm", o.WidthMM, o.HeightMM)
+	}
+
 	if len(o.Modes) > 0 {
 		for _, m := range o.Modes {
 			if m.Active || m.Default {
@@ -51,11 +188,11 @@ func (o Output) String() string {
 
 // Equals checks whether the two Outputs are equal.
 func (o Output) Equals(other Output) bool {
-	if o.Name != other.Name || o.Connected != other.Connected {
+	if o.Name != other.Name || o.Connected != other.Connected || o.Unknown != other.Unknown {
 		return false
 	}
 
-	if len(o.Modes) != len(other.Modes) {
+	if len(o.Modes) != len(other.Modes) || len(o.Tiles) != len(other.Tiles) {
 		return false
 	}
 
@@ -63,7 +200,7 @@ func (o Output) Equals(other Output) bool {
 		m1 := o.Modes[i]
 		m2 := other.Modes[i]
 
-		if m1 != m2 {
+		if !m1.Equals(m2) {
 			return false
 		}
 	}
@@ -82,23 +219,67 @@ func (o Output) Active() bool {
 	return false
 }
 
+// ActiveMode returns the active mode of the output.
+func (o Output) ActiveMode() (Mode, bool) {
+	for _, mode := range o.Modes {
+		if mode.Active {
+			return mode, true
+		}
+	}
+
+	return Mode{}, false
+}
+
 // Outputs is a list of outputs.
 type Outputs []Output
 
+// matchName returns true if the name of the output or the name followed by a
+// dash and the monitor ID matches the shell pattern.
+func (o Output) matchName(pattern string) (bool, error) {
+	// Check legacy name
+	m, err := path.Match(pattern, o.Name)
+	if err != nil || m {
+		return m, err
+	}
+
+	// Check extended name
+	return path.Match(pattern, o.Name+"-"+o.MonitorID)
+}
+
+// OnScreen returns the outputs which belong to the X screen.
+func (os Outputs) OnScreen(screen int) Outputs {
+	var list Outputs
+	for _, o := range os {
+		if o.Screen == screen {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
 // Present returns true iff the list of outputs contains the named output.
 func (os Outputs) Present(name string) bool {
 	for _, o := range os {
-		// Check legacy name
-		m, err := path.Match(name, o.Name)
+		m, err := o.matchName(name)
 		if err != nil {
 			return false
 		}
 		if m {
 			return true
 		}
+	}
+	return false
+}
+
+// Connected returns true iff the list of outputs contains the named output and
+// it is connected.
+func (os Outputs) Connected(name string) bool {
+	for _, o := range os {
+		if !o.Connected {
+			continue
+		}
 
-		// Check extended name
-		m, err = path.Match(name, o.Name+"-"+o.MonitorID)
+		m, err := o.matchName(name)
 		if err != nil {
 			return false
 		}
@@ -109,29 +290,54 @@ func (os Outputs) Present(name string) bool {
 	return false
 }
 
-// Connected returns true iff the list of outputs contains the named output and
-// it is connected.
-func (os Outputs) Connected(name string) bool {
+// HaveProperties returns true iff the list of outputs contains the named
+// output and its properties match want, see Properties.Match.
+func (os Outputs) HaveProperties(name string, want map[string]string) bool {
 	for _, o := range os {
-		if !o.Connected {
+		m, err := o.matchName(name)
+		if err != nil {
+			return false
+		}
+		if m && o.Properties.Match(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// HaveVendor returns true iff the list of outputs contains the named output
+// and the PNP ID or the name of its vendor matches the shell pattern.
+func (os Outputs) HaveVendor(name, pattern string) bool {
+	for _, o := range os {
+		m, err := o.matchName(name)
+		if err != nil {
+			return false
+		}
+		if !m {
 			continue
 		}
 
-		// Check legacy name
-		m, err := path.Match(name, o.Name)
+		m, err = o.matchVendor(pattern)
 		if err != nil {
 			return false
 		}
 		if m {
 			return true
 		}
+	}
+	return false
+}
 
-		// Check extended name
-		m, err = path.Match(name, o.Name+"-"+o.MonitorID)
+// HaveAudio returns true iff the list of outputs contains the named output
+// and its monitor can play audio.
+func (os Outputs) HaveAudio(name string) bool {
+	for _, o := range os {
+		m, err := o.matchName(name)
 		if err != nil {
 			return false
 		}
-		if m {
+
+		if m && o.EDID != nil && o.EDID.Audio() {
 			return true
 		}
 	}
@@ -161,6 +367,83 @@ type Mode struct {
 	Name    string
 	Default bool
 	Active  bool
+
+	// Rates lists the refresh rates available for the mode. ActiveRate and
+	// DefaultRate are the active and the preferred one, they are zero if the
+	// mode is not active or not preferred.
+	Rates       []float64
+	ActiveRate  float64
+	DefaultRate float64
+
+	// Timings holds the timing of each mode sharing the name, it is only
+	// set by a detailed query (`xrandr --verbose`).
+	Timings []ModeTiming
+}
+
+// addRate records a refresh rate of the mode and whether it is active or
+// preferred.
+func (m *Mode) addRate(rate float64, active, preferred bool) {
+	found := false
+	for _, r := range m.Rates {
+		if r == rate {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		m.Rates = append(m.Rates, rate)
+	}
+
+	if active {
+		m.Active = true
+		m.ActiveRate = rate
+	}
+
+	if preferred {
+		m.Default = true
+		m.DefaultRate = rate
+	}
+}
+
+// ClosestRate returns the available refresh rate closest to rate. If the
+// rates of the mode are not known, rate is returned.
+func (m Mode) ClosestRate(rate float64) float64 {
+	if len(m.Rates) == 0 {
+		return rate
+	}
+
+	best := m.Rates[0]
+	for _, r := range m.Rates[1:] {
+		if math.Abs(r-rate) < math.Abs(best-rate) {
+			best = r
+		}
+	}
+
+	return best
+}
+
+// Equals checks whether the two modes are equal.
+func (m Mode) Equals(other Mode) bool {
+	if m.Name != other.Name || m.Default != other.Default || m.Active != other.Active {
+		return false
+	}
+
+	if m.ActiveRate != other.ActiveRate || m.DefaultRate != other.DefaultRate {
+		return false
+	}
+
+	if len(m.Rates) != len(other.Rates) {
+		return false
+	}
+
+	for i := range m.Rates {
+		if m.Rates[i] != other.Rates[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (m Mode) String() string {
@@ -190,149 +473,245 @@ func (m Modes) String() string {
 
 // GenerateMonitorID derives the monitor id from the edid
 func GenerateMonitorID(s string) (string, error) {
-	var errEdidCorrupted = errors.New("corrupt EDID: " + s)
-	if len(s) < 32 || s[:16] != "00ffffffffffff00" {
-		return "", errEdidCorrupted
-	}
-
-	edid, err := hex.DecodeString(s)
+	e, err := ParseEDIDHex(s)
 	if err != nil {
 		return "", err
 	}
 
-	// we only parse EDID 1.3 and 1.4
-	if edid[18] != 1 || (edid[19] < 3 || edid[19] > 4) {
-		return "", fmt.Errorf("unknown EDID version %d.%d", edid[18], edid[19])
-	}
+	return e.MonitorID(), nil
+}
 
-	manuf := binary.BigEndian.Uint16(edid[8:10])
+// errNotModeLine is returned by parseModeLine when the line doesn't match
+// the format for a mode line.
+var errNotModeLine = errors.New("not a mode line")
 
-	// The first bit is resevered and needs to be zero
-	if manuf&0x8000 != 0x0000 {
-		return "", errEdidCorrupted
-	}
+// rotations are the names xrandr uses for the rotation of an output.
+var rotations = map[string]struct{}{
+	"normal":   {},
+	"left":     {},
+	"inverted": {},
+	"right":    {},
+}
 
-	// Decode the manufacturer 'A' = 0b00001, 'B' = 0b00010, ..., 'Z' = 0b11010
-	var manufacturer string
-	mask := uint16(0x7C00) // 0b0111110000000000
-	for i := uint(0); i <= 10; i += 5 {
-		number := ((manuf & (mask >> i)) >> (10 - i))
-		manufacturer += string(byte(number + 'A' - 1))
+// parseGeometry parses a geometry like "1920x1080+0+0".
+func parseGeometry(s string) (Geometry, bool) {
+	var g Geometry
+	n, err := fmt.Sscanf(s, "%dx%d+%d+%d", &g.Width, &g.Height, &g.X, &g.Y)
+	if err != nil || n != 4 {
+		return Geometry{}, false
 	}
 
-	// Decode the product and serial number
-	product := binary.LittleEndian.Uint16(edid[10:12])
-	serial := binary.LittleEndian.Uint32(edid[12:16])
-
-	// Decode four descriptor blocks
-	var displayName, displaySerialNumber string
-	for i := 0; i < 4; i++ {
-		d := edid[54+i*18 : 54+18+i*18]
+	return g, true
+}
 
-		// interesting descriptors start with three zeroes
-		if d[0] != 0 || d[1] != 0 || d[2] != 0 {
-			continue
-		}
+// parsePhysicalSize parses the words "NNNmm", "x", "NNNmm".
+func parsePhysicalSize(words []string) (width, height int, ok bool) {
+	if len(words) < 3 || words[1] != "x" {
+		return 0, 0, false
+	}
 
-		switch d[3] {
-		case 0xff: // display serial number
-			displaySerialNumber = strings.TrimSpace(string(d[5:]))
-		case 0xfc: // display name
-			displayName = strings.TrimSpace(string(d[5:]))
-		}
+	n, err := fmt.Sscanf(words[0]+" "+words[2], "%dmm %dmm", &width, &height)
+	if err != nil || n != 2 {
+		return 0, 0, false
 	}
 
-	str := fmt.Sprintf("%s-%d-%d-%v-%v", manufacturer, product, serial, displayName, displaySerialNumber)
-	return str, nil
+	return width, height, true
 }
 
-// errNotModeLine is returned by parseModeLine when the line doesn't match
-// the format for a mode line.
-var errNotModeLine = errors.New("not a mode line")
-
 // parseOutputLine returns the output parsed from the string.
 func parseOutputLine(line string) (Output, error) {
 	output := Output{}
 
-	ws := bufio.NewScanner(bytes.NewReader([]byte(line)))
-	ws.Split(bufio.ScanWords)
-
-	if !ws.Scan() {
+	words := strings.Fields(line)
+	if len(words) < 1 {
 		return Output{}, fmt.Errorf("line too short, name not found: %s", line)
 	}
-	output.Name = ws.Text()
+	output.Name = words[0]
 
-	if !ws.Scan() {
-		return Output{}, fmt.Errorf("line too short, state not found: %s", line)
+	if len(words) < 2 {
+		output.Unknown = true
+		return output, fmt.Errorf("line too short, state not found: %s", line)
 	}
 
-	switch ws.Text() {
+	// an invalid state is reported after parsing the rest of the line, so
+	// that lenient parsing can use the output
+	var stateErr error
+
+	switch words[1] {
 	case "connected":
 		output.Connected = true
 	case "disconnected":
 		output.Connected = false
+	case "unknown":
+		// the driver cannot detect whether a monitor is connected
+		output.Unknown = true
+		if len(words) > 2 && words[2] == "connection" {
+			words = words[1:]
+		}
 	default:
-		return Output{}, fmt.Errorf("unknown state %q", ws.Text())
+		output.Unknown = true
+		stateErr = fmt.Errorf("unknown state %q", words[1])
 	}
 
-	if !ws.Scan() {
-		return output, nil
-	}
+	words = words[2:]
 
-	if ws.Text() == "primary" {
+	if len(words) > 0 && words[0] == "primary" {
 		output.Primary = true
-		ws.Scan()
+		words = words[1:]
+	}
+
+	if len(words) > 0 {
+		if g, ok := parseGeometry(words[0]); ok {
+			output.Geometry = g
+			output.Rotation = "normal"
+			words = words[1:]
+
+			// handle special case when output is disconnected but still active
+			if !output.Connected {
+				mode := fmt.Sprintf("%dx%d", g.Width, g.Height)
+				output.Modes = append(output.Modes, Mode{Name: mode, Active: true})
+			}
+		}
 	}
 
-	if output.Connected {
-		return output, nil
+	// `xrandr --verbose` prints the ID of the active mode
+	if len(words) > 0 {
+		if id, ok := parseModeID(words[0]); ok {
+			output.details().ModeID = id
+			words = words[1:]
+		}
 	}
 
-	// handle special case when output is disconnected but still active
-	arg := strings.Split(ws.Text(), "+")
-	if len(arg) != 3 {
-		return output, nil
+	// the rotation is only printed if it is not "normal" (or with --verbose)
+	if len(words) > 0 {
+		if _, ok := rotations[words[0]]; ok {
+			output.Rotation = words[0]
+			words = words[1:]
+		}
 	}
 
-	mode := arg[0]
-	output.Modes = append(output.Modes, Mode{Name: mode, Active: true})
+	// reflection is printed as "X axis", "Y axis" or "X and Y axis"
+	switch {
+	case len(words) >= 4 && words[0] == "X" && words[1] == "and" && words[2] == "Y" && words[3] == "axis":
+		output.Reflection = "X and Y axis"
+		words = words[4:]
+	case len(words) >= 2 && (words[0] == "X" || words[0] == "Y") && words[1] == "axis":
+		output.Reflection = words[0] + " axis"
+		words = words[2:]
+	}
 
-	return output, nil
+	// skip the list of supported rotations and reflections
+	if len(words) > 0 && strings.HasPrefix(words[0], "(") {
+		for len(words) > 0 {
+			word := words[0]
+			words = words[1:]
+			if strings.HasSuffix(word, ")") {
+				break
+			}
+		}
+	}
+
+	if width, height, ok := parsePhysicalSize(words); ok {
+		output.WidthMM = width
+		output.HeightMM = height
+	}
+
+	return output, stateErr
 }
 
-// parseModeLine returns the mode parsed from the string.
+// parseRate parses a refresh rate or clock. strconv.ParseFloat also accepts
+// "NaN" and "Inf", which are rejected like negative values. Virtual outputs
+// may report a rate of zero.
+func parseRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate < 0 {
+		return 0, fmt.Errorf("invalid value %v", rate)
+	}
+
+	return rate, nil
+}
+
+// parseModeLine returns the mode parsed from the string. Each refresh rate
+// may be followed by "*" if it is active and "+" if it is preferred.
 func parseModeLine(line string) (mode Mode, err error) {
 	if !strings.HasPrefix(line, "  ") {
 		return Mode{}, errNotModeLine
 	}
 
-	ws := bufio.NewScanner(bytes.NewReader([]byte(line)))
-	ws.Split(bufio.ScanWords)
-
-	if !ws.Scan() {
+	words := strings.Fields(line)
+	if len(words) < 1 {
 		return Mode{}, fmt.Errorf("line too short, mode name not found: %s", line)
 	}
-	mode.Name = ws.Text()
+	mode.Name = words[0]
 
-	if !ws.Scan() {
+	if len(words) < 2 {
 		return Mode{}, fmt.Errorf("line too short, no refresh rate found: %s", line)
 	}
-	rate := ws.Text()
 
-	if rate[len(rate)-1] == '+' {
-		mode.Default = true
+	for i := 1; i < len(words); i++ {
+		word := strings.TrimRight(words[i], "*+")
+		flags := words[i][len(word):]
+
+		// handle single-word "+", which happens when a rate is preferred
+		// but not active
+		if i+1 < len(words) && words[i+1] == "+" {
+			flags += "+"
+			i++
+		}
+
+		rate, err := parseRate(word)
+		if err != nil {
+			return Mode{}, fmt.Errorf("invalid refresh rate %q: %s", words[i], line)
+		}
+
+		mode.addRate(rate, strings.Contains(flags, "*"), strings.Contains(flags, "+"))
 	}
 
-	if rate[len(rate)-2] == '*' {
-		mode.Active = true
+	return mode, nil
+}
+
+// parsePropertyLine returns the name and value of the property on a line like
+// "	link-status: Good".
+func parsePropertyLine(line string) (name string, prop Property, err error) {
+	data := strings.SplitN(strings.TrimPrefix(line, "	"), ":", 2)
+	if len(data) != 2 || data[0] == "" {
+		return "", Property{}, fmt.Errorf("invalid property line: %s", line)
 	}
 
-	// handle single-word "+", which happens when a mode is default but not active
-	if ws.Scan() && ws.Text() == "+" {
-		mode.Default = true
+	return data[0], Property{Value: strings.TrimSpace(data[1])}, nil
+}
+
+// parsePropertyDetail returns prop with the information from the line
+// following the property added. This is either the list of supported values,
+// the range of valid values or the continuation of a long value.
+func parsePropertyDetail(prop Property, line string) (Property, error) {
+	line = strings.TrimSpace(line)
+
+	switch {
+	case strings.HasPrefix(line, "supported:"):
+		for _, value := range strings.Split(strings.TrimPrefix(line, "supported:"), ",") {
+			prop.Supported = append(prop.Supported, strings.TrimSpace(value))
+		}
+
+	case strings.HasPrefix(line, "range:"), strings.HasPrefix(line, "ranges:"):
+		// only the first range is used when several are listed
+		var r PropertyRange
+		rest := line[strings.Index(line, ":")+1:]
+		_, err := fmt.Sscanf(strings.TrimSpace(rest), "(%d, %d)", &r.Min, &r.Max)
+		if err != nil {
+			return Property{}, fmt.Errorf("invalid range %q: %w", rest, err)
+		}
+		prop.Range = &r
+
+	default:
+		prop.Value += line
 	}
 
-	return mode, nil
+	return prop, nil
 }
 
 var errNotEdidLine = errors.New("not an edid line")
@@ -358,8 +737,70 @@ func parseEdidLine(line string) (edid string, err error) {
 	return edid, nil
 }
 
-// RandrParse returns the list of outputs parsed from the reader.
-func RandrParse(rd io.Reader) (outputs Outputs, err error) {
+// parseScreenLine parses a line like
+// "Screen 0: minimum 320 x 200, current 3840 x 1080, maximum 16384 x 16384".
+func parseScreenLine(line string) (Screen, error) {
+	var s Screen
+	_, err := fmt.Sscanf(line, "Screen %d: minimum %d x %d, current %d x %d, maximum %d x %d",
+		&s.Index,
+		&s.Minimum.Width, &s.Minimum.Height,
+		&s.Current.Width, &s.Current.Height,
+		&s.Maximum.Width, &s.Maximum.Height)
+	if err != nil || s.Index < 0 {
+		return Screen{}, fmt.Errorf("invalid screen line: %s", line)
+	}
+
+	return s, nil
+}
+
+// RandrParse returns the list of outputs parsed from the reader, which may
+// hold the output of `xrandr` for several screens.
+func RandrParse(rd io.Reader) (Outputs, error) {
+	_, outputs, err := RandrParseScreens(rd)
+	return outputs, err
+}
+
+// RandrParseScreens returns the list of screens and outputs parsed from the
+// reader.
+func RandrParseScreens(rd io.Reader) (Screens, Outputs, error) {
+	screens, outputs, _, err := randrParse(rd, false)
+	return screens, outputs, err
+}
+
+// ParseWarning describes a line which could not be parsed in lenient mode.
+type ParseWarning struct {
+	// Line is the line number, starting at 1. It is zero for warnings from
+	// a native RANDR query.
+	Line int
+
+	// Output is the name of the output the line belongs to, it is empty for
+	// lines before the first output.
+	Output string
+
+	Err error
+}
+
+func (w ParseWarning) String() string {
+	if w.Line == 0 {
+		return fmt.Sprintf("output %v: %v", w.Output, w.Err)
+	}
+	if w.Output == "" {
+		return fmt.Sprintf("line %d: %v", w.Line, w.Err)
+	}
+	return fmt.Sprintf("line %d, output %v: %v", w.Line, w.Output, w.Err)
+}
+
+// RandrParseLenient works like RandrParseScreens, but lines which cannot be
+// parsed are skipped and returned as warnings. Outputs with an unknown
+// connection state are returned with Unknown set. An error is only returned
+// if reading fails.
+func RandrParseLenient(rd io.Reader) (Screens, Outputs, []ParseWarning, error) {
+	return randrParse(rd, true)
+}
+
+// randrParse parses the output of `xrandr`. In lenient mode, errors are
+// recorded as warnings and the offending line is skipped.
+func randrParse(rd io.Reader, lenient bool) (screens Screens, outputs Outputs, warnings []ParseWarning, err error) {
 	ls := bufio.NewScanner(rd)
 
 	const (
@@ -374,26 +815,82 @@ func RandrParse(rd io.Reader) (outputs Outputs, err error) {
 		state       = StateStart
 		output      Output
 		currentEdid string
+		property    string
+		lineNo      int
+
+		// transformRow is the next row of the transformation matrix
+		// printed by `xrandr --verbose`
+		transformRow int
+
+		// modeIndex is the mode whose timing follows, current and
+		// preferred are its flags (`xrandr --verbose` only)
+		modeIndex          = -1
+		current, preferred bool
 	)
 
+	// warn records err as a warning for line
+	warn := func(line int, err error) {
+		w := ParseWarning{Line: line, Output: output.Name, Err: err}
+		V("xrandr output %v\n", w)
+		warnings = append(warnings, w)
+		if output.Name != "" {
+			output.Warnings = append(output.Warnings, w)
+		}
+	}
+
+	// fail returns err, or records it as a warning and returns nil in
+	// lenient mode
+	fail := func(err error) error {
+		if !lenient {
+			return err
+		}
+
+		warn(lineNo, err)
+		return nil
+	}
+
 nextLine:
 	for ls.Scan() {
 		line := ls.Text()
+		lineNo++
 
 		for {
 			switch state {
 			case StateStart:
 				if strings.HasPrefix(line, "Screen ") {
 					state = StateOutput
-					continue nextLine
+					continue
+				}
+				err := fail(fmt.Errorf(`first line should start with "Screen", found: %v`, line))
+				if err != nil {
+					return nil, nil, nil, err
 				}
-				return nil, fmt.Errorf(`first line should start with "Screen", found: %v`, line)
+				continue nextLine
 
 			case StateOutput:
+				if strings.HasPrefix(line, "Screen ") {
+					screen, err := parseScreenLine(line)
+					if err != nil {
+						if err := fail(err); err != nil {
+							return nil, nil, nil, err
+						}
+						// the outputs following are assigned to the next screen
+						screen = Screen{Index: len(screens)}
+					}
+					screens = append(screens, screen)
+					continue nextLine
+				}
+
 				output, err = parseOutputLine(line)
 				if err != nil {
-					return nil, err
+					if err := fail(err); err != nil {
+						return nil, nil, nil, err
+					}
 				}
+				if len(screens) > 0 {
+					output.Screen = screens[len(screens)-1].Index
+				}
+				property = ""
 				state = StateAdditionalProperties
 				continue nextLine
 
@@ -401,42 +898,150 @@ nextLine:
 				if strings.HasPrefix(line, "	EDID:") {
 					state = StateEdid
 					currentEdid = ""
+					property = ""
 					continue nextLine
 				}
-				if !strings.HasPrefix(line, "	") {
-					state = StateMode
-					continue
+				if strings.HasPrefix(line, "	 ") {
+					err := parseTransformContinuation(output.details(), transformRow, line)
+					if err != nil {
+						if err := fail(err); err != nil {
+							return nil, nil, nil, fmt.Errorf("output %v: %w", output.Name, err)
+						}
+					}
+					transformRow++
+					continue nextLine
 				}
-				continue nextLine
+				if key, value, ok := splitDetailLine(line); ok {
+					err := parseDetail(output.details(), key, value)
+					if err != nil {
+						if err := fail(err); err != nil {
+							return nil, nil, nil, fmt.Errorf("output %v: %w", output.Name, err)
+						}
+					}
+					transformRow = 1
+					property = ""
+					continue nextLine
+				}
+				if strings.HasPrefix(line, "		") {
+					if property == "" {
+						continue nextLine
+					}
+
+					prop, err := parsePropertyDetail(output.Properties[property], line)
+					if err != nil {
+						if err := fail(fmt.Errorf("property %v: %w", property, err)); err != nil {
+							return nil, nil, nil, fmt.Errorf("output %v, %w", output.Name, err)
+						}
+						continue nextLine
+					}
+					output.Properties[property] = prop
+					continue nextLine
+				}
+				if strings.HasPrefix(line, "	") {
+					name, prop, err := parsePropertyLine(line)
+					if err != nil {
+						if err := fail(err); err != nil {
+							return nil, nil, nil, fmt.Errorf("output %v: %w", output.Name, err)
+						}
+						property = ""
+						continue nextLine
+					}
+
+					if output.Properties == nil {
+						output.Properties = make(Properties)
+					}
+					output.Properties[name] = prop
+					property = name
+					continue nextLine
+				}
+				state = StateMode
+				continue
 
 			case StateEdid:
 				edidPart, err := parseEdidLine(line)
 				if err == errNotEdidLine {
-					monitorID, err := GenerateMonitorID(currentEdid)
+					// a monitor which cannot be identified must not
+					// break the other outputs
+					e, err := ParseEDIDHex(currentEdid)
 					if err != nil {
-						return nil, err
+						warn(lineNo, err)
+					} else {
+						if e.ChecksumMismatch {
+							warn(lineNo, errEDIDChecksum)
+						}
+						output.EDID = e
+						output.MonitorID = e.MonitorID()
+						output.Vendor = e.Vendor
 					}
-					output.MonitorID = monitorID
 					state = StateAdditionalProperties
 					continue
 				}
 				if err != nil {
-					return nil, err
+					if err := fail(err); err != nil {
+						return nil, nil, nil, err
+					}
+					continue nextLine
 				}
 				currentEdid += edidPart
 				continue nextLine
 
 			case StateMode:
+				if isTimingLine(line) {
+					if modeIndex < 0 {
+						err := fail(fmt.Errorf("timing without mode: %s", line))
+						if err != nil {
+							return nil, nil, nil, fmt.Errorf("output %v: %w", output.Name, err)
+						}
+						continue nextLine
+					}
+
+					mode := &output.Modes[modeIndex]
+					vertical, err := parseTimingLine(&mode.Timings[len(mode.Timings)-1], line)
+					if err != nil {
+						if err := fail(err); err != nil {
+							return nil, nil, nil, err
+						}
+						continue nextLine
+					}
+
+					// the refresh rate is printed with the vertical timing
+					if vertical {
+						mode.addRate(mode.Timings[len(mode.Timings)-1].Rate, current, preferred)
+					}
+					continue nextLine
+				}
+
+				name, timing, cur, pref, err := parseVerboseModeLine(line)
+				if err == nil {
+					modeIndex = output.addVerboseMode(name, timing)
+					current, preferred = cur, pref
+					continue nextLine
+				}
+				if err != errNotModeLine {
+					if err := fail(err); err != nil {
+						return nil, nil, nil, err
+					}
+					modeIndex = -1
+					continue nextLine
+				}
+
 				mode, err := parseModeLine(line)
 				if err == errNotModeLine {
-					outputs = append(outputs, output)
+					// lines of an output which could not be parsed are dropped
+					if output.Name != "" {
+						outputs = append(outputs, output)
+					}
 					output = Output{}
+					modeIndex = -1
 					state = StateOutput
 					continue
 				}
 
 				if err != nil {
-					return nil, err
+					if err := fail(err); err != nil {
+						return nil, nil, nil, err
+					}
+					continue nextLine
 				}
 
 				output.Modes = append(output.Modes, mode)
@@ -445,11 +1050,15 @@ nextLine:
 		}
 	}
 
+	if err := ls.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
 	if output.Name != "" {
 		outputs = append(outputs, output)
 	}
 
-	return outputs, nil
+	return screens, outputs, warnings, nil
 }
 
 func runXrandr(extraArgs ...string) *exec.Cmd {
@@ -460,62 +1069,104 @@ func runXrandr(extraArgs ...string) *exec.Cmd {
 	return cmd
 }
 
-// GetOutputs runs `xrandr` and returns the parsed output.
-func GetOutputs() (Outputs, error) {
-	cmd := runXrandr("--current")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// xrandrQuery runs `xrandr` and returns the parsed screens and outputs.
+// `xrandr` only lists one screen, so it is run for each screen if the X
+// server has more than one. Lines which cannot be parsed are skipped, see
+// RandrParseLenient.
+func xrandrQuery(extraArgs ...string) (Screens, Outputs, error) {
+	screens := xScreenCount()
+	if screens < 2 {
+		output, err := runXrandr(extraArgs...).Output()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return xrandrParse(output)
+	}
+
+	var buf []byte
+	for screen := 0; screen < screens; screen++ {
+		args := append(screenArgs(screen), extraArgs...)
+		output, err := runXrandr(args...).Output()
+		if err != nil {
+			return nil, nil, fmt.Errorf("screen %d: %w", screen, err)
+		}
+		buf = append(buf, output...)
 	}
 
-	return RandrParse(bytes.NewReader(output))
+	return xrandrParse(buf)
 }
 
-// DetectOutputs runs `xrandr`, rescans the outputs and returns the parsed outputs.
-func DetectOutputs() (Outputs, error) {
-	cmd := runXrandr()
-	output, err := cmd.Output()
+// xrandrParse parses the output of `xrandr` leniently, the warnings are
+// attached to the outputs.
+func xrandrParse(buf []byte) (Screens, Outputs, error) {
+	screens, outputs, warnings, err := RandrParseLenient(bytes.NewReader(buf))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if len(warnings) > 0 {
+		V("%d lines of the xrandr output could not be parsed\n", len(warnings))
 	}
 
-	return RandrParse(bytes.NewReader(output))
+	return screens, outputs.MergeTiles(), nil
+}
+
+// xrandrOutputs runs `xrandr` and returns the parsed outputs.
+func xrandrOutputs(extraArgs ...string) (Outputs, error) {
+	_, outputs, err := xrandrQuery(extraArgs...)
+	return outputs, err
+}
+
+// screenArgs returns the arguments which select the screen for `xrandr`.
+// They are only needed if the X server has more than one screen.
+func screenArgs(screen int) []string {
+	if xScreenCount() < 2 {
+		return nil
+	}
+	return []string{"--screen", strconv.Itoa(screen)}
 }
 
 // BuildCommandOutputRow return a sequence of calls to `xrandr` to configure
 // all named outputs in a row, left to right, given the currently active
 // Outputs and a list of output names, optionally followed by "@" and the
-// desired mode, e.g. LVDS1@1377x768.
+// desired mode, e.g. LVDS1@1377x768, and another "@" and the refresh rate,
+// e.g. HDMI1@2560x1440@144. The closest available rate is used. All outputs
+// in current must belong to the same screen, see Rule.ScreenOutputs.
 func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
-	var outputs []string
-	var row bool
-
-	switch {
-	case rule.ConfigureSingle != "":
-		outputs = []string{rule.ConfigureSingle}
-	case len(rule.ConfigureRow) > 0:
-		outputs = rule.ConfigureRow
-		row = true
-	case len(rule.ConfigureColumn) > 0:
-		outputs = rule.ConfigureColumn
-		row = false
-	default:
-		return nil, errors.New("empty monitor row configuration")
+	outputs, row, err := ruleOutputs(rule)
+	if err != nil {
+		return nil, err
 	}
 
 	V("enable outputs: %v\n", outputs)
 
-	command := "xrandr"
+	var screen []string
+	if len(current) > 0 {
+		screen = screenArgs(current[0].Screen)
+	}
 	enableOutputArgs := [][]string{}
 
+	// tiled monitors need each tile to be placed explicitly
+	if ruleHasTiles(outputs, current) {
+		layout, err := ComputeLayout(rule, current)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, output := range layout.Outputs {
+			enableOutputArgs = append(enableOutputArgs, layoutOutputArgs(output))
+		}
+
+		return buildCommands(rule, screen, enableOutputArgs, layout.Disable), nil
+	}
+
 	active := make(map[string]struct{})
 	var lastOutput = ""
 	for i, output := range outputs {
-		data := strings.SplitN(output, "@", 2)
-		name := data[0]
-		mode := ""
-		if len(data) > 1 {
-			mode = data[1]
+		name, mode, rate, err := splitOutputMode(output)
+		if err != nil {
+			return nil, err
 		}
 
 		active[name] = struct{}{}
@@ -523,11 +1174,21 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 		args := []string{}
 		args = append(args, "--output", name)
 		if mode == "" {
+			if rate != 0 {
+				return nil, fmt.Errorf("output %v: refresh rate requires a mode", name)
+			}
 			args = append(args, "--auto")
 		} else {
 			args = append(args, "--mode", mode)
 		}
 
+		if rate != 0 {
+			if out, ok := findOutput(current, name); ok {
+				rate = closestRate(out, mode, rate)
+			}
+			args = append(args, "--rate", strconv.FormatFloat(rate, 'f', 2, 64))
+		}
+
 		if i > 0 {
 			if row {
 				args = append(args, "--right-of", lastOutput)
@@ -544,32 +1205,46 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 		enableOutputArgs = append(enableOutputArgs, args)
 	}
 
-	disableOutputs := make(map[string]struct{})
-	for _, output := range current {
-		if !output.Connected && len(output.Modes) == 0 {
+	return buildCommands(rule, screen, enableOutputArgs, disableOutputs(current, active, rule.DisableOrder)), nil
+}
+
+// ruleHasTiles returns true if one of the outputs (optionally followed by a
+// mode) is a tiled monitor.
+func ruleHasTiles(outputs []string, current Outputs) bool {
+	for _, output := range outputs {
+		name, _, _, err := splitOutputMode(output)
+		if err != nil {
 			continue
 		}
 
-		// disable unneeded outputs that are still active
-		if _, ok := active[output.Name]; !ok {
-			disableOutputs[output.Name] = struct{}{}
+		if o, ok := findOutput(current, name); ok && len(o.Tiles) > 0 {
+			return true
 		}
 	}
+	return false
+}
 
-	disableOutputArgs := [][]string{}
-
-	// honour disable_order if present
-	for _, name := range rule.DisableOrder {
-		if _, ok := disableOutputs[name]; ok {
-			args := []string{"--output", name, "--off"}
-			disableOutputArgs = append(disableOutputArgs, args)
-
-			delete(disableOutputs, name)
-		}
+// layoutOutputArgs returns the arguments for `xrandr` which enable output at
+// its absolute position.
+func layoutOutputArgs(output LayoutOutput) []string {
+	args := []string{"--output", output.Name, "--mode", output.Mode,
+		"--pos", fmt.Sprintf("%dx%d", output.X, output.Y)}
+	if output.Rate != 0 {
+		args = append(args, "--rate", strconv.FormatFloat(output.Rate, 'f', 2, 64))
 	}
+	if output.Primary {
+		args = append(args, "--primary")
+	}
+	return args
+}
 
-	// collect remaining outputs to be disabled
-	for name := range disableOutputs {
+// buildCommands returns the calls to `xrandr` which enable and disable the
+// outputs, either in one call for atomic rules or interleaved in several.
+func buildCommands(rule Rule, screen []string, enableOutputArgs [][]string, disable []string) []*exec.Cmd {
+	command := "xrandr"
+
+	disableOutputArgs := [][]string{}
+	for _, name := range disable {
 		args := []string{"--output", name, "--off"}
 		disableOutputArgs = append(disableOutputArgs, args)
 	}
@@ -577,7 +1252,7 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 	// enable/disable all monitors in one call to xrandr
 	if rule.Atomic {
 		V("using one atomic call to xrandr\n")
-		args := []string{}
+		args := append([]string{}, screen...)
 		for _, disableArgs := range disableOutputArgs {
 			args = append(args, disableArgs...)
 		}
@@ -585,7 +1260,7 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 			args = append(args, enableArgs...)
 		}
 		cmd := exec.Command(command, args...)
-		return []*exec.Cmd{cmd}, nil
+		return []*exec.Cmd{cmd}
 	}
 
 	V("splitting the configuration into several calls to xrandr\n")
@@ -595,13 +1270,14 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 
 	// disable an output
 	if len(disableOutputArgs) > 0 {
-		cmds = append(cmds, exec.Command(command, disableOutputArgs[0]...))
+		args := append(append([]string{}, screen...), disableOutputArgs[0]...)
+		cmds = append(cmds, exec.Command(command, args...))
 		disableOutputArgs = disableOutputArgs[1:]
 	}
 
 	// now for each newly enabled output, also disable another output
 	for len(disableOutputArgs) > 0 || len(enableOutputArgs) > 0 {
-		args := []string{}
+		args := append([]string{}, screen...)
 		if len(disableOutputArgs) > 0 {
 			args = append(args, disableOutputArgs[0]...)
 			disableOutputArgs = disableOutputArgs[1:]
@@ -614,25 +1290,40 @@ func BuildCommandOutputRow(rule Rule, current Outputs) ([]*exec.Cmd, error) {
 		cmds = append(cmds, exec.Command(command, args...))
 	}
 
-	return cmds, nil
+	return cmds
 }
 
-// DisableOutputs returns a call to `xrandr` to switch off the specified outputs.
-func DisableOutputs(off Outputs) (*exec.Cmd, error) {
+// DisableOutputs returns the calls to `xrandr` to switch off the specified
+// outputs, one for each screen.
+func DisableOutputs(off Outputs) ([]*exec.Cmd, error) {
 	if len(off) == 0 {
 		return nil, nil
 	}
 
 	command := "xrandr"
-	args := []string{}
+
+	var screens []int
+	args := make(map[int][]string)
 
 	var outputs []string
 	for _, output := range off {
-		outputs = append(outputs, output.Name)
-		args = append(args, "--output", output.Name, "--off")
+		if _, ok := args[output.Screen]; !ok {
+			screens = append(screens, output.Screen)
+			args[output.Screen] = screenArgs(output.Screen)
+		}
+
+		for _, name := range tileNames(output) {
+			outputs = append(outputs, name)
+			args[output.Screen] = append(args[output.Screen], "--output", name, "--off")
+		}
 	}
 
 	V("disable outputs: %v\n", outputs)
 
-	return exec.Command(command, args...), nil
+	var cmds []*exec.Cmd
+	for _, screen := range screens {
+		cmds = append(cmds, exec.Command(command, args[screen]...))
+	}
+
+	return cmds, nil
 }
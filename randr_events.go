@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// RandrChangeKind is the type of a RANDR change event.
+type RandrChangeKind int
+
+// These are the RANDR change events grobi subscribes to.
+const (
+	RandrScreenChange RandrChangeKind = iota
+	RandrCrtcChange
+	RandrOutputChange
+	RandrOutputProperty
+	RandrProviderChange
+	RandrResourceChange
+)
+
+func (k RandrChangeKind) String() string {
+	switch k {
+	case RandrScreenChange:
+		return "ScreenChangeNotify"
+	case RandrCrtcChange:
+		return "CrtcChangeNotify"
+	case RandrOutputChange:
+		return "OutputChangeNotify"
+	case RandrOutputProperty:
+		return "OutputPropertyNotify"
+	case RandrProviderChange:
+		return "ProviderChangeNotify"
+	case RandrResourceChange:
+		return "ResourceChangeNotify"
+	}
+	return fmt.Sprintf("RandrChangeKind(%d)", int(k))
+}
+
+// RandrChange is a decoded RANDR change event. Only the fields relevant for
+// the kind of event are set.
+type RandrChange struct {
+	Kind RandrChangeKind
+
+	// Output is set for OutputChangeNotify and OutputPropertyNotify.
+	Output randr.Output
+
+	// Crtc and Mode are set for CrtcChangeNotify and OutputChangeNotify,
+	// they are zero if the output is (or the CRTC was) switched off.
+	Crtc randr.Crtc
+	Mode randr.Mode
+
+	// Connection is set for OutputChangeNotify.
+	Connection byte
+
+	// Atom and Deleted are set for OutputPropertyNotify.
+	Atom    xproto.Atom
+	Deleted bool
+
+	// Provider is set for ProviderChangeNotify.
+	Provider randr.Provider
+
+	// Width and Height are set for ScreenChangeNotify and CrtcChangeNotify,
+	// X, Y and Rotation for CrtcChangeNotify.
+	X, Y          int
+	Width, Height int
+	Rotation      uint16
+}
+
+func (c RandrChange) String() string {
+	switch c.Kind {
+	case RandrScreenChange:
+		return fmt.Sprintf("%v %dx%d", c.Kind, c.Width, c.Height)
+	case RandrCrtcChange:
+		return fmt.Sprintf("%v crtc %d mode %d %dx%d+%d+%d",
+			c.Kind, c.Crtc, c.Mode, c.Width, c.Height, c.X, c.Y)
+	case RandrOutputChange:
+		return fmt.Sprintf("%v output %d crtc %d mode %d connection %d",
+			c.Kind, c.Output, c.Crtc, c.Mode, c.Connection)
+	case RandrOutputProperty:
+		return fmt.Sprintf("%v output %d atom %d deleted %v",
+			c.Kind, c.Output, c.Atom, c.Deleted)
+	case RandrProviderChange:
+		return fmt.Sprintf("%v provider %d", c.Kind, c.Provider)
+	}
+	return c.Kind.String()
+}
+
+// decodeRandrEvent returns the change described by ev. It returns false for
+// events which are not RANDR change events.
+func decodeRandrEvent(ev xgb.Event) (RandrChange, bool) {
+	switch ev := ev.(type) {
+	case randr.ScreenChangeNotifyEvent:
+		return RandrChange{
+			Kind:   RandrScreenChange,
+			Width:  int(ev.Width),
+			Height: int(ev.Height),
+		}, true
+
+	case randr.NotifyEvent:
+		switch ev.SubCode {
+		case randr.NotifyCrtcChange:
+			return RandrChange{
+				Kind:     RandrCrtcChange,
+				Crtc:     ev.U.Cc.Crtc,
+				Mode:     ev.U.Cc.Mode,
+				X:        int(ev.U.Cc.X),
+				Y:        int(ev.U.Cc.Y),
+				Width:    int(ev.U.Cc.Width),
+				Height:   int(ev.U.Cc.Height),
+				Rotation: ev.U.Cc.Rotation,
+			}, true
+
+		case randr.NotifyOutputChange:
+			return RandrChange{
+				Kind:       RandrOutputChange,
+				Output:     ev.U.Oc.Output,
+				Crtc:       ev.U.Oc.Crtc,
+				Mode:       ev.U.Oc.Mode,
+				Connection: ev.U.Oc.Connection,
+			}, true
+
+		case randr.NotifyOutputProperty:
+			return RandrChange{
+				Kind:    RandrOutputProperty,
+				Output:  ev.U.Op.Output,
+				Atom:    ev.U.Op.Atom,
+				Deleted: ev.U.Op.Status == xproto.PropertyDelete,
+			}, true
+
+		case randr.NotifyProviderChange:
+			return RandrChange{
+				Kind:     RandrProviderChange,
+				Provider: ev.U.Pc.Provider,
+			}, true
+
+		case randr.NotifyResourceChange:
+			return RandrChange{Kind: RandrResourceChange}, true
+		}
+	}
+
+	return RandrChange{}, false
+}
+
+// randrCrtcState is the configuration of a CRTC.
+type randrCrtcState struct {
+	Mode     randr.Mode
+	Geometry Geometry
+	Rotation uint16
+}
+
+// randrOutputState holds the RANDR state of an output which is not part of
+// Output, but needed to apply change events.
+type randrOutputState struct {
+	Crtc              randr.Crtc
+	Connection        byte
+	WidthMM, HeightMM int
+}
+
+// randrCache holds the outputs from the last query together with the RANDR
+// IDs needed to apply change events to them.
+type randrCache struct {
+	outputs Outputs
+
+	index map[randr.Output]int
+	state map[randr.Output]randrOutputState
+	crtcs map[randr.Crtc]randrCrtcState
+	modes map[randr.Mode]string
+	rates map[randr.Mode]float64
+
+	// watched holds the atoms of the properties whose changes require a
+	// new query, see watchedProperties.
+	watched map[xproto.Atom]struct{}
+}
+
+// watchedProperties returns the names of the output properties whose changes
+// require a new query: a new EDID means a different monitor, a changed
+// link-status requires the output to be reconfigured, and rules in cfg may
+// match other properties.
+func watchedProperties(cfg *Config) []string {
+	names := []string{"EDID", "link-status"}
+	if cfg == nil {
+		return names
+	}
+
+	seen := map[string]struct{}{"EDID": {}, "link-status": {}}
+	for _, rule := range cfg.Rules {
+		for _, props := range rule.OutputsProperties {
+			for name := range props {
+				if _, ok := seen[name]; !ok {
+					seen[name] = struct{}{}
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// newRandrCache returns an empty cache.
+func newRandrCache(modes map[randr.Mode]string, rates map[randr.Mode]float64) *randrCache {
+	return &randrCache{
+		watched: make(map[xproto.Atom]struct{}),
+		index:   make(map[randr.Output]int),
+		state:   make(map[randr.Output]randrOutputState),
+		crtcs:   make(map[randr.Crtc]randrCrtcState),
+		modes:   modes,
+		rates:   rates,
+	}
+}
+
+// add appends the output to the cache.
+func (c *randrCache) add(id randr.Output, state randrOutputState, output Output) {
+	c.index[id] = len(c.outputs)
+	c.state[id] = state
+	c.outputs = append(c.outputs, output)
+}
+
+// setCrtc records the configuration of a CRTC.
+func (c *randrCache) setCrtc(crtc randr.Crtc, state randrCrtcState) {
+	c.crtcs[crtc] = state
+}
+
+// Outputs returns a copy of the cached outputs.
+func (c *randrCache) Outputs() Outputs {
+	outputs := make(Outputs, len(c.outputs))
+	copy(outputs, c.outputs)
+	return outputs
+}
+
+// refresh updates the active mode, its refresh rate and the placement of the
+// output from the configuration of its CRTC. It returns false if the output,
+// the CRTC or the mode is not known. The list of modes is copied before it is
+// modified, earlier results of Outputs are not changed.
+func (c *randrCache) refresh(id randr.Output) (changed, ok bool) {
+	pos, ok := c.index[id]
+	if !ok {
+		return false, false
+	}
+
+	// xrandr lists only the active mode for disconnected outputs
+	output := c.outputs[pos]
+	if !output.Connected {
+		return false, false
+	}
+
+	state := c.state[id]
+
+	var crtc randrCrtcState
+	if state.Crtc != 0 {
+		crtc, ok = c.crtcs[state.Crtc]
+		if !ok {
+			return false, false
+		}
+	}
+
+	var name string
+	if crtc.Mode != 0 {
+		name, ok = c.modes[crtc.Mode]
+		if !ok {
+			return false, false
+		}
+	}
+
+	updated := output
+	updated.Modes = make(Modes, len(output.Modes))
+	copy(updated.Modes, output.Modes)
+
+	found := name == ""
+	for i := range updated.Modes {
+		active := name != "" && updated.Modes[i].Name == name
+		if active {
+			found = true
+		}
+		updated.Modes[i].Active = active
+		updated.Modes[i].ActiveRate = 0
+		if active {
+			updated.Modes[i].ActiveRate = c.rates[crtc.Mode]
+		}
+	}
+
+	// the mode is not in the list for this output
+	if !found {
+		return false, false
+	}
+
+	if name == "" {
+		updated.Geometry = Geometry{}
+		updated.Rotation, updated.Reflection = "", ""
+		updated.WidthMM, updated.HeightMM = 0, 0
+	} else {
+		updated.Geometry = crtc.Geometry
+		updated.Rotation, updated.Reflection = rotationNames(crtc.Rotation)
+		updated.WidthMM, updated.HeightMM = state.WidthMM, state.HeightMM
+	}
+
+	if reflect.DeepEqual(updated, output) {
+		return false, true
+	}
+
+	c.outputs[pos] = updated
+	return true, true
+}
+
+// Apply updates the cached outputs with the change. It returns whether the
+// outputs have changed. If ok is false, the change cannot be applied to the
+// cache and the outputs need to be queried again.
+func (c *randrCache) Apply(change RandrChange) (changed, ok bool) {
+	switch change.Kind {
+	case RandrScreenChange:
+		// the screen size is not part of the outputs, changed CRTCs and
+		// outputs are reported in separate events
+		return false, true
+
+	case RandrCrtcChange:
+		c.setCrtc(change.Crtc, randrCrtcState{
+			Mode: change.Mode,
+			Geometry: Geometry{
+				X:      change.X,
+				Y:      change.Y,
+				Width:  change.Width,
+				Height: change.Height,
+			},
+			Rotation: change.Rotation,
+		})
+
+		for id, state := range c.state {
+			if state.Crtc != change.Crtc {
+				continue
+			}
+
+			ch, ok := c.refresh(id)
+			if !ok {
+				return false, false
+			}
+			changed = changed || ch
+		}
+		return changed, true
+
+	case RandrOutputChange:
+		// a new output or a newly connected one, the list of modes is unknown
+		state, ok := c.state[change.Output]
+		if !ok || state.Connection != change.Connection {
+			return false, false
+		}
+
+		// the CRTC is reported before the output, it must match
+		if change.Crtc != 0 {
+			crtc, ok := c.crtcs[change.Crtc]
+			if !ok || crtc.Mode != change.Mode {
+				return false, false
+			}
+		}
+
+		state.Crtc = change.Crtc
+		c.state[change.Output] = state
+		return c.refresh(change.Output)
+
+	case RandrOutputProperty:
+		if _, ok := c.index[change.Output]; !ok {
+			return false, false
+		}
+
+		// the event does not contain the new value, only changes of the
+		// properties which make a difference for the rules are queried
+		if _, ok := c.watched[change.Atom]; ok {
+			return false, false
+		}
+		return false, true
+
+	case RandrProviderChange, RandrResourceChange:
+		// providers, outputs or CRTCs were added or removed, new
+		// providers may need to be linked
+		return false, false
+	}
+
+	return false, false
+}
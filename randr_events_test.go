@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+func TestDecodeRandrEvent(t *testing.T) {
+	var tests = []struct {
+		ev     xgb.Event
+		change RandrChange
+		ok     bool
+	}{
+		{
+			randr.ScreenChangeNotifyEvent{Width: 3200, Height: 1080},
+			RandrChange{Kind: RandrScreenChange, Width: 3200, Height: 1080},
+			true,
+		},
+		{
+			randr.NotifyEvent{
+				SubCode: randr.NotifyCrtcChange,
+				U:       randr.NotifyDataUnionCcNew(randr.CrtcChange{Crtc: 0x3f, Mode: 0x4b}),
+			},
+			RandrChange{Kind: RandrCrtcChange, Crtc: 0x3f, Mode: 0x4b},
+			true,
+		},
+		{
+			randr.NotifyEvent{
+				SubCode: randr.NotifyOutputChange,
+				U: randr.NotifyDataUnionOcNew(randr.OutputChange{
+					Output:     0x42,
+					Crtc:       0x3f,
+					Mode:       0x4b,
+					Connection: randr.ConnectionConnected,
+				}),
+			},
+			RandrChange{Kind: RandrOutputChange, Output: 0x42, Crtc: 0x3f, Mode: 0x4b},
+			true,
+		},
+		{
+			randr.NotifyEvent{
+				SubCode: randr.NotifyOutputProperty,
+				U: randr.NotifyDataUnionOpNew(randr.OutputProperty{
+					Output: 0x42,
+					Atom:   0x123,
+					Status: xproto.PropertyDelete,
+				}),
+			},
+			RandrChange{Kind: RandrOutputProperty, Output: 0x42, Atom: 0x123, Deleted: true},
+			true,
+		},
+		{
+			randr.NotifyEvent{
+				SubCode: randr.NotifyProviderChange,
+				U:       randr.NotifyDataUnionPcNew(randr.ProviderChange{Provider: 0x116}),
+			},
+			RandrChange{Kind: RandrProviderChange, Provider: 0x116},
+			true,
+		},
+		{
+			randr.NotifyEvent{SubCode: randr.NotifyResourceChange},
+			RandrChange{Kind: RandrResourceChange},
+			true,
+		},
+		{
+			randr.NotifyEvent{SubCode: randr.NotifyProviderProperty},
+			RandrChange{},
+			false,
+		},
+		{
+			xproto.KeyPressEvent{},
+			RandrChange{},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			change, ok := decodeRandrEvent(test.ev)
+			if ok != test.ok {
+				t.Fatalf("wrong result, want %v, got %v", test.ok, ok)
+			}
+
+			if !reflect.DeepEqual(change, test.change) {
+				t.Fatalf("wrong change decoded:\n  want %v\n  got  %v", test.change, change)
+			}
+		})
+	}
+}
+
+// testRandrCache returns a cache with an active laptop panel and a connected
+// monitor which is switched off.
+func testRandrCache() *randrCache {
+	modes := map[randr.Mode]string{
+		0x40: "1920x1080",
+		0x41: "1920x1080",
+		0x42: "1280x720",
+		0x43: "1920x1080",
+		0x50: "2560x1440",
+	}
+
+	rates := map[randr.Mode]float64{
+		0x40: 60.00,
+		0x41: 60.00,
+		0x42: 60.00,
+		0x43: 48.00,
+		0x50: 59.95,
+	}
+
+	c := newRandrCache(modes, rates)
+	c.watched[0x200] = struct{}{}
+	c.setCrtc(0x30, randrCrtcState{
+		Mode:     0x40,
+		Geometry: Geometry{Width: 1920, Height: 1080},
+		Rotation: randr.RotationRotate0,
+	})
+	c.add(0x60, randrOutputState{Crtc: 0x30, Connection: randr.ConnectionConnected, WidthMM: 309, HeightMM: 174}, Output{
+		Name:      "eDP-1",
+		Connected: true,
+		Modes: Modes{
+			{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.00, 48.00}, ActiveRate: 60.00, DefaultRate: 60.00},
+			{Name: "1280x720", Rates: []float64{60.00}},
+		},
+		Geometry: Geometry{Width: 1920, Height: 1080},
+		Rotation: "normal",
+		WidthMM:  309,
+		HeightMM: 174,
+	})
+	c.add(0x61, randrOutputState{Connection: randr.ConnectionConnected, WidthMM: 597, HeightMM: 336}, Output{
+		Name:      "DP-1",
+		Connected: true,
+		Modes: Modes{
+			{Name: "2560x1440", Default: true, Rates: []float64{59.95}, DefaultRate: 59.95},
+			{Name: "1920x1080", Rates: []float64{60.00}},
+		},
+	})
+	c.add(0x62, randrOutputState{Connection: randr.ConnectionDisconnected}, Output{
+		Name: "HDMI-1",
+	})
+
+	return c
+}
+
+func TestRandrCacheApply(t *testing.T) {
+	var tests = []struct {
+		changes []RandrChange
+		changed bool
+		ok      bool
+		active  map[string]string
+	}{
+		{
+			// screen size changes alone are not relevant
+			[]RandrChange{{Kind: RandrScreenChange, Width: 1920, Height: 1080}},
+			false, true,
+			map[string]string{"eDP-1": "1920x1080@60.00 1920x1080+0+0 normal 309mm x 174mm"},
+		},
+		{
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x42, Width: 1280, Height: 720, Rotation: randr.RotationRotate0}},
+			true, true,
+			map[string]string{"eDP-1": "1280x720@60.00 1280x720+0+0 normal 309mm x 174mm"},
+		},
+		{
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x41, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0}},
+			false, true,
+			map[string]string{"eDP-1": "1920x1080@60.00 1920x1080+0+0 normal 309mm x 174mm"},
+		},
+		{
+			// same mode name, different refresh rate
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x43, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0}},
+			true, true,
+			map[string]string{"eDP-1": "1920x1080@48.00 1920x1080+0+0 normal 309mm x 174mm"},
+		},
+		{
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x40, Width: 1080, Height: 1920,
+				Rotation: randr.RotationRotate90 | randr.RotationReflectX}},
+			true, true,
+			map[string]string{"eDP-1": "1920x1080@60.00 1080x1920+0+0 left X axis 309mm x 174mm"},
+		},
+		{
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30}},
+			true, true,
+			map[string]string{},
+		},
+		{
+			[]RandrChange{
+				{Kind: RandrCrtcChange, Crtc: 0x31, Mode: 0x41, X: 1920, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0},
+				{Kind: RandrOutputChange, Output: 0x61, Crtc: 0x31, Mode: 0x41, Connection: randr.ConnectionConnected},
+			},
+			true, true,
+			map[string]string{
+				"eDP-1": "1920x1080@60.00 1920x1080+0+0 normal 309mm x 174mm",
+				"DP-1":  "1920x1080@60.00 1920x1080+1920+0 normal 597mm x 336mm",
+			},
+		},
+		{
+			// unknown mode
+			[]RandrChange{{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x99}},
+			false, false,
+			nil,
+		},
+		{
+			// mode not available for the output
+			[]RandrChange{
+				{Kind: RandrCrtcChange, Crtc: 0x30, Mode: 0x50, Width: 2560, Height: 1440},
+				{Kind: RandrOutputChange, Output: 0x60, Crtc: 0x30, Mode: 0x50, Connection: randr.ConnectionConnected},
+			},
+			false, false,
+			nil,
+		},
+		{
+			// CRTC not reported before
+			[]RandrChange{{Kind: RandrOutputChange, Output: 0x61, Crtc: 0x32, Mode: 0x50, Connection: randr.ConnectionConnected}},
+			false, false,
+			nil,
+		},
+		{
+			// newly connected output
+			[]RandrChange{{Kind: RandrOutputChange, Output: 0x62, Connection: randr.ConnectionConnected}},
+			false, false,
+			nil,
+		},
+		{
+			// unknown output
+			[]RandrChange{{Kind: RandrOutputChange, Output: 0x63, Connection: randr.ConnectionDisconnected}},
+			false, false,
+			nil,
+		},
+		{
+			// the new value of properties rules use is not known
+			[]RandrChange{{Kind: RandrOutputProperty, Output: 0x60, Atom: 0x200}},
+			false, false,
+			nil,
+		},
+		{
+			// other properties are not relevant
+			[]RandrChange{{Kind: RandrOutputProperty, Output: 0x60, Atom: 0x201}},
+			false, true,
+			map[string]string{"eDP-1": "1920x1080@60.00 1920x1080+0+0 normal 309mm x 174mm"},
+		},
+		{
+			[]RandrChange{{Kind: RandrOutputProperty, Output: 0x99, Atom: 0x201}},
+			false, false,
+			nil,
+		},
+		{
+			// a new provider may bring new outputs
+			[]RandrChange{{Kind: RandrProviderChange, Provider: 0x116}},
+			false, false,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			c := testRandrCache()
+			before := c.Outputs()
+
+			var changed, ok bool
+			for _, change := range test.changes {
+				var ch bool
+				ch, ok = c.Apply(change)
+				if !ok {
+					break
+				}
+				changed = changed || ch
+			}
+
+			if ok != test.ok {
+				t.Fatalf("wrong result, want ok %v, got %v", test.ok, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if changed != test.changed {
+				t.Fatalf("wrong result, want changed %v, got %v", test.changed, changed)
+			}
+
+			active := make(map[string]string)
+			for _, output := range c.Outputs() {
+				for _, mode := range output.Modes {
+					if mode.Active {
+						active[output.Name] = fmt.Sprintf("%v@%.2f %v %dmm x %dmm",
+							mode.Name, mode.ActiveRate, output.Placement(), output.WidthMM, output.HeightMM)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(active, test.active) {
+				t.Fatalf("wrong active modes:\n  want %v\n  got  %v", test.active, active)
+			}
+
+			// outputs returned before must not be modified
+			if !reflect.DeepEqual(before, testRandrCache().Outputs()) {
+				t.Fatalf("outputs returned earlier have been modified: %v", before)
+			}
+		})
+	}
+}
+
+func TestWatchedProperties(t *testing.T) {
+	cfg := &Config{
+		Rules: []Rule{
+			{OutputsProperties: map[string]map[string]string{"DP*": {"PATH": "mst:*", "link-status": "Good"}}},
+			{OutputsProperties: map[string]map[string]string{"HDMI*": {"PATH": "*"}}},
+		},
+	}
+
+	var tests = []struct {
+		cfg   *Config
+		names []string
+	}{
+		{nil, []string{"EDID", "link-status"}},
+		{&Config{}, []string{"EDID", "link-status"}},
+		{cfg, []string{"EDID", "link-status", "PATH"}},
+	}
+
+	for _, test := range tests {
+		names := watchedProperties(test.cfg)
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("wrong properties returned:\n  want %v\n  got  %v", test.names, names)
+		}
+	}
+}
@@ -0,0 +1,1066 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// errConnectionLost is returned when the connection to the X server or the
+// session bus was lost, e.g. because the display manager was restarted.
+var errConnectionLost = errors.New("connection to the display server lost")
+
+// RandrConn is a connection to the X server with the RANDR extension
+// initialised.
+type RandrConn struct {
+	X    *xgb.Conn
+	Root xproto.Window
+
+	// Screen is the index of the default screen, Root is its root window.
+	// Screens is the number of screens of the X server.
+	Screen  int
+	Screens int
+
+	mu     sync.Mutex
+	lost   bool
+	closed bool
+
+	// atomNames caches the names of atoms, which never change while the
+	// connection is open.
+	atomNames map[xproto.Atom]string
+}
+
+// NewRandrConn connects to the X server and initialises the RANDR extension.
+func NewRandrConn() (*RandrConn, error) {
+	X, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = randr.Init(X); err != nil {
+		X.Close()
+		return nil, err
+	}
+
+	setup := xproto.Setup(X)
+	return &RandrConn{
+		X:       X,
+		Root:    setup.DefaultScreen(X).Root,
+		Screen:  X.DefaultScreen,
+		Screens: len(setup.Roots),
+	}, nil
+}
+
+// screen returns the size limits of the default screen.
+func (c *RandrConn) screen() (s Screen, err error) {
+	if c.Lost() {
+		return Screen{}, errConnectionLost
+	}
+	defer c.recoverLost(&err)
+
+	sizes, err := randr.GetScreenSizeRange(c.X, c.Root).Reply()
+	if err != nil {
+		return Screen{}, fmt.Errorf("querying screen size range: %w", err)
+	}
+
+	geometry, err := xproto.GetGeometry(c.X, xproto.Drawable(c.Root)).Reply()
+	if err != nil {
+		return Screen{}, fmt.Errorf("querying screen size: %w", err)
+	}
+
+	return Screen{
+		Index:   c.Screen,
+		Minimum: Size{int(sizes.MinWidth), int(sizes.MinHeight)},
+		Current: Size{int(geometry.Width), int(geometry.Height)},
+		Maximum: Size{int(sizes.MaxWidth), int(sizes.MaxHeight)},
+	}, nil
+}
+
+var (
+	screenCountOnce sync.Once
+	screenCount     = 1
+)
+
+// xScreenCount returns the number of screens of the X server. It is only
+// determined once, one screen is assumed if the X server cannot be reached.
+func xScreenCount() int {
+	screenCountOnce.Do(func() {
+		X, err := xgb.NewConn()
+		if err != nil {
+			V("unable to determine the number of X screens: %v\n", err)
+			return
+		}
+		defer X.Close()
+
+		screenCount = len(xproto.Setup(X).Roots)
+	})
+
+	return screenCount
+}
+
+// Close closes the connection to the X server, it may be called more than
+// once.
+func (c *RandrConn) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// xgb closes the connection by itself when it is lost
+	if !c.closed && !c.lost {
+		c.X.Close()
+	}
+	c.closed = true
+}
+
+// markLost records that the connection to the X server was lost.
+func (c *RandrConn) markLost() {
+	c.mu.Lock()
+	c.lost = true
+	c.mu.Unlock()
+}
+
+// recoverLost must be deferred by all functions which send requests to the X
+// server. xgb closes the connection after a read error and requests sent
+// afterwards panic, the panic is turned into errConnectionLost, which is
+// stored in err.
+func (c *RandrConn) recoverLost(err *error) {
+	if r := recover(); r != nil {
+		c.markLost()
+		*err = fmt.Errorf("%w: %v", errConnectionLost, r)
+	}
+}
+
+// Lost returns true if the connection to the X server was lost.
+func (c *RandrConn) Lost() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lost
+}
+
+// waitForEvent returns the next event received from the X server. X errors
+// are not fatal, they are logged and skipped. When the connection is lost,
+// errConnectionLost is returned. When it was closed, both the event and the
+// error are nil.
+func (c *RandrConn) waitForEvent() (xgb.Event, error) {
+	for {
+		ev, err := c.X.WaitForEvent()
+		if err != nil {
+			V("X error received: %v\n", err)
+			continue
+		}
+
+		if ev != nil {
+			return ev, nil
+		}
+
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+
+		if closed {
+			return nil, nil
+		}
+
+		c.markLost()
+		return nil, errConnectionLost
+	}
+}
+
+// screenResources holds the reply of either GetScreenResources or
+// GetScreenResourcesCurrent.
+type screenResources struct {
+	ConfigTimestamp xproto.Timestamp
+	Crtcs           []randr.Crtc
+	Outputs         []randr.Output
+	Modes           []randr.ModeInfo
+	Names           []byte
+}
+
+// resources returns the screen resources. When detect is set, the X server
+// is asked to probe the outputs for changes, which may take a while.
+func (c *RandrConn) resources(detect bool) (screenResources, error) {
+	if detect {
+		res, err := randr.GetScreenResources(c.X, c.Root).Reply()
+		if err != nil {
+			return screenResources{}, err
+		}
+
+		return screenResources{
+			ConfigTimestamp: res.ConfigTimestamp,
+			Crtcs:           res.Crtcs,
+			Outputs:         res.Outputs,
+			Modes:           res.Modes,
+			Names:           res.Names,
+		}, nil
+	}
+
+	res, err := randr.GetScreenResourcesCurrent(c.X, c.Root).Reply()
+	if err != nil {
+		return screenResources{}, err
+	}
+
+	return screenResources{
+		ConfigTimestamp: res.ConfigTimestamp,
+		Crtcs:           res.Crtcs,
+		Outputs:         res.Outputs,
+		Modes:           res.Modes,
+		Names:           res.Names,
+	}, nil
+}
+
+// modeNames returns a map of mode IDs to mode names.
+func (res screenResources) modeNames() map[randr.Mode]string {
+	names := make(map[randr.Mode]string, len(res.Modes))
+
+	offset := 0
+	for _, mode := range res.Modes {
+		end := offset + int(mode.NameLen)
+		if end > len(res.Names) {
+			break
+		}
+		names[randr.Mode(mode.Id)] = string(res.Names[offset:end])
+		offset = end
+	}
+
+	return names
+}
+
+// modeRates returns a map of mode IDs to refresh rates, rounded to two
+// decimals like xrandr prints them.
+func (res screenResources) modeRates() map[randr.Mode]float64 {
+	rates := make(map[randr.Mode]float64, len(res.Modes))
+
+	for _, mode := range res.Modes {
+		vtotal := float64(mode.Vtotal)
+		if mode.ModeFlags&randr.ModeFlagDoubleScan != 0 {
+			vtotal *= 2
+		}
+		if mode.ModeFlags&randr.ModeFlagInterlace != 0 {
+			vtotal /= 2
+		}
+
+		if mode.Htotal == 0 || vtotal == 0 {
+			rates[randr.Mode(mode.Id)] = 0
+			continue
+		}
+
+		rate := float64(mode.DotClock) / (float64(mode.Htotal) * vtotal)
+		rates[randr.Mode(mode.Id)] = math.Round(rate*100) / 100
+	}
+
+	return rates
+}
+
+// buildModes returns the list of modes for an output in the same form
+// `xrandr` prints them: modes sharing a name are collapsed into one entry,
+// which is active or default if any of the collapsed modes is.
+func buildModes(names map[randr.Mode]string, rates map[randr.Mode]float64, modes []randr.Mode, preferred int, active randr.Mode) Modes {
+	var list Modes
+	index := make(map[string]int)
+
+	for i, id := range modes {
+		name := names[id]
+
+		pos, ok := index[name]
+		if !ok {
+			pos = len(list)
+			index[name] = pos
+			list = append(list, Mode{Name: name})
+		}
+
+		list[pos].addRate(rates[id], id == active, i < preferred)
+	}
+
+	return list
+}
+
+// rotationNames returns the names xrandr uses for the rotation and
+// reflection in the RANDR rotation bitmask.
+func rotationNames(rotation uint16) (string, string) {
+	var rot string
+	switch {
+	case rotation&randr.RotationRotate90 != 0:
+		rot = "left"
+	case rotation&randr.RotationRotate180 != 0:
+		rot = "inverted"
+	case rotation&randr.RotationRotate270 != 0:
+		rot = "right"
+	default:
+		rot = "normal"
+	}
+
+	var refl string
+	switch {
+	case rotation&randr.RotationReflectX != 0 && rotation&randr.RotationReflectY != 0:
+		refl = "X and Y axis"
+	case rotation&randr.RotationReflectX != 0:
+		refl = "X axis"
+	case rotation&randr.RotationReflectY != 0:
+		refl = "Y axis"
+	}
+
+	return rot, refl
+}
+
+// atom returns the atom for name, or xproto.AtomNone if it does not exist.
+func (c *RandrConn) atom(name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(c.X, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return xproto.AtomNone, err
+	}
+
+	return reply.Atom, nil
+}
+
+// atomName returns the name of atom.
+func (c *RandrConn) atomName(atom xproto.Atom) (string, error) {
+	c.mu.Lock()
+	name, ok := c.atomNames[atom]
+	c.mu.Unlock()
+
+	if ok {
+		return name, nil
+	}
+
+	reply, err := xproto.GetAtomName(c.X, atom).Reply()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if c.atomNames == nil {
+		c.atomNames = make(map[xproto.Atom]string)
+	}
+	c.atomNames[atom] = reply.Name
+	c.mu.Unlock()
+
+	return reply.Name, nil
+}
+
+// properties returns the properties of the output formatted like `xrandr
+// --props` prints them. The EDID is skipped, it is returned by edid.
+func (c *RandrConn) properties(output randr.Output, edidAtom xproto.Atom) (Properties, error) {
+	list, err := randr.ListOutputProperties(c.X, output).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	// send all requests before waiting for the replies
+	var atoms []xproto.Atom
+	var values []randr.GetOutputPropertyCookie
+	var infos []randr.QueryOutputPropertyCookie
+	for _, atom := range list.Atoms {
+		if atom == edidAtom {
+			continue
+		}
+
+		atoms = append(atoms, atom)
+		values = append(values, randr.GetOutputProperty(c.X, output, atom, xproto.AtomAny, 0, 128, false, false))
+		infos = append(infos, randr.QueryOutputProperty(c.X, output, atom))
+	}
+
+	var props Properties
+	for i, atom := range atoms {
+		value, err := values[i].Reply()
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := infos[i].Reply()
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := c.atomName(atom)
+		if err != nil {
+			return nil, err
+		}
+
+		prop, err := decodeProperty(value, info, c.atomName)
+		if err != nil {
+			return nil, fmt.Errorf("property %v: %w", name, err)
+		}
+
+		if props == nil {
+			props = make(Properties)
+		}
+		props[name] = prop
+	}
+
+	return props, nil
+}
+
+// decodeProperty returns the property described by the replies of
+// GetOutputProperty and QueryOutputProperty, atomName is used to look up the
+// names of atoms.
+func decodeProperty(value *randr.GetOutputPropertyReply, info *randr.QueryOutputPropertyReply, atomName func(xproto.Atom) (string, error)) (Property, error) {
+	var prop Property
+
+	switch {
+	case value.Type == xproto.AtomInteger && value.Format == 8:
+		// binary data, xrandr prints it as hex
+		prop.Value = hex.EncodeToString(value.Data)
+		return prop, nil
+
+	case value.Type == xproto.AtomString && value.Format == 8:
+		prop.Value = strings.TrimRight(string(value.Data), "\x00")
+		return prop, nil
+	}
+
+	size := int(value.Format) / 8
+	if size == 0 {
+		return prop, nil
+	}
+
+	var items []string
+	for i := 0; i+size <= len(value.Data) && len(items) < int(value.NumItems); i += size {
+		var v uint32
+		switch size {
+		case 1:
+			v = uint32(value.Data[i])
+		case 2:
+			v = uint32(xgb.Get16(value.Data[i:]))
+		default:
+			v = xgb.Get32(value.Data[i:])
+		}
+
+		item, err := formatPropertyValue(value.Type, value.Format, v, atomName)
+		if err != nil {
+			return Property{}, err
+		}
+		items = append(items, item)
+	}
+	prop.Value = strings.Join(items, " ")
+
+	if info.Range {
+		if len(info.ValidValues) >= 2 {
+			prop.Range = &PropertyRange{Min: int64(info.ValidValues[0]), Max: int64(info.ValidValues[1])}
+		}
+		return prop, nil
+	}
+
+	for _, v := range info.ValidValues {
+		item, err := formatPropertyValue(value.Type, 32, uint32(v), atomName)
+		if err != nil {
+			return Property{}, err
+		}
+		prop.Supported = append(prop.Supported, item)
+	}
+
+	return prop, nil
+}
+
+// formatPropertyValue returns a single value of a property of the given type
+// and format as a string.
+func formatPropertyValue(typ xproto.Atom, format byte, v uint32, atomName func(xproto.Atom) (string, error)) (string, error) {
+	switch typ {
+	case xproto.AtomAtom:
+		return atomName(xproto.Atom(v))
+
+	case xproto.AtomInteger:
+		switch format {
+		case 8:
+			return strconv.FormatInt(int64(int8(v)), 10), nil
+		case 16:
+			return strconv.FormatInt(int64(int16(v)), 10), nil
+		}
+		return strconv.FormatInt(int64(int32(v)), 10), nil
+	}
+
+	return strconv.FormatUint(uint64(v), 10), nil
+}
+
+// edid returns the raw EDID of the output, which is empty if none is
+// available.
+func (c *RandrConn) edid(output randr.Output, edidAtom xproto.Atom) ([]byte, error) {
+	if edidAtom == xproto.AtomNone {
+		return nil, nil
+	}
+
+	// the length is given in units of four bytes, 128 is enough for the base
+	// block and three extension blocks
+	reply, err := randr.GetOutputProperty(c.X, output, edidAtom, xproto.AtomAny, 0, 128, false, false).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	return reply.Data, nil
+}
+
+// Outputs queries the X server for the list of outputs. When detect is set,
+// the outputs are probed for changes like `xrandr` without `--current` does.
+func (c *RandrConn) Outputs(detect bool) (Outputs, error) {
+	cache, err := c.query(detect)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.outputs, nil
+}
+
+// query returns the list of outputs together with the RANDR IDs needed to
+// apply change events to it.
+func (c *RandrConn) query(detect bool) (cache *randrCache, err error) {
+	if c.Lost() {
+		return nil, errConnectionLost
+	}
+
+	defer c.recoverLost(&err)
+
+	res, err := c.resources(detect)
+	if err != nil {
+		return nil, fmt.Errorf("querying screen resources: %w", err)
+	}
+
+	primary, err := randr.GetOutputPrimary(c.X, c.Root).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("querying primary output: %w", err)
+	}
+
+	edidAtom, err := c.atom("EDID")
+	if err != nil {
+		return nil, fmt.Errorf("querying EDID atom: %w", err)
+	}
+
+	names := res.modeNames()
+	rates := res.modeRates()
+	cache = newRandrCache(names, rates)
+
+	for _, name := range watchedProperties(globalOpts.cfg) {
+		atom, err := c.atom(name)
+		if err != nil {
+			return nil, fmt.Errorf("querying %v atom: %w", name, err)
+		}
+		if atom != xproto.AtomNone {
+			cache.watched[atom] = struct{}{}
+		}
+	}
+
+	for _, id := range res.Outputs {
+		info, err := randr.GetOutputInfo(c.X, id, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return nil, fmt.Errorf("querying output %d: %w", id, err)
+		}
+
+		output := Output{
+			Name:      string(info.Name),
+			Connected: info.Connection == randr.ConnectionConnected,
+			Unknown:   info.Connection == randr.ConnectionUnknown,
+			Primary:   primary.Output == id,
+			Screen:    c.Screen,
+		}
+
+		var active randr.Mode
+		if info.Crtc != 0 {
+			crtc, err := randr.GetCrtcInfo(c.X, info.Crtc, res.ConfigTimestamp).Reply()
+			if err != nil {
+				return nil, fmt.Errorf("querying crtc %d for output %v: %w", info.Crtc, output.Name, err)
+			}
+			active = crtc.Mode
+
+			state := randrCrtcState{
+				Mode: crtc.Mode,
+				Geometry: Geometry{
+					X:      int(crtc.X),
+					Y:      int(crtc.Y),
+					Width:  int(crtc.Width),
+					Height: int(crtc.Height),
+				},
+				Rotation: crtc.Rotation,
+			}
+			cache.setCrtc(info.Crtc, state)
+
+			if active != 0 {
+				output.Geometry = state.Geometry
+				output.Rotation, output.Reflection = rotationNames(crtc.Rotation)
+				output.WidthMM = int(info.MmWidth)
+				output.HeightMM = int(info.MmHeight)
+			}
+		}
+
+		if output.Connected {
+			output.Modes = buildModes(names, rates, info.Modes, int(info.NumPreferred), active)
+		} else if active != 0 {
+			// disconnected but still active, xrandr only lists the active mode
+			mode := Mode{Name: names[active]}
+			mode.addRate(rates[active], true, false)
+			output.Modes = Modes{mode}
+		}
+
+		edid, err := c.edid(id, edidAtom)
+		if err != nil {
+			return nil, fmt.Errorf("querying EDID for output %v: %w", output.Name, err)
+		}
+
+		if len(edid) > 0 {
+			// a monitor which cannot be identified must not break the
+			// other outputs
+			e, err := ParseEDID(edid)
+			if err == nil && e.ChecksumMismatch {
+				err = errEDIDChecksum
+			}
+
+			if err != nil {
+				w := ParseWarning{Output: output.Name, Err: err}
+				V("RANDR query %v\n", w)
+				output.Warnings = append(output.Warnings, w)
+			}
+
+			if e != nil {
+				output.EDID = e
+				output.MonitorID = e.MonitorID()
+				output.Vendor = e.Vendor
+			}
+		}
+
+		output.Properties, err = c.properties(id, edidAtom)
+		if err != nil {
+			return nil, fmt.Errorf("querying properties for output %v: %w", output.Name, err)
+		}
+
+		cache.add(id, randrOutputState{
+			Crtc:       info.Crtc,
+			Connection: info.Connection,
+			WidthMM:    int(info.MmWidth),
+			HeightMM:   int(info.MmHeight),
+		}, output)
+	}
+
+	return cache, nil
+}
+
+// crtcConfig is the configuration of one CRTC.
+type crtcConfig struct {
+	Crtc     randr.Crtc
+	X, Y     int16
+	Mode     randr.Mode
+	Rotation uint16
+	Outputs  []randr.Output
+
+	// Width and Height are the size of the area the CRTC scans out.
+	Width, Height int
+}
+
+// crtcPlan is the list of changes needed to apply a layout.
+type crtcPlan struct {
+	ConfigTimestamp xproto.Timestamp
+
+	// Width and Height are the new size of the screen in pixels, WidthMM and
+	// HeightMM the physical size.
+	Width, Height     int
+	WidthMM, HeightMM int
+
+	// Old is the current configuration of the CRTCs which are changed.
+	Old []crtcConfig
+
+	// New is the configuration of the CRTCs after the change. CRTCs without
+	// a mode are disabled.
+	New []crtcConfig
+
+	// OldWidth, OldHeight, OldWidthMM and OldHeightMM describe the screen
+	// before the change.
+	OldWidth, OldHeight     int
+	OldWidthMM, OldHeightMM int
+
+	Primary     randr.Output
+	OldPrimary  randr.Output
+	PrimaryName string
+
+	// Names maps outputs and modes to names for printing the plan.
+	OutputNames map[randr.Output]string
+	ModeNames   map[randr.Mode]string
+
+	// ModeRates maps modes to refresh rates.
+	ModeRates map[randr.Mode]float64
+}
+
+// planLayout computes the CRTC configuration for layout.
+func (c *RandrConn) planLayout(layout Layout) (_ crtcPlan, err error) {
+	if c.Lost() {
+		return crtcPlan{}, errConnectionLost
+	}
+	defer c.recoverLost(&err)
+
+	res, err := c.resources(false)
+	if err != nil {
+		return crtcPlan{}, fmt.Errorf("querying screen resources: %w", err)
+	}
+
+	plan := crtcPlan{
+		ConfigTimestamp: res.ConfigTimestamp,
+		OutputNames:     make(map[randr.Output]string),
+		ModeNames:       res.modeNames(),
+		ModeRates:       res.modeRates(),
+	}
+
+	infos := make(map[string]*randr.GetOutputInfoReply)
+	ids := make(map[string]randr.Output)
+	for _, id := range res.Outputs {
+		info, err := randr.GetOutputInfo(c.X, id, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return crtcPlan{}, fmt.Errorf("querying output %d: %w", id, err)
+		}
+		infos[string(info.Name)] = info
+		ids[string(info.Name)] = id
+		plan.OutputNames[id] = string(info.Name)
+	}
+
+	current := make(map[randr.Crtc]crtcConfig)
+	for _, crtc := range res.Crtcs {
+		info, err := randr.GetCrtcInfo(c.X, crtc, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return crtcPlan{}, fmt.Errorf("querying crtc %d: %w", crtc, err)
+		}
+		current[crtc] = crtcConfig{
+			Crtc:     crtc,
+			X:        info.X,
+			Y:        info.Y,
+			Mode:     info.Mode,
+			Rotation: info.Rotation,
+			Outputs:  info.Outputs,
+			Width:    int(info.Width),
+			Height:   int(info.Height),
+		}
+	}
+
+	primary, err := randr.GetOutputPrimary(c.X, c.Root).Reply()
+	if err != nil {
+		return crtcPlan{}, fmt.Errorf("querying primary output: %w", err)
+	}
+	plan.OldPrimary = primary.Output
+	plan.Primary = primary.Output
+
+	geometry, err := xproto.GetGeometry(c.X, xproto.Drawable(c.Root)).Reply()
+	if err != nil {
+		return crtcPlan{}, fmt.Errorf("querying screen size: %w", err)
+	}
+	plan.OldWidth, plan.OldHeight = int(geometry.Width), int(geometry.Height)
+
+	// CRTCs which are used by outputs which are neither enabled nor disabled
+	// by the layout keep their configuration
+	changed := make(map[string]struct{})
+	for _, name := range layout.Disable {
+		changed[name] = struct{}{}
+	}
+	for _, out := range layout.Outputs {
+		changed[out.Name] = struct{}{}
+	}
+
+	used := make(map[randr.Crtc]struct{})
+	for crtc, cfg := range current {
+		for _, id := range cfg.Outputs {
+			if _, ok := changed[plan.OutputNames[id]]; !ok {
+				used[crtc] = struct{}{}
+			}
+		}
+	}
+
+	// first keep the CRTC an output is currently driven by, then assign free
+	// CRTCs to the remaining outputs
+	assigned := make(map[string]randr.Crtc)
+	for _, out := range layout.Outputs {
+		info, ok := infos[out.Name]
+		if !ok {
+			return crtcPlan{}, fmt.Errorf("output %v not found", out.Name)
+		}
+
+		if info.Crtc == 0 {
+			continue
+		}
+
+		if _, ok := used[info.Crtc]; ok {
+			continue
+		}
+
+		assigned[out.Name] = info.Crtc
+		used[info.Crtc] = struct{}{}
+	}
+
+	for _, out := range layout.Outputs {
+		if _, ok := assigned[out.Name]; ok {
+			continue
+		}
+
+		for _, crtc := range infos[out.Name].Crtcs {
+			if _, ok := used[crtc]; ok {
+				continue
+			}
+
+			assigned[out.Name] = crtc
+			used[crtc] = struct{}{}
+			break
+		}
+
+		if _, ok := assigned[out.Name]; !ok {
+			return crtcPlan{}, fmt.Errorf("no free crtc found for output %v", out.Name)
+		}
+	}
+
+	// build the new configuration for all assigned CRTCs
+	configured := make(map[randr.Crtc]struct{})
+	for _, out := range layout.Outputs {
+		info := infos[out.Name]
+		crtc := assigned[out.Name]
+
+		mode, err := findModeID(plan.ModeNames, plan.ModeRates, info, out.Mode, out.Rate)
+		if err != nil {
+			return crtcPlan{}, fmt.Errorf("output %v: %w", out.Name, err)
+		}
+
+		// an active output keeps its rotation, even if it moves to another
+		// CRTC, ComputeLayout places it accordingly
+		rotation := uint16(randr.RotationRotate0)
+		if cur := current[info.Crtc]; info.Crtc != 0 && cur.Mode != 0 {
+			rotation = cur.Rotation
+		}
+
+		plan.Old = append(plan.Old, current[crtc])
+		plan.New = append(plan.New, crtcConfig{
+			Crtc:     crtc,
+			X:        int16(out.X),
+			Y:        int16(out.Y),
+			Mode:     mode,
+			Rotation: rotation,
+			Outputs:  []randr.Output{ids[out.Name]},
+			Width:    out.Width,
+			Height:   out.Height,
+		})
+		configured[crtc] = struct{}{}
+
+		if out.Primary {
+			plan.Primary = ids[out.Name]
+			plan.PrimaryName = out.Name
+		}
+	}
+
+	// disable all other CRTCs driving changed outputs
+	for _, crtc := range res.Crtcs {
+		cfg := current[crtc]
+		if cfg.Mode == 0 {
+			continue
+		}
+
+		if _, ok := configured[crtc]; ok {
+			continue
+		}
+
+		for _, id := range cfg.Outputs {
+			if _, ok := changed[plan.OutputNames[id]]; ok {
+				plan.Old = append(plan.Old, cfg)
+				plan.New = append(plan.New, crtcConfig{Crtc: crtc, Rotation: randr.RotationRotate0})
+				break
+			}
+		}
+	}
+
+	// the screen needs to contain all CRTCs which stay active
+	plan.Width, plan.Height = layout.Width, layout.Height
+	for crtc := range used {
+		if _, ok := configured[crtc]; ok {
+			continue
+		}
+
+		cfg := current[crtc]
+		if cfg.Mode == 0 {
+			continue
+		}
+
+		if w := int(cfg.X) + cfg.Width; w > plan.Width {
+			plan.Width = w
+		}
+		if h := int(cfg.Y) + cfg.Height; h > plan.Height {
+			plan.Height = h
+		}
+	}
+
+	sizes, err := randr.GetScreenSizeRange(c.X, c.Root).Reply()
+	if err != nil {
+		return crtcPlan{}, fmt.Errorf("querying screen size range: %w", err)
+	}
+
+	if plan.Width < int(sizes.MinWidth) {
+		plan.Width = int(sizes.MinWidth)
+	}
+	if plan.Height < int(sizes.MinHeight) {
+		plan.Height = int(sizes.MinHeight)
+	}
+	if plan.Width > int(sizes.MaxWidth) || plan.Height > int(sizes.MaxHeight) {
+		return crtcPlan{}, fmt.Errorf("screen size %dx%d exceeds maximum %dx%d",
+			plan.Width, plan.Height, sizes.MaxWidth, sizes.MaxHeight)
+	}
+
+	// keep the DPI the X server was started with like xrandr does, or fall
+	// back to 96 DPI
+	dpi := 96.0
+	screen := xproto.Setup(c.X).DefaultScreen(c.X)
+	if screen.HeightInMillimeters > 0 {
+		dpi = 25.4 * float64(screen.HeightInPixels) / float64(screen.HeightInMillimeters)
+	}
+	plan.WidthMM = int(25.4*float64(plan.Width)/dpi + 0.5)
+	plan.HeightMM = int(25.4*float64(plan.Height)/dpi + 0.5)
+	plan.OldWidthMM = int(25.4*float64(plan.OldWidth)/dpi + 0.5)
+	plan.OldHeightMM = int(25.4*float64(plan.OldHeight)/dpi + 0.5)
+
+	return plan, nil
+}
+
+// findModeID returns the ID of the named mode for the output. If rate is not
+// zero, the mode with the closest refresh rate is used, otherwise preferred
+// modes are used first.
+func findModeID(names map[randr.Mode]string, rates map[randr.Mode]float64, info *randr.GetOutputInfoReply, name string, rate float64) (randr.Mode, error) {
+	if rate != 0 {
+		var best randr.Mode
+		for _, id := range info.Modes {
+			if names[id] != name {
+				continue
+			}
+
+			if best == 0 || math.Abs(rates[id]-rate) < math.Abs(rates[best]-rate) {
+				best = id
+			}
+		}
+
+		if best != 0 {
+			return best, nil
+		}
+
+		return 0, fmt.Errorf("mode %v not found", name)
+	}
+
+	for i, id := range info.Modes {
+		if i < int(info.NumPreferred) && names[id] == name {
+			return id, nil
+		}
+	}
+
+	for _, id := range info.Modes {
+		if names[id] == name {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("mode %v not found", name)
+}
+
+// describe returns a human-readable description of the CRTC configuration.
+func (plan crtcPlan) describe(cfg crtcConfig) string {
+	if cfg.Mode == 0 {
+		return fmt.Sprintf("crtc %d: off", cfg.Crtc)
+	}
+
+	var names []string
+	for _, id := range cfg.Outputs {
+		names = append(names, plan.OutputNames[id])
+	}
+
+	return fmt.Sprintf("crtc %d: %v %v@%.2f+%d+%d", cfg.Crtc, names,
+		plan.ModeNames[cfg.Mode], plan.ModeRates[cfg.Mode], cfg.X, cfg.Y)
+}
+
+// setCrtc applies the configuration to a CRTC.
+func (c *RandrConn) setCrtc(cfg crtcConfig, timestamp xproto.Timestamp) error {
+	reply, err := randr.SetCrtcConfig(c.X, cfg.Crtc, xproto.TimeCurrentTime, timestamp,
+		cfg.X, cfg.Y, cfg.Mode, cfg.Rotation, cfg.Outputs).Reply()
+	if err != nil {
+		return err
+	}
+
+	if reply.Status != randr.SetConfigSuccess {
+		return fmt.Errorf("crtc %d: SetCrtcConfig failed with status %d", cfg.Crtc, reply.Status)
+	}
+
+	return nil
+}
+
+// executePlan switches off all CRTCs which are changed, resizes the screen and
+// then enables the new CRTC configuration.
+func (c *RandrConn) executePlan(plan crtcPlan, configs []crtcConfig, width, height, widthMM, heightMM int, primary randr.Output) error {
+	for _, cfg := range configs {
+		off := crtcConfig{Crtc: cfg.Crtc, Rotation: randr.RotationRotate0}
+		if err := c.setCrtc(off, plan.ConfigTimestamp); err != nil {
+			return err
+		}
+	}
+
+	err := randr.SetScreenSizeChecked(c.X, c.Root, uint16(width), uint16(height),
+		uint32(widthMM), uint32(heightMM)).Check()
+	if err != nil {
+		return fmt.Errorf("setting screen size %dx%d: %w", width, height, err)
+	}
+
+	for _, cfg := range configs {
+		if cfg.Mode == 0 {
+			continue
+		}
+
+		if err := c.setCrtc(cfg, plan.ConfigTimestamp); err != nil {
+			return err
+		}
+	}
+
+	return randr.SetOutputPrimaryChecked(c.X, c.Root, primary).Check()
+}
+
+// ApplyLayout configures all outputs of layout in one step. The X server is
+// grabbed during the change, so clients never see an intermediate state. When
+// one of the changes fails, the previous configuration is restored.
+func (c *RandrConn) ApplyLayout(layout Layout) (err error) {
+	if c.Lost() {
+		return errConnectionLost
+	}
+
+	// registered first, so it also covers ungrabbing the server
+	defer c.recoverLost(&err)
+
+	plan, err := c.planLayout(layout)
+	if err != nil {
+		return err
+	}
+
+	if globalOpts.DryRun {
+		fmt.Printf("screen size %dx%d (%dmm x %dmm)\n", plan.Width, plan.Height, plan.WidthMM, plan.HeightMM)
+		for _, cfg := range plan.New {
+			fmt.Printf("%s\n", plan.describe(cfg))
+		}
+		if plan.PrimaryName != "" {
+			fmt.Printf("primary %s\n", plan.PrimaryName)
+		}
+		return nil
+	}
+
+	for _, cfg := range plan.New {
+		V("%s\n", plan.describe(cfg))
+	}
+
+	err = xproto.GrabServerChecked(c.X).Check()
+	if err != nil {
+		return fmt.Errorf("grabbing X server: %w", err)
+	}
+
+	defer func() {
+		uerr := xproto.UngrabServerChecked(c.X).Check()
+		if uerr != nil && err == nil {
+			err = fmt.Errorf("ungrabbing X server: %w", uerr)
+		}
+	}()
+
+	err = c.executePlan(plan, plan.New, plan.Width, plan.Height, plan.WidthMM, plan.HeightMM, plan.Primary)
+	if err == nil {
+		return nil
+	}
+
+	V("applying layout failed, restoring previous configuration: %v\n", err)
+	rerr := c.executePlan(plan, plan.Old, plan.OldWidth, plan.OldHeight, plan.OldWidthMM, plan.OldHeightMM, plan.OldPrimary)
+	if rerr != nil {
+		return fmt.Errorf("%v, restoring previous configuration failed: %v", err, rerr)
+	}
+
+	return err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+func TestBuildModes(t *testing.T) {
+	res := screenResources{
+		Modes: []randr.ModeInfo{
+			{Id: 0x40, NameLen: 9, DotClock: 148500000, Htotal: 2200, Vtotal: 1125},
+			{Id: 0x41, NameLen: 9, DotClock: 74250000, Htotal: 2640, Vtotal: 1125, ModeFlags: randr.ModeFlagInterlace},
+			{Id: 0x42, NameLen: 9, DotClock: 162000000, Htotal: 2160, Vtotal: 1250},
+			{Id: 0x43, NameLen: 7, DotClock: 40000000, Htotal: 1056, Vtotal: 628},
+		},
+		Names: []byte("1920x10801920x10801600x1200800x600"),
+	}
+
+	names := res.modeNames()
+	want := map[randr.Mode]string{
+		0x40: "1920x1080",
+		0x41: "1920x1080",
+		0x42: "1600x1200",
+		0x43: "800x600",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wrong mode names, want %v, got %v", want, names)
+	}
+
+	rates := res.modeRates()
+	wantRates := map[randr.Mode]float64{
+		0x40: 60.00,
+		0x41: 50.00,
+		0x42: 60.00,
+		0x43: 60.32,
+	}
+	if !reflect.DeepEqual(rates, wantRates) {
+		t.Fatalf("wrong mode rates, want %v, got %v", wantRates, rates)
+	}
+
+	var tests = []struct {
+		modes     []randr.Mode
+		preferred int
+		active    randr.Mode
+		result    Modes
+	}{
+		{
+			[]randr.Mode{0x40, 0x41, 0x42, 0x43},
+			1,
+			0x41,
+			Modes{
+				{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.00, 50.00}, ActiveRate: 50.00, DefaultRate: 60.00},
+				{Name: "1600x1200", Rates: []float64{60.00}},
+				{Name: "800x600", Rates: []float64{60.32}},
+			},
+		},
+		{
+			[]randr.Mode{0x42, 0x43},
+			1,
+			0,
+			Modes{
+				{Name: "1600x1200", Default: true, Rates: []float64{60.00}, DefaultRate: 60.00},
+				{Name: "800x600", Rates: []float64{60.32}},
+			},
+		},
+		{
+			[]randr.Mode{0x42, 0x43},
+			0,
+			0x43,
+			Modes{
+				{Name: "1600x1200", Rates: []float64{60.00}},
+				{Name: "800x600", Active: true, Rates: []float64{60.32}, ActiveRate: 60.32},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			modes := buildModes(names, rates, test.modes, test.preferred, test.active)
+			if !reflect.DeepEqual(modes, test.result) {
+				t.Fatalf("wrong modes returned, want %v, got %v", test.result, modes)
+			}
+		})
+	}
+}
+
+func TestFindModeID(t *testing.T) {
+	names := map[randr.Mode]string{
+		0x40: "2560x1440",
+		0x41: "2560x1440",
+		0x42: "2560x1440",
+		0x43: "1920x1080",
+	}
+	rates := map[randr.Mode]float64{
+		0x40: 59.95,
+		0x41: 143.91,
+		0x42: 120.00,
+		0x43: 60.00,
+	}
+	info := &randr.GetOutputInfoReply{
+		Modes:        []randr.Mode{0x40, 0x41, 0x42, 0x43},
+		NumPreferred: 1,
+	}
+
+	var tests = []struct {
+		name string
+		rate float64
+		id   randr.Mode
+	}{
+		{"2560x1440", 0, 0x40},
+		{"2560x1440", 144, 0x41},
+		{"2560x1440", 120, 0x42},
+		{"2560x1440", 60, 0x40},
+		{"1920x1080", 0, 0x43},
+		{"1920x1080", 144, 0x43},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			id, err := findModeID(names, rates, info, test.name, test.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if id != test.id {
+				t.Fatalf("wrong mode returned, want %d, got %d", test.id, id)
+			}
+		})
+	}
+
+	_, err := findModeID(names, rates, info, "800x600", 60)
+	if err == nil {
+		t.Fatal("no error returned for unknown mode")
+	}
+}
+
+func TestDecodeProperty(t *testing.T) {
+	atoms := map[xproto.Atom]string{
+		0x100: "Good",
+		0x101: "Bad",
+	}
+	atomName := func(atom xproto.Atom) (string, error) {
+		name, ok := atoms[atom]
+		if !ok {
+			return "", fmt.Errorf("unknown atom %d", atom)
+		}
+		return name, nil
+	}
+
+	var tests = []struct {
+		value *randr.GetOutputPropertyReply
+		info  *randr.QueryOutputPropertyReply
+		prop  Property
+	}{
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomAtom, Format: 32, NumItems: 1, Data: []byte{0x00, 0x01, 0, 0}},
+			&randr.QueryOutputPropertyReply{ValidValues: []int32{0x100, 0x101}},
+			Property{Value: "Good", Supported: []string{"Good", "Bad"}},
+		},
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomInteger, Format: 32, NumItems: 1, Data: []byte{12, 0, 0, 0}},
+			&randr.QueryOutputPropertyReply{Range: true, ValidValues: []int32{8, 12}},
+			Property{Value: "12", Range: &PropertyRange{8, 12}},
+		},
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomInteger, Format: 32, NumItems: 2, Data: []byte{0xff, 0xff, 0xff, 0xff, 2, 0, 0, 0}},
+			&randr.QueryOutputPropertyReply{},
+			Property{Value: "-1 2"},
+		},
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomCardinal, Format: 32, NumItems: 1, Data: []byte{95, 0, 0, 0}},
+			&randr.QueryOutputPropertyReply{ValidValues: []int32{95}},
+			Property{Value: "95", Supported: []string{"95"}},
+		},
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomString, Format: 8, NumItems: 9, Data: []byte("mst:93-1\x00")},
+			&randr.QueryOutputPropertyReply{},
+			Property{Value: "mst:93-1"},
+		},
+		{
+			&randr.GetOutputPropertyReply{Type: xproto.AtomInteger, Format: 8, NumItems: 4, Data: []byte{0x8b, 0x36, 0xa7, 0xa1}},
+			&randr.QueryOutputPropertyReply{},
+			Property{Value: "8b36a7a1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			prop, err := decodeProperty(test.value, test.info, atomName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(prop, test.prop) {
+				t.Fatalf("wrong property returned, want %v, got %v", test.prop, prop)
+			}
+		})
+	}
+}
+
+func TestRecoverLost(t *testing.T) {
+	c := &RandrConn{}
+
+	err := func() (err error) {
+		defer c.recoverLost(&err)
+		panic("write on closed connection")
+	}()
+
+	if !errors.Is(err, errConnectionLost) {
+		t.Fatalf("wrong error returned: %v", err)
+	}
+
+	if !c.Lost() {
+		t.Fatal("connection not marked as lost")
+	}
+
+	// requests must not be sent on a lost connection
+	_, err = c.screen()
+	if !errors.Is(err, errConnectionLost) {
+		t.Fatalf("wrong error returned by screen: %v", err)
+	}
+
+	err = c.ApplyLayout(Layout{})
+	if !errors.Is(err, errConnectionLost) {
+		t.Fatalf("wrong error returned by ApplyLayout: %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -36,10 +38,10 @@ DP3 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "LVDS1",
 				Modes: []Mode{
-					{Name: "1366x768", Default: true},
-					{Name: "1024x768"},
-					{Name: "800x600"},
-					{Name: "640x480"},
+					{Name: "1366x768", Default: true, Rates: []float64{60.10}, DefaultRate: 60.10},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "640x480", Rates: []float64{59.94}},
 				},
 				Connected: true,
 			},
@@ -49,15 +51,15 @@ DP3 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "HDMI2",
 				Modes: []Mode{
-					{Name: "1600x1200", Default: true, Active: true},
-					{Name: "1280x1024"},
-					{Name: "1280x960"},
-					{Name: "1152x864"},
-					{Name: "1024x768"},
-					{Name: "832x624"},
-					{Name: "800x600"},
-					{Name: "640x480"},
-					{Name: "720x400"},
+					{Name: "1600x1200", Default: true, Active: true, Rates: []float64{60.00}, ActiveRate: 60.00, DefaultRate: 60.00},
+					{Name: "1280x1024", Rates: []float64{75.02, 60.02}},
+					{Name: "1280x960", Rates: []float64{60.00}},
+					{Name: "1152x864", Rates: []float64{75.00}},
+					{Name: "1024x768", Rates: []float64{75.08, 70.07, 60.00}},
+					{Name: "832x624", Rates: []float64{74.55}},
+					{Name: "800x600", Rates: []float64{72.19, 75.00, 60.32, 56.25}},
+					{Name: "640x480", Rates: []float64{75.00, 72.81, 66.67, 60.00}},
+					{Name: "720x400", Rates: []float64{70.08}},
 				},
 				Connected: true,
 			},
@@ -76,15 +78,47 @@ LVDS1 connected (normal left inverted right x axis y axis)
 			{
 				Name: "LVDS1",
 				Modes: []Mode{
-					{Name: "1366x768", Default: true},
-					{Name: "1024x768"},
-					{Name: "800x600"},
-					{Name: "640x480"},
+					{Name: "1366x768", Default: true, Rates: []float64{60.10}, DefaultRate: 60.10},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "640x480", Rates: []float64{59.94}},
 				},
 				Connected: true,
 			},
 		},
 	},
+	{
+		`Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 8192 x 8192
+DVI-0 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 531mm x 299mm
+   1920x1080     60.00*+
+   1280x1024     60.02
+VGA-0 disconnected (normal left inverted right x axis y axis)
+Screen 1: minimum 320 x 200, current 1280 x 1024, maximum 8192 x 8192
+DVI-1-0 connected 1280x1024+0+0 (normal left inverted right x axis y axis) 376mm x 301mm
+   1280x1024     60.02*+   75.02
+HDMI-1-0 disconnected (normal left inverted right x axis y axis)`,
+		[]Output{
+			{
+				Name:      "DVI-0",
+				Connected: true,
+				Primary:   true,
+				Modes: []Mode{
+					{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60}, ActiveRate: 60, DefaultRate: 60},
+					{Name: "1280x1024", Rates: []float64{60.02}},
+				},
+			},
+			{Name: "VGA-0"},
+			{
+				Name:      "DVI-1-0",
+				Connected: true,
+				Modes: []Mode{
+					{Name: "1280x1024", Default: true, Active: true, Rates: []float64{60.02, 75.02}, ActiveRate: 60.02, DefaultRate: 60.02},
+				},
+				Screen: 1,
+			},
+			{Name: "HDMI-1-0", Screen: 1},
+		},
+	},
 	{
 		`Screen 0: minimum 8 x 8, current 4480 x 1440, maximum 32767 x 32767
 eDP1 connected 1920x1080+2560+0 (normal left inverted right x axis y axis) 276mm x 156mm
@@ -131,21 +165,21 @@ VIRTUAL1 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "eDP1",
 				Modes: []Mode{
-					{Name: "1920x1080", Default: true, Active: true},
-					{Name: "1400x1050"},
-					{Name: "1600x900"},
-					{Name: "1280x1024"},
-					{Name: "1280x960"},
-					{Name: "1368x768"},
-					{Name: "1280x720"},
-					{Name: "1024x768"},
-					{Name: "1024x576"},
-					{Name: "960x540"},
-					{Name: "800x600"},
-					{Name: "864x486"},
-					{Name: "640x480"},
-					{Name: "720x405"},
-					{Name: "640x360"},
+					{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.04}, ActiveRate: 60.04, DefaultRate: 60.04},
+					{Name: "1400x1050", Rates: []float64{59.98}},
+					{Name: "1600x900", Rates: []float64{60.00}},
+					{Name: "1280x1024", Rates: []float64{60.02}},
+					{Name: "1280x960", Rates: []float64{60.00}},
+					{Name: "1368x768", Rates: []float64{60.00}},
+					{Name: "1280x720", Rates: []float64{60.00}},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "1024x576", Rates: []float64{60.00}},
+					{Name: "960x540", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "864x486", Rates: []float64{60.00}},
+					{Name: "640x480", Rates: []float64{59.94}},
+					{Name: "720x405", Rates: []float64{60.00}},
+					{Name: "640x360", Rates: []float64{60.00}},
 				},
 				Connected: true,
 			},
@@ -155,22 +189,22 @@ VIRTUAL1 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "DP2-2",
 				Modes: []Mode{
-					{Name: "2560x1440", Default: true, Active: true},
-					{Name: "2048x1152"},
-					{Name: "1920x1200"},
-					{Name: "1920x1080"},
-					{Name: "1600x1200"},
-					{Name: "1680x1050"},
-					{Name: "1280x1024"},
-					{Name: "1200x960"},
-					{Name: "1152x864"},
-					{Name: "1280x720"},
-					{Name: "1024x768"},
-					{Name: "800x600"},
-					{Name: "720x576"},
-					{Name: "720x480"},
-					{Name: "640x480"},
-					{Name: "720x400"},
+					{Name: "2560x1440", Default: true, Active: true, Rates: []float64{59.95}, ActiveRate: 59.95, DefaultRate: 59.95},
+					{Name: "2048x1152", Rates: []float64{60.00}},
+					{Name: "1920x1200", Rates: []float64{59.88}},
+					{Name: "1920x1080", Rates: []float64{60.00, 50.00, 59.94, 30.00, 25.00, 24.00, 29.97, 23.98}},
+					{Name: "1600x1200", Rates: []float64{60.00}},
+					{Name: "1680x1050", Rates: []float64{59.95}},
+					{Name: "1280x1024", Rates: []float64{75.02, 60.02}},
+					{Name: "1200x960", Rates: []float64{59.99}},
+					{Name: "1152x864", Rates: []float64{75.00}},
+					{Name: "1280x720", Rates: []float64{60.00, 50.00, 59.94}},
+					{Name: "1024x768", Rates: []float64{75.08, 60.00}},
+					{Name: "800x600", Rates: []float64{75.00, 60.32}},
+					{Name: "720x576", Rates: []float64{50.00}},
+					{Name: "720x480", Rates: []float64{60.00, 59.94}},
+					{Name: "640x480", Rates: []float64{75.00, 60.00, 59.94}},
+					{Name: "720x400", Rates: []float64{70.08}},
 				},
 				Connected: true,
 				Primary:   true,
@@ -194,10 +228,10 @@ HDMI3 disconnected 1680x1050+1600+0 (normal left inverted right x axis y axis) 0
 			{
 				Name: "LVDS1",
 				Modes: []Mode{
-					{Name: "1366x768", Default: true},
-					{Name: "1024x768"},
-					{Name: "800x600"},
-					{Name: "640x480"},
+					{Name: "1366x768", Default: true, Rates: []float64{60.10}, DefaultRate: 60.10},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "640x480", Rates: []float64{59.94}},
 				},
 				Connected: true,
 			},
@@ -244,21 +278,21 @@ VIRTUAL1 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "eDP1",
 				Modes: []Mode{
-					{Name: "1920x1080", Default: true, Active: true},
-					{Name: "1400x1050"},
-					{Name: "1600x900"},
-					{Name: "1280x1024"},
-					{Name: "1280x960"},
-					{Name: "1368x768"},
-					{Name: "1280x720"},
-					{Name: "1024x768"},
-					{Name: "1024x576"},
-					{Name: "960x540"},
-					{Name: "800x600"},
-					{Name: "864x486"},
-					{Name: "640x480"},
-					{Name: "720x405"},
-					{Name: "640x360"},
+					{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.01}, ActiveRate: 60.01, DefaultRate: 60.01},
+					{Name: "1400x1050", Rates: []float64{59.98}},
+					{Name: "1600x900", Rates: []float64{60.00}},
+					{Name: "1280x1024", Rates: []float64{60.02}},
+					{Name: "1280x960", Rates: []float64{60.00}},
+					{Name: "1368x768", Rates: []float64{60.00}},
+					{Name: "1280x720", Rates: []float64{60.00}},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "1024x576", Rates: []float64{60.00}},
+					{Name: "960x540", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "864x486", Rates: []float64{60.00}},
+					{Name: "640x480", Rates: []float64{59.94}},
+					{Name: "720x405", Rates: []float64{60.00}},
+					{Name: "640x360", Rates: []float64{60.00}},
 				},
 				Connected: true,
 				Primary:   true,
@@ -391,55 +425,69 @@ VIRTUAL1 disconnected (normal left inverted right x axis y axis)`,
 			{
 				Name: "eDP1",
 				Modes: []Mode{
-					{Name: "1920x1080", Default: true, Active: true},
-					{Name: "1400x1050"},
-					{Name: "1600x900"},
-					{Name: "1280x1024"},
-					{Name: "1280x960"},
-					{Name: "1368x768"},
-					{Name: "1280x720"},
-					{Name: "1024x768"},
-					{Name: "1024x576"},
-					{Name: "960x540"},
-					{Name: "800x600"},
-					{Name: "864x486"},
-					{Name: "640x480"},
-					{Name: "720x405"},
-					{Name: "640x360"},
+					{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.01}, ActiveRate: 60.01, DefaultRate: 60.01},
+					{Name: "1400x1050", Rates: []float64{59.98}},
+					{Name: "1600x900", Rates: []float64{60.00}},
+					{Name: "1280x1024", Rates: []float64{60.02}},
+					{Name: "1280x960", Rates: []float64{60.00}},
+					{Name: "1368x768", Rates: []float64{60.00}},
+					{Name: "1280x720", Rates: []float64{60.00}},
+					{Name: "1024x768", Rates: []float64{60.00}},
+					{Name: "1024x576", Rates: []float64{60.00}},
+					{Name: "960x540", Rates: []float64{60.00}},
+					{Name: "800x600", Rates: []float64{60.32, 56.25}},
+					{Name: "864x486", Rates: []float64{60.00}},
+					{Name: "640x480", Rates: []float64{59.94}},
+					{Name: "720x405", Rates: []float64{60.00}},
+					{Name: "640x360", Rates: []float64{60.00}},
 				},
 				Connected: true,
 				Primary:   true,
 				MonitorID: "CMN-5297-0--",
+				Properties: Properties{
+					"BACKLIGHT":     {Value: "332", Range: &PropertyRange{0, 852}},
+					"Backlight":     {Value: "332", Range: &PropertyRange{0, 852}},
+					"scaling mode":  {Value: "Full aspect", Supported: []string{"None", "Full", "Center", "Full aspect"}},
+					"Broadcast RGB": testBroadcastRGB,
+					"audio":         testAudio,
+				},
 			},
-			{Name: "DP1"},
-			{Name: "DP2"},
-			{Name: "HDMI1"},
+			{Name: "DP1", Properties: Properties{"Broadcast RGB": testBroadcastRGB, "audio": testAudio}},
+			{Name: "DP2", Properties: Properties{"Broadcast RGB": testBroadcastRGB, "audio": testAudio}},
+			{Name: "HDMI1", Properties: Properties{"aspect ratio": testAspectRatio, "Broadcast RGB": testBroadcastRGB, "audio": testAudio}},
 			{Name: "HDMI2",
 				Modes: []Mode{
-					{Name: "1920x1080", Default: true, Active: true},
-					{Name: "1680x1050"},
-					{Name: "1600x900"},
-					{Name: "1280x1024"},
-					{Name: "1440x900"},
-					{Name: "1280x800"},
-					{Name: "1152x864"},
-					{Name: "1280x720"},
-					{Name: "1024x768"},
-					{Name: "832x624"},
-					{Name: "800x600"},
-					{Name: "720x576"},
-					{Name: "720x480"},
-					{Name: "640x480"},
-					{Name: "720x400"},
+					{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60.00, 50.00, 59.94}, ActiveRate: 60.00, DefaultRate: 60.00},
+					{Name: "1680x1050", Rates: []float64{59.88}},
+					{Name: "1600x900", Rates: []float64{60.00}},
+					{Name: "1280x1024", Rates: []float64{75.02, 60.02}},
+					{Name: "1440x900", Rates: []float64{59.90}},
+					{Name: "1280x800", Rates: []float64{59.91}},
+					{Name: "1152x864", Rates: []float64{75.00}},
+					{Name: "1280x720", Rates: []float64{60.00, 50.00, 59.94}},
+					{Name: "1024x768", Rates: []float64{75.03, 70.07, 60.00}},
+					{Name: "832x624", Rates: []float64{74.55}},
+					{Name: "800x600", Rates: []float64{72.19, 75.00, 60.32, 56.25}},
+					{Name: "720x576", Rates: []float64{50.00}},
+					{Name: "720x480", Rates: []float64{60.00, 59.94}},
+					{Name: "640x480", Rates: []float64{75.00, 72.81, 66.67, 60.00, 59.94}},
+					{Name: "720x400", Rates: []float64{70.08}},
 				},
-				Connected: true,
-				MonitorID: "SAM-2618-808661557-S24C350-",
+				Connected:  true,
+				MonitorID:  "SAM-2618-808661557-S24C350-",
+				Properties: Properties{"aspect ratio": testAspectRatio, "Broadcast RGB": testBroadcastRGB, "audio": testAudio},
 			},
 			{Name: "VIRTUAL1"},
 		},
 	},
 }
 
+var (
+	testBroadcastRGB = Property{Value: "Automatic", Supported: []string{"Automatic", "Full", "Limited 16:235"}}
+	testAudio        = Property{Value: "auto", Supported: []string{"force-dvi", "off", "auto", "on"}}
+	testAspectRatio  = Property{Value: "Automatic", Supported: []string{"Automatic", "4:3", "16:9"}}
+)
+
 func TestRandrParse(t *testing.T) {
 	for ti, test := range randrTestOutputs {
 		out, err := RandrParse(bytes.NewReader([]byte(test.str)))
@@ -486,10 +534,64 @@ func TestRandrParse(t *testing.T) {
 					ti, i,
 					out1.MonitorID, out2.MonitorID)
 			}
+
+			if out1.Screen != out2.Screen {
+				t.Errorf("test %d, output %d: screen not equal: want %v, got %v",
+					ti, i,
+					out1.Screen, out2.Screen)
+			}
+
+			if !reflect.DeepEqual(out1.Properties, out2.Properties) {
+				t.Errorf("test %d, output %d: properties not equal:\n  want %v\n  got  %v",
+					ti, i,
+					out1.Properties, out2.Properties)
+			}
 		}
 	}
 }
 
+func TestRandrParseProperties(t *testing.T) {
+	const str = `Screen 0: minimum 320 x 200, current 3840 x 2160, maximum 16384 x 16384
+DP-1-1 connected 3840x2160+0+0 (normal left inverted right x axis y axis) 600mm x 340mm
+	GUID: 
+		8b36a7a11b8843d1b5d7d1c5e21f6ea6
+	non-desktop: 0 
+		range: (0, 1)
+	link-status: Good 
+		supported: Good, Bad
+	CONNECTOR_ID: 95 
+		supported: 95
+	PATH: mst:93-1
+	TILE: 1 1 2 1 0 0 1920 2160 
+	max bpc: 12 
+		range: (8, 12)
+   1920x2160     60.00*+
+`
+
+	outputs, err := RandrParse(bytes.NewReader([]byte(str)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outputs) != 1 {
+		t.Fatalf("wrong number of outputs returned, want 1, got %d", len(outputs))
+	}
+
+	want := Properties{
+		"GUID":         {Value: "8b36a7a11b8843d1b5d7d1c5e21f6ea6"},
+		"non-desktop":  {Value: "0", Range: &PropertyRange{0, 1}},
+		"link-status":  {Value: "Good", Supported: []string{"Good", "Bad"}},
+		"CONNECTOR_ID": {Value: "95", Supported: []string{"95"}},
+		"PATH":         {Value: "mst:93-1"},
+		"TILE":         {Value: "1 1 2 1 0 0 1920 2160"},
+		"max bpc":      {Value: "12", Range: &PropertyRange{8, 12}},
+	}
+
+	if !reflect.DeepEqual(outputs[0].Properties, want) {
+		t.Fatalf("wrong properties returned:\n  want %v\n  got  %v", want, outputs[0].Properties)
+	}
+}
+
 var TestOutputLines = []struct {
 	line   string
 	output Output
@@ -508,11 +610,20 @@ var TestOutputLines = []struct {
 			Connected: false,
 		},
 	},
+	{
+		"VIRTUAL1 unknown connection (normal left inverted right x axis y axis)",
+		Output{
+			Name:    "VIRTUAL1",
+			Unknown: true,
+		},
+	},
 	{
 		"HDMI3 disconnected 1680x1050+1600+0 (normal left inverted right x axis y axis) 0mm x 0mm`",
 		Output{
-			Name:  "HDMI3",
-			Modes: []Mode{{Name: "1680x1050", Active: true}},
+			Name:     "HDMI3",
+			Modes:    []Mode{{Name: "1680x1050", Active: true}},
+			Geometry: Geometry{X: 1600, Width: 1680, Height: 1050},
+			Rotation: "normal",
 		},
 	},
 	{
@@ -521,6 +632,46 @@ var TestOutputLines = []struct {
 			Name:      "DP3-1-8",
 			Connected: true,
 			Primary:   true,
+			Geometry:  Geometry{Width: 2560, Height: 1440},
+			Rotation:  "normal",
+			WidthMM:   553,
+			HeightMM:  311,
+		},
+	},
+	{
+		"HDMI-1 connected 1080x1920+2560+0 left (normal left inverted right x axis y axis) 527mm x 296mm",
+		Output{
+			Name:      "HDMI-1",
+			Connected: true,
+			Geometry:  Geometry{X: 2560, Width: 1080, Height: 1920},
+			Rotation:  "left",
+			WidthMM:   527,
+			HeightMM:  296,
+		},
+	},
+	{
+		"eDP-1 connected primary 1920x1080+0+1440 inverted X and Y axis (normal left inverted right x axis y axis) 309mm x 174mm",
+		Output{
+			Name:       "eDP-1",
+			Connected:  true,
+			Primary:    true,
+			Geometry:   Geometry{Y: 1440, Width: 1920, Height: 1080},
+			Rotation:   "inverted",
+			Reflection: "X and Y axis",
+			WidthMM:    309,
+			HeightMM:   174,
+		},
+	},
+	{
+		"DP-2 connected 1920x1200+0+0 Y axis (normal left inverted right x axis y axis) 518mm x 324mm",
+		Output{
+			Name:       "DP-2",
+			Connected:  true,
+			Geometry:   Geometry{Width: 1920, Height: 1200},
+			Rotation:   "normal",
+			Reflection: "Y axis",
+			WidthMM:    518,
+			HeightMM:   324,
 		},
 	},
 }
@@ -548,34 +699,62 @@ var TestModeLines = []struct {
 	{
 		"  1152x864      75.00",
 		Mode{
-			Name: "1152x864",
+			Name:  "1152x864",
+			Rates: []float64{75.00},
 		},
 	},
 	{
 		"  1024x768      75.08    70.07    60.00",
 		Mode{
-			Name: "1024x768",
+			Name:  "1024x768",
+			Rates: []float64{75.08, 70.07, 60.00},
 		},
 	},
 	{
 		"  1600x1200     60.00*+",
 		Mode{
-			Name:    "1600x1200",
-			Active:  true,
-			Default: true,
+			Name:        "1600x1200",
+			Active:      true,
+			Default:     true,
+			Rates:       []float64{60.00},
+			ActiveRate:  60.00,
+			DefaultRate: 60.00,
 		},
 	},
 	{
 		"  1366x768      60.10 +",
 		Mode{
-			Name:    "1366x768",
-			Default: true,
+			Name:        "1366x768",
+			Default:     true,
+			Rates:       []float64{60.10},
+			DefaultRate: 60.10,
 		},
 	},
 	{
 		"  832x624       74.55",
 		Mode{
-			Name: "832x624",
+			Name:  "832x624",
+			Rates: []float64{74.55},
+		},
+	},
+	{
+		"  2560x1440     59.95 +  143.91*  120.00    99.95",
+		Mode{
+			Name:        "2560x1440",
+			Active:      true,
+			Default:     true,
+			Rates:       []float64{59.95, 143.91, 120.00, 99.95},
+			ActiveRate:  143.91,
+			DefaultRate: 59.95,
+		},
+	},
+	{
+		"  1920x1080     60.00    50.00    59.94*   30.00    25.00    24.00    29.97    23.98",
+		Mode{
+			Name:       "1920x1080",
+			Active:     true,
+			Rates:      []float64{60.00, 50.00, 59.94, 30.00, 25.00, 24.00, 29.97, 23.98},
+			ActiveRate: 59.94,
 		},
 	},
 }
@@ -589,12 +768,36 @@ func TestParseModeLine(t *testing.T) {
 		}
 
 		if !reflect.DeepEqual(mode, test.mode) {
-			t.Errorf("test %d failed: expected Mode not found", i)
+			t.Errorf("test %d failed: expected Mode not found, want %+v, got %+v", i, test.mode, mode)
 			continue
 		}
 	}
 }
 
+func TestParseModeLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"   1920x1080",
+		"   1920x1080     NaN",
+		"   1920x1080     60.00 +Inf",
+		"   1920x1080     -60.00*",
+		"   1920x1080     *",
+	} {
+		if _, err := parseModeLine(line); err == nil {
+			t.Errorf("expected error not returned for %q", line)
+		}
+	}
+}
+
+func TestGenerateMonitorIDTruncated(t *testing.T) {
+	// every prefix of a valid EDID must be rejected without panicking
+	edid := cmnEDIDHex
+	for i := 0; i < len(edid); i += 2 {
+		if _, err := GenerateMonitorID(edid[:i]); err == nil {
+			t.Fatalf("no error returned for EDID truncated to %d bytes", i/2)
+		}
+	}
+}
+
 func TestGenerateMonitorId(t *testing.T) {
 	var tests = []struct {
 		edid      string
@@ -652,3 +855,158 @@ func TestGenerateMonitorId(t *testing.T) {
 		})
 	}
 }
+
+// xrandrBrokenOutput contains an output with an unknown state, a corrupt
+// EDID, an invalid property range and an invalid mode line.
+const xrandrBrokenOutput = `Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 8192 x 8192
+eDP1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 309mm x 174mm
+	EDID:
+		00ffffffffffff00ffff
+		0000
+	non-desktop: 0
+		range: (0, x)
+   1920x1080     60.02*+
+   1024x768      sixty
+VIRTUAL1 unknown connection (normal left inverted right x axis y axis)
+DP1 flimsy (normal left inverted right x axis y axis)
+   1280x1024     60.02
+HDMI1 disconnected (normal left inverted right x axis y axis)
+`
+
+func TestRandrParseLenient(t *testing.T) {
+	_, _, err := RandrParseScreens(bytes.NewReader([]byte(xrandrBrokenOutput)))
+	if err == nil {
+		t.Fatal("strict parsing did not return an error")
+	}
+
+	screens, outputs, warnings, err := RandrParseLenient(bytes.NewReader([]byte(xrandrBrokenOutput)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(screens) != 1 {
+		t.Errorf("wrong number of screens, want 1, got %d", len(screens))
+	}
+
+	var lines []int
+	for _, w := range warnings {
+		lines = append(lines, w.Line)
+	}
+
+	wantLines := []int{6, 7, 9, 11}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("warnings for wrong lines returned, want %v, got %v (%v)", wantLines, lines, warnings)
+	}
+
+	var names []string
+	for _, output := range outputs {
+		names = append(names, output.Name)
+	}
+
+	wantNames := []string{"eDP1", "VIRTUAL1", "DP1", "HDMI1"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("wrong outputs returned, want %v, got %v", wantNames, names)
+	}
+
+	edp := outputs[0]
+	if !edp.Connected || len(edp.Modes) != 1 || edp.MonitorID != "" || len(edp.Warnings) != 3 {
+		t.Errorf("wrong output eDP1 returned: %+v", edp)
+	}
+
+	if _, ok := edp.Properties["non-desktop"]; !ok {
+		t.Errorf("property non-desktop of eDP1 not found: %v", edp.Properties)
+	}
+
+	if !outputs[1].Unknown || outputs[1].Connected || len(outputs[1].Warnings) != 0 {
+		t.Errorf("wrong output VIRTUAL1 returned: %+v", outputs[1])
+	}
+
+	if !outputs[2].Unknown || len(outputs[2].Modes) != 1 || len(outputs[2].Warnings) != 1 {
+		t.Errorf("wrong output DP1 returned: %+v", outputs[2])
+	}
+}
+
+func TestRandrParseLenientStart(t *testing.T) {
+	input := "garbage\n" + randrTestOutputs[0].str
+
+	_, outputs, warnings, err := RandrParseLenient(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 1 || warnings[0].Line != 1 || warnings[0].Output != "" {
+		t.Errorf("wrong warnings returned: %v", warnings)
+	}
+
+	if len(outputs) != len(randrTestOutputs[0].outputs) {
+		t.Errorf("wrong number of outputs, want %d, got %d", len(randrTestOutputs[0].outputs), len(outputs))
+	}
+}
+
+// xrandrOldProjector contains an output with an EDID 2.0, which cannot be
+// decoded.
+const xrandrOldProjector = `Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 16384 x 16384
+eDP1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 309mm x 174mm
+	EDID:
+		00ffffffffffff000daeb11400000000
+		0c190204951f117802ff359255529529
+		25505400000001010101010101010101
+		010101010101b43b804a71383440503c
+		680034ad10000018000000fe004e3134
+		304843452d4541410a20000000fe0043
+		4d4e0a202020202020202020000000fe
+		004e3134304843452d4541410a2000a1
+   1920x1080     60.02*+
+HDMI1 connected (normal left inverted right x axis y axis)
+	EDID:
+		00ffffffffffff000daeb11400000000
+		0c190104951f117802ff359255529529
+		25505400000001010101010101010101
+		010101010101b43b804a71383440503c
+		680034ad10000018000000fe004e3134
+		304843452d4541410a20000000fe0043
+		4d4e0a202020202020202020000000fe
+		004e3134304843452d4541410a2000a2
+   1920x1080     60.02 +
+`
+
+func TestRandrParseUnknownEDID(t *testing.T) {
+	outputs, err := RandrParse(bytes.NewReader([]byte(xrandrOldProjector)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outputs) != 2 {
+		t.Fatalf("wrong number of outputs, want 2, got %d", len(outputs))
+	}
+
+	if outputs[0].MonitorID != "" || outputs[0].EDID != nil {
+		t.Errorf("monitor ID for undecodable EDID returned: %q", outputs[0].MonitorID)
+	}
+
+	if len(outputs[0].Warnings) != 1 {
+		t.Errorf("wrong warnings returned: %v", outputs[0].Warnings)
+	}
+
+	if outputs[1].MonitorID != "CMN-5297-0--" {
+		t.Errorf("wrong monitor ID for second output: %q", outputs[1].MonitorID)
+	}
+}
+
+func TestRandrParseEDIDChecksumMismatch(t *testing.T) {
+	// the EDID of the second output with a wrong checksum
+	input := strings.Replace(xrandrOldProjector, "004e3134304843452d4541410a2000a2", "004e3134304843452d4541410a2000a3", 1)
+
+	outputs, err := RandrParse(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if outputs[1].MonitorID != "CMN-5297-0--" || outputs[1].EDID == nil {
+		t.Errorf("wrong monitor ID for EDID with checksum mismatch: %q", outputs[1].MonitorID)
+	}
+
+	if len(outputs[1].Warnings) != 1 || !errors.Is(outputs[1].Warnings[0].Err, errEDIDChecksum) {
+		t.Errorf("wrong warnings returned: %v", outputs[1].Warnings)
+	}
+}
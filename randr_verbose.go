@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OutputDetails holds information about an output which is only printed by
+// `xrandr --verbose`.
+type OutputDetails struct {
+	// ID is the RANDR ID of the output, ModeID the one of the active mode.
+	ID     uint32
+	ModeID uint32
+
+	Subpixel string
+
+	// Crtc is the index of the CRTC driving the output, it is -1 if the
+	// output is not active. Crtcs lists the CRTCs which can drive the
+	// output.
+	Crtc  int
+	Crtcs []int
+
+	// Clones lists the outputs which can show the same content.
+	Clones []string
+
+	// Gamma is the gamma correction for red, green and blue.
+	Gamma      [3]float64
+	Brightness float64
+
+	// Panning, Tracking and Border (left, top, right, bottom) are only set
+	// if the CRTC supports panning.
+	Panning  Geometry
+	Tracking Geometry
+	Border   [4]int
+
+	// Transform is the transformation matrix of the CRTC, Filter the filter
+	// used for scaling.
+	Transform [3][3]float64
+	Filter    string
+}
+
+// ModeTiming holds the timing of a mode as printed by `xrandr --verbose`.
+type ModeTiming struct {
+	ID uint32
+
+	// PixelClock is in MHz, Rate is the refresh rate in Hz.
+	PixelClock float64
+	Rate       float64
+
+	HDisplay, HSyncStart, HSyncEnd, HTotal, HSkew int
+	VDisplay, VSyncStart, VSyncEnd, VTotal        int
+
+	// Flags lists the mode flags, e.g. "+HSync" or "Interlace".
+	Flags []string
+}
+
+// details returns the details of the output, they are allocated on first
+// use.
+func (o *Output) details() *OutputDetails {
+	if o.Details == nil {
+		o.Details = &OutputDetails{Crtc: -1}
+	}
+	return o.Details
+}
+
+// parseModeID parses a mode ID like "(0x45)".
+func parseModeID(s string) (uint32, bool) {
+	if !strings.HasPrefix(s, "(0x") || !strings.HasSuffix(s, ")") {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(s[3:len(s)-1], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint32(id), true
+}
+
+// detailKeys are the names of the lines `xrandr --verbose` prints for each
+// output before the properties.
+var detailKeys = map[string]struct{}{
+	"Identifier": {},
+	"Timestamp":  {},
+	"Subpixel":   {},
+	"Gamma":      {},
+	"Brightness": {},
+	"Clones":     {},
+	"CRTC":       {},
+	"CRTCs":      {},
+	"Panning":    {},
+	"Tracking":   {},
+	"Border":     {},
+	"Transform":  {},
+}
+
+// splitDetailLine returns the name and value of a line like
+// "	CRTCs:      0 1 2". It returns false for other lines.
+func splitDetailLine(line string) (key, value string, ok bool) {
+	if !strings.HasPrefix(line, "	") || strings.HasPrefix(line, "		") {
+		return "", "", false
+	}
+
+	data := strings.SplitN(line[1:], ":", 2)
+	if len(data) != 2 {
+		return "", "", false
+	}
+
+	if _, ok := detailKeys[data[0]]; !ok {
+		return "", "", false
+	}
+
+	return data[0], strings.TrimSpace(data[1]), true
+}
+
+// parseInts parses a list of integers separated by sep.
+func parseInts(s, sep string) ([]int, error) {
+	var list []int
+	for _, field := range strings.Split(s, sep) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// parseTransformRow parses one row of the transformation matrix.
+func parseTransformRow(d *OutputDetails, row int, s string) error {
+	words := strings.Fields(s)
+	if row < 0 || row > 2 || len(words) != 3 {
+		return fmt.Errorf("invalid transform row %q", s)
+	}
+
+	for i, word := range words {
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return fmt.Errorf("invalid transform row %q", s)
+		}
+		d.Transform[row][i] = v
+	}
+
+	return nil
+}
+
+// parseDetail records the value of a line printed by `xrandr --verbose`.
+func parseDetail(d *OutputDetails, key, value string) error {
+	var err error
+	switch key {
+	case "Identifier":
+		var id uint64
+		id, err = strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+		d.ID = uint32(id)
+
+	case "Subpixel":
+		d.Subpixel = value
+
+	case "Gamma":
+		data := strings.Split(value, ":")
+		if len(data) != 3 {
+			return fmt.Errorf("invalid gamma %q", value)
+		}
+		for i, s := range data {
+			d.Gamma[i], err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				break
+			}
+		}
+
+	case "Brightness":
+		d.Brightness, err = strconv.ParseFloat(value, 64)
+
+	case "Clones":
+		if value != "" {
+			d.Clones = strings.Fields(value)
+		}
+
+	case "CRTC":
+		d.Crtc, err = strconv.Atoi(value)
+
+	case "CRTCs":
+		d.Crtcs, err = parseInts(value, " ")
+
+	case "Panning", "Tracking":
+		g, ok := parseGeometry(value)
+		if !ok {
+			return fmt.Errorf("invalid geometry %q", value)
+		}
+		if key == "Panning" {
+			d.Panning = g
+		} else {
+			d.Tracking = g
+		}
+
+	case "Border":
+		var list []int
+		list, err = parseInts(value, "/")
+		if err == nil && len(list) != 4 {
+			return fmt.Errorf("invalid border %q", value)
+		}
+		copy(d.Border[:], list)
+
+	case "Transform":
+		err = parseTransformRow(d, 0, value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %v %q: %w", key, value, err)
+	}
+
+	return nil
+}
+
+// parseTransformContinuation parses the lines following "Transform:", which
+// hold the remaining rows of the matrix and the filter.
+func parseTransformContinuation(d *OutputDetails, row int, line string) error {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "filter:") {
+		d.Filter = strings.TrimSpace(strings.TrimPrefix(line, "filter:"))
+		return nil
+	}
+
+	return parseTransformRow(d, row, line)
+}
+
+// parseVerboseModeLine parses a mode line printed by `xrandr --verbose` like
+// "  1920x1080 (0x45) 138.700MHz +HSync -VSync *current +preferred". It
+// returns errNotModeLine for other lines.
+func parseVerboseModeLine(line string) (name string, timing ModeTiming, current, preferred bool, err error) {
+	if !strings.HasPrefix(line, "  ") {
+		return "", ModeTiming{}, false, false, errNotModeLine
+	}
+
+	words := strings.Fields(line)
+	if len(words) < 3 {
+		return "", ModeTiming{}, false, false, errNotModeLine
+	}
+
+	id, ok := parseModeID(words[1])
+	if !ok {
+		return "", ModeTiming{}, false, false, errNotModeLine
+	}
+	timing.ID = id
+
+	timing.PixelClock, err = parseRate(strings.TrimSuffix(words[2], "MHz"))
+	if err != nil {
+		return "", ModeTiming{}, false, false, fmt.Errorf("invalid pixel clock %q: %s", words[2], line)
+	}
+
+	for _, word := range words[3:] {
+		switch word {
+		case "*current":
+			current = true
+		case "+preferred":
+			preferred = true
+		default:
+			timing.Flags = append(timing.Flags, word)
+		}
+	}
+
+	return words[0], timing, current, preferred, nil
+}
+
+// isTimingLine returns true if the line holds the horizontal or vertical
+// timing of a mode.
+func isTimingLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "h:") || strings.HasPrefix(line, "v:")
+}
+
+// parseTimingLine records the timing on a line like
+// "        v: height 1080 start 1083 end 1088 total 1111           clock  60.02Hz".
+// It returns true for the vertical timing, which is printed last.
+func parseTimingLine(t *ModeTiming, line string) (vertical bool, err error) {
+	words := strings.Fields(line)
+	if len(words) < 1 {
+		return false, fmt.Errorf("invalid timing line: %s", line)
+	}
+
+	vertical = words[0] == "v:"
+	words = words[1:]
+
+	for i := 0; i+1 < len(words); i += 2 {
+		key, value := words[i], words[i+1]
+
+		if key == "clock" {
+			if vertical {
+				t.Rate, err = parseRate(strings.TrimSuffix(value, "Hz"))
+				if err != nil {
+					return false, fmt.Errorf("invalid clock %q: %s", value, line)
+				}
+			}
+			continue
+		}
+
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid value %q for %v: %s", value, key, line)
+		}
+
+		var field *int
+		switch {
+		case key == "width" && !vertical:
+			field = &t.HDisplay
+		case key == "height" && vertical:
+			field = &t.VDisplay
+		case key == "start" && !vertical:
+			field = &t.HSyncStart
+		case key == "start" && vertical:
+			field = &t.VSyncStart
+		case key == "end" && !vertical:
+			field = &t.HSyncEnd
+		case key == "end" && vertical:
+			field = &t.VSyncEnd
+		case key == "total" && !vertical:
+			field = &t.HTotal
+		case key == "total" && vertical:
+			field = &t.VTotal
+		case key == "skew" && !vertical:
+			field = &t.HSkew
+		default:
+			return false, fmt.Errorf("unknown timing %q: %s", key, line)
+		}
+		*field = v
+	}
+
+	return vertical, nil
+}
+
+// addVerboseMode adds the timing of a mode to the output. Modes sharing a
+// name are collapsed into one like for the short output of xrandr. It
+// returns the index of the mode.
+func (o *Output) addVerboseMode(name string, timing ModeTiming) int {
+	for i := range o.Modes {
+		if o.Modes[i].Name == name {
+			o.Modes[i].Timings = append(o.Modes[i].Timings, timing)
+			return i
+		}
+	}
+
+	o.Modes = append(o.Modes, Mode{Name: name, Timings: []ModeTiming{timing}})
+	return len(o.Modes) - 1
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// xrandrVerboseOutput was recorded from `xrandr --verbose` on an Intel
+// laptop, shortened to the relevant parts.
+const xrandrVerboseOutput = `Screen 0: minimum 320 x 200, current 3840 x 1080, maximum 16384 x 16384
+eDP-1 connected primary 1920x1080+0+0 (0x45) normal (normal left inverted right x axis y axis) 309mm x 174mm
+	Identifier: 0x42
+	Timestamp:  21216
+	Subpixel:   unknown
+	Gamma:      1.0:1.0:1.0
+	Brightness: 1.0
+	Clones:
+	CRTC:       0
+	CRTCs:      0 1 2
+	Transform:  1.000000 0.000000 0.000000
+	            0.000000 1.000000 0.000000
+	            0.000000 0.000000 1.000000
+	           filter:
+	EDID:
+		00ffffffffffff000daeb11400000000
+		0c190104951f117802ff359255529529
+		25505400000001010101010101010101
+		010101010101b43b804a71383440503c
+		680034ad10000018000000fe004e3134
+		304843452d4541410a20000000fe0043
+		4d4e0a202020202020202020000000fe
+		004e3134304843452d4541410a2000a2
+	link-status: Good
+		supported: Good, Bad
+	non-desktop: 0
+		range: (0, 1)
+  1920x1080 (0x45) 138.700MHz +HSync -VSync *current +preferred
+        h: width  1920 start 1968 end 2000 total 2080 skew    0 clock  66.68KHz
+        v: height 1080 start 1083 end 1088 total 1111           clock  60.02Hz
+  1920x1080 (0x46) 110.960MHz +HSync -VSync
+        h: width  1920 start 1968 end 2000 total 2080 skew    0 clock  53.35KHz
+        v: height 1080 start 1083 end 1088 total 1111           clock  48.02Hz
+  1280x720 (0x47) 74.500MHz -HSync +VSync
+        h: width  1280 start 1344 end 1472 total 1664 skew    0 clock  44.77KHz
+        v: height  720 start  723 end  728 total  748           clock  59.86Hz
+HDMI-1 connected 1920x1080+1920+0 (0x48) left X axis (normal left inverted right x axis y axis) 531mm x 299mm
+	Identifier: 0x43
+	Timestamp:  21216
+	Subpixel:   unknown
+	Gamma:      1.0:0.91:0.83
+	Brightness: 0.80
+	Clones:     DP-1
+	CRTC:       1
+	CRTCs:      1 2
+	Panning:    1920x1080+1920+0
+	Tracking:   1920x1080+1920+0
+	Border:     0/0/0/0
+	Transform:  1.500000 0.000000 0.000000
+	            0.000000 1.500000 0.000000
+	            0.000000 0.000000 1.000000
+	           filter: bilinear
+  1920x1080 (0x48) 148.500MHz +HSync +VSync *current
+        h: width  1920 start 2008 end 2052 total 2200 skew    0 clock  67.50KHz
+        v: height 1080 start 1084 end 1089 total 1125           clock  60.00Hz
+  1920x1080i (0x49) 74.250MHz +HSync +VSync Interlace +preferred
+        h: width  1920 start 2008 end 2052 total 2200 skew    0 clock  33.75KHz
+        v: height 1080 start 1084 end 1094 total 1125           clock  60.00Hz
+  1920x1080 (0x4a) 148.500MHz +HSync +VSync
+        h: width  1920 start 2448 end 2492 total 2640 skew    0 clock  56.25KHz
+        v: height 1080 start 1084 end 1089 total 1125           clock  50.00Hz
+DP-1 disconnected (normal left inverted right x axis y axis)
+	Identifier: 0x44
+	Timestamp:  21216
+	Subpixel:   unknown
+	Clones:     HDMI-1
+	CRTCs:      0 1 2
+	Transform:  1.000000 0.000000 0.000000
+	            0.000000 1.000000 0.000000
+	            0.000000 0.000000 1.000000
+	           filter:
+	link-status: Good
+		supported: Good, Bad
+`
+
+var identity = [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+func TestRandrParseVerbose(t *testing.T) {
+	outputs, err := RandrParse(bytes.NewReader([]byte(xrandrVerboseOutput)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linkStatus := Property{Value: "Good", Supported: []string{"Good", "Bad"}}
+
+	want := Outputs{
+		{
+			Name:      "eDP-1",
+			Connected: true,
+			Primary:   true,
+			MonitorID: "CMN-5297-0--",
+			Vendor:    "CMN",
+			EDID:      cmnEDID,
+			Geometry:  Geometry{Width: 1920, Height: 1080},
+			Rotation:  "normal",
+			WidthMM:   309,
+			HeightMM:  174,
+			Modes: Modes{
+				{
+					Name: "1920x1080", Active: true, Default: true,
+					Rates: []float64{60.02, 48.02}, ActiveRate: 60.02, DefaultRate: 60.02,
+					Timings: []ModeTiming{
+						{
+							ID: 0x45, PixelClock: 138.7, Rate: 60.02,
+							HDisplay: 1920, HSyncStart: 1968, HSyncEnd: 2000, HTotal: 2080,
+							VDisplay: 1080, VSyncStart: 1083, VSyncEnd: 1088, VTotal: 1111,
+							Flags: []string{"+HSync", "-VSync"},
+						},
+						{
+							ID: 0x46, PixelClock: 110.96, Rate: 48.02,
+							HDisplay: 1920, HSyncStart: 1968, HSyncEnd: 2000, HTotal: 2080,
+							VDisplay: 1080, VSyncStart: 1083, VSyncEnd: 1088, VTotal: 1111,
+							Flags: []string{"+HSync", "-VSync"},
+						},
+					},
+				},
+				{
+					Name: "1280x720", Rates: []float64{59.86},
+					Timings: []ModeTiming{
+						{
+							ID: 0x47, PixelClock: 74.5, Rate: 59.86,
+							HDisplay: 1280, HSyncStart: 1344, HSyncEnd: 1472, HTotal: 1664,
+							VDisplay: 720, VSyncStart: 723, VSyncEnd: 728, VTotal: 748,
+							Flags: []string{"-HSync", "+VSync"},
+						},
+					},
+				},
+			},
+			Properties: Properties{
+				"link-status": linkStatus,
+				"non-desktop": {Value: "0", Range: &PropertyRange{0, 1}},
+			},
+			Details: &OutputDetails{
+				ID:         0x42,
+				ModeID:     0x45,
+				Subpixel:   "unknown",
+				Crtc:       0,
+				Crtcs:      []int{0, 1, 2},
+				Gamma:      [3]float64{1, 1, 1},
+				Brightness: 1,
+				Transform:  identity,
+			},
+		},
+		{
+			Name:       "HDMI-1",
+			Connected:  true,
+			Geometry:   Geometry{X: 1920, Width: 1920, Height: 1080},
+			Rotation:   "left",
+			Reflection: "X axis",
+			WidthMM:    531,
+			HeightMM:   299,
+			Modes: Modes{
+				{
+					Name: "1920x1080", Active: true,
+					Rates: []float64{60, 50}, ActiveRate: 60,
+					Timings: []ModeTiming{
+						{
+							ID: 0x48, PixelClock: 148.5, Rate: 60,
+							HDisplay: 1920, HSyncStart: 2008, HSyncEnd: 2052, HTotal: 2200,
+							VDisplay: 1080, VSyncStart: 1084, VSyncEnd: 1089, VTotal: 1125,
+							Flags: []string{"+HSync", "+VSync"},
+						},
+						{
+							ID: 0x4a, PixelClock: 148.5, Rate: 50,
+							HDisplay: 1920, HSyncStart: 2448, HSyncEnd: 2492, HTotal: 2640,
+							VDisplay: 1080, VSyncStart: 1084, VSyncEnd: 1089, VTotal: 1125,
+							Flags: []string{"+HSync", "+VSync"},
+						},
+					},
+				},
+				{
+					Name: "1920x1080i", Default: true,
+					Rates: []float64{60}, DefaultRate: 60,
+					Timings: []ModeTiming{
+						{
+							ID: 0x49, PixelClock: 74.25, Rate: 60,
+							HDisplay: 1920, HSyncStart: 2008, HSyncEnd: 2052, HTotal: 2200,
+							VDisplay: 1080, VSyncStart: 1084, VSyncEnd: 1094, VTotal: 1125,
+							Flags: []string{"+HSync", "+VSync", "Interlace"},
+						},
+					},
+				},
+			},
+			Details: &OutputDetails{
+				ID:         0x43,
+				ModeID:     0x48,
+				Subpixel:   "unknown",
+				Crtc:       1,
+				Crtcs:      []int{1, 2},
+				Clones:     []string{"DP-1"},
+				Gamma:      [3]float64{1, 0.91, 0.83},
+				Brightness: 0.8,
+				Panning:    Geometry{X: 1920, Width: 1920, Height: 1080},
+				Tracking:   Geometry{X: 1920, Width: 1920, Height: 1080},
+				Transform:  [3][3]float64{{1.5, 0, 0}, {0, 1.5, 0}, {0, 0, 1}},
+				Filter:     "bilinear",
+			},
+		},
+		{
+			Name: "DP-1",
+			Properties: Properties{
+				"link-status": linkStatus,
+			},
+			Details: &OutputDetails{
+				ID:        0x44,
+				Subpixel:  "unknown",
+				Crtc:      -1,
+				Crtcs:     []int{0, 1, 2},
+				Clones:    []string{"HDMI-1"},
+				Transform: identity,
+			},
+		},
+	}
+
+	if len(outputs) != len(want) {
+		t.Fatalf("wrong number of outputs returned, want %d, got %d", len(want), len(outputs))
+	}
+
+	for i := range want {
+		if !reflect.DeepEqual(outputs[i], want[i]) {
+			t.Errorf("output %d wrong:\n  want %#v\n  got  %#v", i, want[i], outputs[i])
+		}
+	}
+}
+
+func TestParseTimingLineErrors(t *testing.T) {
+	var tests = []string{
+		"        h: width  1920 start 1968 end 2000 total 2080 skew    x clock  66.68KHz",
+		"        v: height 1080 start 1083 end 1088 total 1111           clock  fastHz",
+		"        v: height 1080 skew 1",
+	}
+
+	for _, line := range tests {
+		t.Run("", func(t *testing.T) {
+			var timing ModeTiming
+			_, err := parseTimingLine(&timing, line)
+			if err == nil {
+				t.Fatalf("expected error not returned for %q", line)
+			}
+		})
+	}
+}
@@ -9,6 +9,23 @@ type Rule struct {
 	OutputsPresent      []string `yaml:"outputs_present"`
 	OutputsAbsent       []string `yaml:"outputs_absent"`
 
+	// OutputsProperties maps output names to the properties the output must
+	// have, values are shell patterns.
+	OutputsProperties map[string]map[string]string `yaml:"outputs_properties"`
+
+	// OutputsVendor maps output names to a shell pattern for the vendor of
+	// the monitor, which matches either the PNP ID or the name.
+	OutputsVendor map[string]string `yaml:"outputs_vendor"`
+
+	// OutputsAudio lists outputs which must be connected to a monitor that
+	// can play audio according to its EDID, e.g. a TV.
+	OutputsAudio []string `yaml:"outputs_audio"`
+
+	// Screen limits the rule to the outputs of one X screen, all screens
+	// are considered if it is not set. Rules are matched separately for
+	// each screen, see MatchRules.
+	Screen *int `yaml:"screen"`
+
 	ConfigureRow     []string `yaml:"configure_row"`
 	ConfigureColumn  []string `yaml:"configure_column"`
 	ConfigureSingle  string   `yaml:"configure_single"`
@@ -16,6 +33,10 @@ type Rule struct {
 
 	Primary string `yaml:"primary"`
 
+	// SplitOutputs maps output names to the number of virtual monitors of
+	// equal width the output is split into.
+	SplitOutputs map[string]int `yaml:"split_outputs"`
+
 	DisableOrder []string `yaml:"disable_order"`
 
 	Atomic bool `yaml:"atomic"`
@@ -25,6 +46,15 @@ type Rule struct {
 
 // Match returns true iff the rule matches for the given list of outputs.
 func (r Rule) Match(outputs Outputs) bool {
+	if r.Screen != nil {
+		outputs = outputs.OnScreen(*r.Screen)
+
+		// the rule cannot match on a screen which does not exist
+		if len(outputs) == 0 {
+			return false
+		}
+	}
+
 	for _, name := range r.OutputsAbsent {
 		if outputs.Present(name) {
 			return false
@@ -49,5 +79,75 @@ func (r Rule) Match(outputs Outputs) bool {
 		}
 	}
 
+	for name, props := range r.OutputsProperties {
+		if !outputs.HaveProperties(name, props) {
+			return false
+		}
+	}
+
+	for name, vendor := range r.OutputsVendor {
+		if !outputs.HaveVendor(name, vendor) {
+			return false
+		}
+	}
+
+	for _, name := range r.OutputsAudio {
+		if !outputs.HaveAudio(name) {
+			return false
+		}
+	}
+
 	return true
 }
+
+// screen returns the X screen the rule configures: the one set for the rule,
+// or else the screen of the first output the rule enables. An output layout
+// cannot span several screens.
+func (r Rule) screen(outputs Outputs) (int, bool) {
+	if r.Screen != nil {
+		return *r.Screen, true
+	}
+
+	names, _, err := ruleOutputs(r)
+	if err != nil {
+		return 0, false
+	}
+
+	name, _, _, err := splitOutputMode(names[0])
+	if err != nil {
+		return 0, false
+	}
+
+	output, ok := findOutput(outputs, name)
+	if !ok {
+		return 0, false
+	}
+
+	return output.Screen, true
+}
+
+// ScreenIndex returns the index of the X screen the rule is matched for. Rules
+// which do not name an output on any screen belong to the screen of the first
+// output.
+func (r Rule) ScreenIndex(outputs Outputs) int {
+	if screen, ok := r.screen(outputs); ok {
+		return screen
+	}
+
+	if len(outputs) > 0 {
+		return outputs[0].Screen
+	}
+
+	return 0
+}
+
+// ScreenOutputs returns the outputs of the X screen the rule configures, or
+// all outputs if the rule does not name an output.
+func (r Rule) ScreenOutputs(outputs Outputs) Outputs {
+	screen, ok := r.screen(outputs)
+	if !ok {
+		return outputs
+	}
+
+	return outputs.OnScreen(screen)
+}
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 var testRules = []struct {
 	rule  Rule
@@ -71,6 +74,91 @@ var testRules = []struct {
 		},
 		true,
 	},
+	{
+		Rule{
+			OutputsProperties: map[string]map[string]string{
+				"HDMI": {"link-status": "Good"},
+			},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsProperties: map[string]map[string]string{
+				"HDMI": {"link-status": "Bad"},
+			},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsConnected: []string{"LVDS"},
+			OutputsProperties: map[string]map[string]string{
+				"HDMI*":  {"link-status": "Good", "Broadcast RGB": "Auto*"},
+				"*-HMD*": {"non-desktop": "1"},
+			},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsProperties: map[string]map[string]string{
+				"VGA": {"link-status": "Good"},
+			},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsVendor: map[string]string{
+				"HDMI": "Samsung*",
+				"LVDS": "CMN",
+			},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsVendor: map[string]string{
+				"DP3": "VLV",
+			},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsVendor: map[string]string{
+				"HDMI": "Dell*",
+			},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsVendor: map[string]string{
+				"VGA": "*",
+			},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsAudio: []string{"HDMI"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsAudio: []string{"LVDS"},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsAudio: []string{"VGA"},
+		},
+		false,
+	},
 }
 
 var testOutputs = []Output{
@@ -78,31 +166,145 @@ var testOutputs = []Output{
 		Name:      "LVDS",
 		Connected: true,
 		Modes: []Mode{
-			{"1377x768", true, true},
-			{"1024x768", false, false},
+			{Name: "1377x768", Default: true, Active: true},
+			{Name: "1024x768"},
 		},
 		MonitorID: "CMN-5297-0",
+		Vendor:    "CMN",
+		EDID:      &EDID{Vendor: "CMN"},
 	},
 	{
 		Name:      "VGA",
 		Connected: true,
 		Modes: []Mode{
-			{"1280x1024", true, false},
-			{"1024x768", false, true},
+			{Name: "1280x1024", Default: true},
+			{Name: "1024x768", Active: true},
 		},
 	},
 	{
 		Name:      "HDMI",
 		Connected: true,
 		Modes: []Mode{
-			{"1920x1080", true, true},
-			{"1024x768", false, false},
+			{Name: "1920x1080", Default: true, Active: true, Rates: []float64{60, 143.98, 50}, ActiveRate: 60, DefaultRate: 60},
+			{Name: "1024x768"},
 		},
 		MonitorID: "SAM-2618-808661557",
+		Vendor:    "SAM",
+		EDID:      &EDID{Vendor: "SAM", CEA: &CEA{BasicAudio: true}},
+		Properties: Properties{
+			"link-status":   {Value: "Good", Supported: []string{"Good", "Bad"}},
+			"Broadcast RGB": {Value: "Automatic", Supported: []string{"Automatic", "Full", "Limited 16:235"}},
+		},
 	},
 	{
 		Name: "DP2-1",
 	},
+	{
+		Name:      "DP3",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "2880x1600", Default: true},
+		},
+		MonitorID: "VLV-HMD-0",
+		Vendor:    "VLV",
+		Properties: Properties{
+			"non-desktop": {Value: "1", Range: &PropertyRange{0, 1}},
+		},
+	},
+}
+
+func TestRuleMatchScreen(t *testing.T) {
+	outputs := Outputs{
+		{Name: "DVI-0", Connected: true},
+		{Name: "DVI-1-0", Connected: true, Screen: 1},
+		{Name: "HDMI-1-0", Screen: 1},
+	}
+
+	screen0, screen1, screen2 := 0, 1, 2
+
+	var tests = []struct {
+		rule  Rule
+		match bool
+	}{
+		{Rule{OutputsAbsent: []string{"VGA-*"}, Screen: &screen2}, false},
+		{Rule{OutputsConnected: []string{"DVI-*"}}, true},
+		{Rule{OutputsConnected: []string{"DVI-1-0"}, Screen: &screen1}, true},
+		{Rule{OutputsConnected: []string{"DVI-1-0"}, Screen: &screen0}, false},
+		{Rule{OutputsDisconnected: []string{"DVI-0"}, Screen: &screen1}, true},
+		{Rule{OutputsAbsent: []string{"HDMI-1-0"}, Screen: &screen0}, true},
+	}
+
+	for i, test := range tests {
+		m := test.rule.Match(outputs)
+		if m != test.match {
+			t.Errorf("test rule %d wrong match: wanted %v, got %v", i, test.match, m)
+		}
+	}
+}
+
+func TestRuleScreenOutputs(t *testing.T) {
+	outputs := Outputs{
+		{Name: "DVI-0", Connected: true},
+		{Name: "VGA-0"},
+		{Name: "DVI-1-0", Connected: true, Screen: 1},
+		{Name: "HDMI-1-0", Screen: 1},
+	}
+
+	screen0 := 0
+
+	var tests = []struct {
+		rule  Rule
+		names []string
+	}{
+		{Rule{ConfigureSingle: "DVI-0"}, []string{"DVI-0", "VGA-0"}},
+		{Rule{ConfigureRow: []string{"DVI-1-0@1280x1024", "HDMI-1-0"}}, []string{"DVI-1-0", "HDMI-1-0"}},
+		{Rule{ConfigureCommand: "true", Screen: &screen0}, []string{"DVI-0", "VGA-0"}},
+		{Rule{ConfigureCommand: "true"}, []string{"DVI-0", "VGA-0", "DVI-1-0", "HDMI-1-0"}},
+	}
+
+	for i, test := range tests {
+		var names []string
+		for _, output := range test.rule.ScreenOutputs(outputs) {
+			names = append(names, output.Name)
+		}
+
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("test rule %d: wrong outputs, want %v, got %v", i, test.names, names)
+		}
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	outputs := Outputs{
+		{Name: "DVI-0", Connected: true},
+		{Name: "DVI-1-0", Connected: true, Screen: 1},
+		{Name: "HDMI-1-0", Screen: 1},
+	}
+
+	screen1, screen2 := 1, 2
+
+	rules := []Rule{
+		{Name: "missing", Screen: &screen2, ConfigureCommand: "true"},
+		{Name: "hdmi", OutputsConnected: []string{"HDMI-1-0"}, ConfigureSingle: "HDMI-1-0"},
+		{Name: "second", Screen: &screen1, OutputsConnected: []string{"DVI-1-0"}, ConfigureSingle: "DVI-1-0"},
+		{Name: "first", OutputsConnected: []string{"DVI-0"}, ConfigureSingle: "DVI-0"},
+		{Name: "fallback", ConfigureSingle: "DVI-1-0"},
+	}
+
+	matched, err := MatchRules(rules, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[int]string)
+	for screen, rule := range matched {
+		names[screen] = rule.Name
+	}
+
+	want := map[int]string{0: "first", 1: "second"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong rules matched, want %v, got %v", want, names)
+	}
 }
 
 func TestRuleMatch(t *testing.T) {
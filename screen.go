@@ -0,0 +1,87 @@
+package main
+
+import "fmt"
+
+// Size is the size of a screen in pixels.
+type Size struct {
+	Width, Height int
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// Screen holds the size limits of the framebuffer of an X screen, all
+// active outputs must fit into it.
+type Screen struct {
+	Index int
+
+	Minimum Size
+	Current Size
+	Maximum Size
+}
+
+// Screens is a list of screens.
+type Screens []Screen
+
+// Find returns the screen with the given index.
+func (ss Screens) Find(index int) (Screen, bool) {
+	for _, s := range ss {
+		if s.Index == index {
+			return s, true
+		}
+	}
+	return Screen{}, false
+}
+
+// Fits returns true if a framebuffer of the given size can be configured.
+func (s Screen) Fits(width, height int) bool {
+	return width <= s.Maximum.Width && height <= s.Maximum.Height
+}
+
+// ScreenBackend is implemented by backends which can report the size limits
+// of the screens.
+type ScreenBackend interface {
+	// Screens returns the list of screens.
+	Screens() (Screens, error)
+}
+
+// checkLayoutFits returns an error if the layout of rule needs a larger
+// screen than the backend supports. It is checked before any output is
+// changed, so that the configuration does not fail halfway. If the size
+// cannot be determined the check is skipped and the backend reports errors.
+func checkLayoutFits(backend Backend, rule Rule, current Outputs) error {
+	sb, ok := backend.(ScreenBackend)
+	if !ok {
+		return nil
+	}
+
+	layout, err := ComputeLayout(rule, current)
+	if err != nil {
+		V("rule %v: unable to compute the layout size: %v\n", rule.Name, err)
+		return nil
+	}
+
+	screens, err := sb.Screens()
+	if err != nil {
+		V("rule %v: unable to query the screen size: %v\n", rule.Name, err)
+		return nil
+	}
+
+	index := 0
+	if len(current) > 0 {
+		index = current[0].Screen
+	}
+
+	screen, ok := screens.Find(index)
+	if !ok {
+		return nil
+	}
+
+	if !screen.Fits(layout.Width, layout.Height) {
+		return fmt.Errorf("rule %v: layout needs a screen of %dx%d, but screen %d is limited to %v",
+			rule.Name, layout.Width, layout.Height, screen.Index, screen.Maximum)
+	}
+
+	return nil
+}
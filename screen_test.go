@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseScreenLine(t *testing.T) {
+	var tests = []struct {
+		line   string
+		screen Screen
+	}{
+		{
+			"Screen 0: minimum 320 x 200, current 3840 x 1080, maximum 16384 x 16384",
+			Screen{
+				Minimum: Size{320, 200},
+				Current: Size{3840, 1080},
+				Maximum: Size{16384, 16384},
+			},
+		},
+		{
+			"Screen 1: minimum 8 x 8, current 1280 x 1024, maximum 8192 x 8192",
+			Screen{
+				Index:   1,
+				Minimum: Size{8, 8},
+				Current: Size{1280, 1024},
+				Maximum: Size{8192, 8192},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			screen, err := parseScreenLine(test.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if screen != test.screen {
+				t.Fatalf("wrong screen returned, want %+v, got %+v", test.screen, screen)
+			}
+		})
+	}
+
+	for _, line := range []string{
+		"Screen 0:",
+		"Screen -1: minimum 320 x 200, current 3840 x 1080, maximum 16384 x 16384",
+		"Screen 0: minimum 320 x 200, current 3840 x 1080",
+	} {
+		if _, err := parseScreenLine(line); err == nil {
+			t.Errorf("expected error not returned for %q", line)
+		}
+	}
+}
+
+func TestRandrParseScreens(t *testing.T) {
+	for _, test := range randrTestOutputs {
+		screens, outputs, err := RandrParseScreens(bytes.NewReader([]byte(test.str)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, output := range outputs {
+			if _, ok := screens.Find(output.Screen); !ok {
+				t.Errorf("screen %d of output %v not found in %v", output.Screen, output.Name, screens)
+			}
+		}
+	}
+}
+
+// screenBackend reports fixed screen limits.
+type screenBackend struct {
+	xrandrBackend
+	screens Screens
+}
+
+func (b screenBackend) Screens() (Screens, error) {
+	return b.screens, nil
+}
+
+func TestCheckLayoutFits(t *testing.T) {
+	backend := screenBackend{
+		screens: Screens{{Maximum: Size{3300, 2048}}},
+	}
+
+	var tests = []struct {
+		rule Rule
+		fits bool
+	}{
+		{Rule{ConfigureSingle: "HDMI"}, true},
+		{Rule{ConfigureRow: []string{"HDMI", "LVDS"}}, true},
+		{Rule{ConfigureRow: []string{"HDMI", "LVDS", "VGA"}}, false},
+		{Rule{ConfigureColumn: []string{"HDMI", "LVDS"}}, true},
+		{Rule{ConfigureColumn: []string{"HDMI", "VGA"}}, false},
+		// the size of unknown outputs is not known, the check is skipped
+		{Rule{ConfigureRow: []string{"HDMI", "DP-9"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			err := checkLayoutFits(backend, test.rule, testOutputs)
+			if test.fits && err != nil {
+				t.Fatalf("layout does not fit: %v", err)
+			}
+
+			if !test.fits && err == nil {
+				t.Fatal("expected error not returned")
+			}
+		})
+	}
+}
+
+func TestScreensFind(t *testing.T) {
+	screens := Screens{{Index: 0}, {Index: 2, Maximum: Size{8192, 8192}}}
+
+	s, ok := screens.Find(2)
+	if !ok || !reflect.DeepEqual(s, screens[1]) {
+		t.Fatalf("wrong screen found: %v %v", s, ok)
+	}
+
+	if _, ok := screens.Find(1); ok {
+		t.Fatal("nonexistent screen found")
+	}
+}
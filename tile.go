@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tile is the position of an output in a tiled monitor (e.g. a 5K monitor
+// driven via two DisplayPort streams), parsed from the TILE property.
+type Tile struct {
+	Group int
+	Flags int
+
+	// Columns and Rows is the number of tiles of the monitor, Column and
+	// Row the location of this tile.
+	Columns, Rows int
+	Column, Row   int
+
+	// Width and Height is the size of this tile in pixels.
+	Width, Height int
+}
+
+// Tile returns the tile the output shows, it returns false if the output is
+// not part of a tiled monitor.
+func (o Output) Tile() (Tile, bool) {
+	prop, ok := o.Properties["TILE"]
+	if !ok {
+		return Tile{}, false
+	}
+
+	// xrandr separates the values by spaces or commas
+	fields := strings.FieldsFunc(prop.Value, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(fields) != 8 {
+		return Tile{}, false
+	}
+
+	var values [8]int
+	for i, field := range fields {
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return Tile{}, false
+		}
+		values[i] = v
+	}
+
+	t := Tile{
+		Group:   values[0],
+		Flags:   values[1],
+		Columns: values[2],
+		Rows:    values[3],
+		Column:  values[4],
+		Row:     values[5],
+		Width:   values[6],
+		Height:  values[7],
+	}
+
+	if t.Columns < 1 || t.Rows < 1 || t.Column >= t.Columns || t.Row >= t.Rows ||
+		t.Width <= 0 || t.Height <= 0 {
+		return Tile{}, false
+	}
+
+	return t, true
+}
+
+// tileOffset returns the position of the tile in the monitor.
+func tileOffset(tiles Outputs, tile Tile) (x, y int) {
+	for _, o := range tiles {
+		t, _ := o.Tile()
+		if t.Row == tile.Row && t.Column < tile.Column {
+			x += t.Width
+		}
+		if t.Column == tile.Column && t.Row < tile.Row {
+			y += t.Height
+		}
+	}
+	return x, y
+}
+
+// tileMode returns the name of the mode of a tile which belongs to the tiled
+// mode of the monitor.
+func tileMode(output Output, tile Tile) string {
+	for _, mode := range output.Modes {
+		w, h, err := ParseModeSize(mode.Name)
+		if err == nil && w == tile.Width && h == tile.Height {
+			return mode.Name
+		}
+	}
+	return fmt.Sprintf("%dx%d", tile.Width, tile.Height)
+}
+
+// mergeTileGroup returns the logical output for the tiles of a monitor,
+// sorted by row and column. The first mode of the logical output drives all
+// tiles, the other modes are the ones of the top left tile alone.
+func mergeTileGroup(tiles Outputs) Output {
+	main := tiles[0]
+
+	logical := main
+	logical.Tiles = tiles
+	logical.Modes = nil
+	logical.WidthMM, logical.HeightMM = 0, 0
+
+	var width, height int
+	active := true
+	for _, o := range tiles {
+		t, _ := o.Tile()
+		if t.Row == 0 {
+			width += t.Width
+			logical.WidthMM += o.WidthMM
+		}
+		if t.Column == 0 {
+			height += t.Height
+			logical.HeightMM += o.HeightMM
+		}
+
+		mode, ok := o.ActiveMode()
+		if !ok || mode.Name != tileMode(o, t) {
+			active = false
+		}
+	}
+
+	mainTile, _ := main.Tile()
+	mainMode := tileMode(main, mainTile)
+
+	tiled := Mode{Name: fmt.Sprintf("%dx%d", width, height), Default: true, Active: active}
+	if mode, ok := outputMode(main, mainMode); ok {
+		tiled.Rates = mode.Rates
+		tiled.DefaultRate = mode.DefaultRate
+		if active {
+			tiled.ActiveRate = mode.ActiveRate
+		}
+	}
+	logical.Modes = append(logical.Modes, tiled)
+
+	for _, mode := range main.Modes {
+		if mode.Name == mainMode {
+			continue
+		}
+		mode.Default = false
+		logical.Modes = append(logical.Modes, mode)
+	}
+
+	if active {
+		logical.Geometry.Width = width
+		logical.Geometry.Height = height
+	}
+
+	return logical
+}
+
+// MergeTiles returns the list of outputs with the tiles of each tiled
+// monitor replaced by one logical output, which is named after the top left
+// tile. Tiled monitors are only merged if all tiles are connected.
+func (os Outputs) MergeTiles() Outputs {
+	groups := make(map[int]Outputs)
+	for _, o := range os {
+		if !o.Connected {
+			continue
+		}
+
+		if t, ok := o.Tile(); ok {
+			groups[t.Group] = append(groups[t.Group], o)
+		}
+	}
+
+	merged := make(map[string]struct{})
+	logical := make(map[string]Output)
+	for _, tiles := range groups {
+		t, _ := tiles[0].Tile()
+		if len(tiles) != t.Columns*t.Rows || len(tiles) < 2 {
+			continue
+		}
+
+		sort.SliceStable(tiles, func(i, j int) bool {
+			ti, _ := tiles[i].Tile()
+			tj, _ := tiles[j].Tile()
+			if ti.Row != tj.Row {
+				return ti.Row < tj.Row
+			}
+			return ti.Column < tj.Column
+		})
+
+		for _, o := range tiles {
+			merged[o.Name] = struct{}{}
+		}
+
+		output := mergeTileGroup(tiles)
+		logical[output.Name] = output
+	}
+
+	if len(merged) == 0 {
+		return os
+	}
+
+	var list Outputs
+	for _, o := range os {
+		if output, ok := logical[o.Name]; ok {
+			list = append(list, output)
+			continue
+		}
+
+		if _, ok := merged[o.Name]; ok {
+			continue
+		}
+
+		list = append(list, o)
+	}
+
+	return list
+}
+
+// tileNames returns the names of the outputs showing output, which are the
+// tiles for a tiled monitor.
+func tileNames(output Output) []string {
+	if len(output.Tiles) == 0 {
+		return []string{output.Name}
+	}
+
+	var names []string
+	for _, tile := range output.Tiles {
+		names = append(names, tile.Name)
+	}
+	return names
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testTiles is a 5K monitor driven via two DisplayPort streams and a laptop
+// panel.
+var testTiles = Outputs{
+	{
+		Name:      "eDP-1",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "1920x1080", Default: true, Active: true},
+		},
+	},
+	{
+		Name:      "DP-2",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "2560x2880", Default: true, Rates: []float64{60, 30}, DefaultRate: 60},
+			{Name: "1920x1080"},
+		},
+		WidthMM:    300,
+		HeightMM:   340,
+		Properties: Properties{"TILE": {Value: "1 1 2 1 1 0 2560 2880"}},
+	},
+	{
+		Name:      "DP-1",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "2560x2880", Default: true, Rates: []float64{60, 30}, DefaultRate: 60},
+			{Name: "1920x1080"},
+			{Name: "1280x720"},
+		},
+		WidthMM:    300,
+		HeightMM:   340,
+		Properties: Properties{"TILE": {Value: "1 1 2 1 0 0 2560 2880"}},
+	},
+	{
+		Name:      "HDMI-1",
+		Connected: false,
+	},
+}
+
+func TestOutputTile(t *testing.T) {
+	var tests = []struct {
+		value string
+		tile  Tile
+		ok    bool
+	}{
+		{"1 1 2 1 1 0 2560 2880", Tile{1, 1, 2, 1, 1, 0, 2560, 2880}, true},
+		{"1, 1, 2, 1, 0, 0, 2560, 2880", Tile{1, 1, 2, 1, 0, 0, 2560, 2880}, true},
+		{"1 1 2 1 2 0 2560 2880", Tile{}, false},
+		{"1 1 2 1 0 0 2560", Tile{}, false},
+		{"1 1 2 1 0 0 2560 x", Tile{}, false},
+		{"1 1 2 1 0 0 0 2880", Tile{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			o := Output{Properties: Properties{"TILE": {Value: test.value}}}
+			tile, ok := o.Tile()
+			if ok != test.ok {
+				t.Fatalf("wrong result for %q, want %v, got %v", test.value, test.ok, ok)
+			}
+
+			if tile != test.tile {
+				t.Fatalf("wrong tile returned, want %+v, got %+v", test.tile, tile)
+			}
+		})
+	}
+
+	if _, ok := (Output{}).Tile(); ok {
+		t.Fatal("tile found for output without TILE property")
+	}
+}
+
+func TestMergeTiles(t *testing.T) {
+	outputs := testTiles.MergeTiles()
+
+	var names []string
+	for _, o := range outputs {
+		names = append(names, o.Name)
+	}
+
+	want := []string{"eDP-1", "DP-1", "HDMI-1"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wrong outputs returned, want %v, got %v", want, names)
+	}
+
+	logical := outputs[1]
+	if !reflect.DeepEqual(tileNames(logical), []string{"DP-1", "DP-2"}) {
+		t.Fatalf("wrong tiles returned: %v", tileNames(logical))
+	}
+
+	if logical.WidthMM != 600 || logical.HeightMM != 340 {
+		t.Errorf("wrong size %dmm x %dmm", logical.WidthMM, logical.HeightMM)
+	}
+
+	var modes []string
+	for _, mode := range logical.Modes {
+		modes = append(modes, mode.Name)
+	}
+
+	wantModes := []string{"5120x2880", "1920x1080", "1280x720"}
+	if !reflect.DeepEqual(modes, wantModes) {
+		t.Fatalf("wrong modes returned, want %v, got %v", wantModes, modes)
+	}
+
+	if !logical.Modes[0].Default || logical.Modes[0].Active {
+		t.Errorf("wrong flags for tiled mode: %+v", logical.Modes[0])
+	}
+
+	// incomplete tiled monitors are left alone
+	incomplete := Outputs{testTiles[0], testTiles[1]}
+	if merged := incomplete.MergeTiles(); !reflect.DeepEqual(merged, incomplete) {
+		t.Fatalf("incomplete tiled monitor merged: %v", merged)
+	}
+}
+
+func TestComputeLayoutTiles(t *testing.T) {
+	outputs := testTiles.MergeTiles()
+
+	var tests = []struct {
+		rule   Rule
+		layout Layout
+	}{
+		{
+			Rule{ConfigureRow: []string{"eDP-1", "DP-1@5120x2880@59.9"}, Primary: "DP-1"},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "eDP-1", Mode: "1920x1080", Width: 1920, Height: 1080},
+					{Name: "DP-1", Mode: "2560x2880", X: 1920, Width: 2560, Height: 2880, Rate: 60, Primary: true},
+					{Name: "DP-2", Mode: "2560x2880", X: 4480, Width: 2560, Height: 2880, Rate: 60},
+				},
+				Width:  7040,
+				Height: 2880,
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP-1@1920x1080"},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "DP-1", Mode: "1920x1080", Width: 1920, Height: 1080},
+				},
+				Disable: []string{"eDP-1", "DP-2"},
+				Width:   1920,
+				Height:  1080,
+			},
+		},
+		{
+			Rule{ConfigureSingle: "eDP-1"},
+			Layout{
+				Outputs: []LayoutOutput{
+					{Name: "eDP-1", Mode: "1920x1080", Width: 1920, Height: 1080},
+				},
+				Disable: []string{"DP-1", "DP-2"},
+				Width:   1920,
+				Height:  1080,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			layout, err := ComputeLayout(test.rule, outputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(layout, test.layout) {
+				t.Fatalf("wrong layout returned\nwant: %+v\ngot:  %+v", test.layout, layout)
+			}
+		})
+	}
+}